@@ -5,11 +5,12 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/duck"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/govinfo"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/lsa"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/parquet"
-	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/store"
 	delivery "github.com/Jibbscript/ecfr-dereg-dashboard/internal/delivery/http"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/platform"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/usecase"
@@ -27,8 +28,7 @@ func main() {
 	var parquetRepo *parquet.Repo
 	var err error
 
-	// GCS backend (prod/staging)
-	if config.Env == "local" || config.Env == "dev" {
+	if config.StorageBackend == platform.StorageLocal {
 		// Local filesystem backend
 		parquetRepo, err = parquet.NewLocalRepo(config.DataDir, config.ParquetPrefix)
 		if err != nil {
@@ -39,20 +39,27 @@ func main() {
 			zap.String("prefix", config.ParquetPrefix),
 		)
 	} else {
-		// GCS backend (prod/staging)
-		parquetRepo, err = parquet.NewRepo(ctx, config.ParquetBucket, config.ParquetPrefix)
+		// Bucket backend (GCS or S3-compatible)
+		store, err := blob.NewBucketStore(ctx, config, config.ParquetBucket)
 		if err != nil {
-			logger.Fatal("Failed to create Parquet repo", zap.Error(err))
+			logger.Fatal("Failed to create Parquet repo", zap.String("backend", config.StorageBackend), zap.Error(err))
 		}
+		parquetRepo = parquet.NewStoreRepo(store, config.ParquetPrefix)
+		logger.Info("Using bucket Parquet repo",
+			zap.String("backend", config.StorageBackend),
+			zap.String("bucket", config.ParquetBucket),
+			zap.String("prefix", config.ParquetPrefix),
+		)
 	}
 
-	sqliteRepo, err := sqlite.NewRepo(config.DataDir + "/ecfr.db")
+	repo, err := store.Open(config)
 	if err != nil {
-		logger.Fatal("Failed to create SQLite repo", zap.Error(err))
+		logger.Fatal("Failed to open relational store", zap.Error(err))
 	}
+	defer repo.Close()
 
 	// Collect LSA data on startup if stale or missing
-	hasRecent, err := sqliteRepo.HasRecentAgencyLSA(1)
+	hasRecent, err := repo.HasRecentAgencyLSA(1)
 	if err != nil || !hasRecent {
 		logger.Info("Collecting fresh LSA data from Federal Register API")
 		lsaCollector := lsa.NewCollector()
@@ -60,7 +67,7 @@ func main() {
 		if lsaErr != nil {
 			logger.Warn("LSA collection failed", zap.Error(lsaErr))
 		} else {
-			if insertErr := sqliteRepo.InsertAgencyLSABatch(records); insertErr != nil {
+			if insertErr := repo.InsertAgencyLSABatch(records); insertErr != nil {
 				logger.Warn("LSA insert failed", zap.Error(insertErr))
 			} else {
 				logger.Info("LSA data refreshed", zap.Int("agencies", len(records)))
@@ -68,27 +75,38 @@ func main() {
 		}
 	}
 
-	duckHelper, err := duck.NewHelper(parquetRepo, sqliteRepo, config.DuckDBUI)
+	// DuckDB can only attach the SQLite store
+	sqlitePath := ""
+	if config.DatabaseURL == "" {
+		sqlitePath = store.SQLitePath(config)
+	}
+	duckHelper, err := duck.NewHelper(parquetRepo, sqlitePath, config.DuckDBUI)
 	if err != nil {
 		logger.Fatal("Failed to create DuckDB helper", zap.Error(err))
 	}
 
 	var govinfoClient *govinfo.Client
-	if config.Env == "local" || config.Env == "dev" {
+	if config.StorageBackend == platform.StorageLocal {
 		logger.Warn("Skipping GovInfo Client initialization (local mode)")
 		// Ideally mock this if needed, or ensure it's not used for read-only flows
 	} else {
-		govinfoClient, err = govinfo.NewClient(ctx, config.RawXMLBucket, config.RawXMLPrefix)
+		rawStore, err := blob.NewBucketStore(ctx, config, config.RawXMLBucket)
 		if err != nil {
 			logger.Fatal("Failed to create GovInfo client", zap.Error(err))
 		}
+		govinfoClient = govinfo.NewStoreClient(rawStore, config.RawXMLPrefix)
 	}
 
 	usecases := delivery.Usecases{
-		Ingest:    usecase.NewIngest(logger, govinfoClient, parquetRepo, sqliteRepo),
-		Snapshot:  usecase.NewSnapshot(parquetRepo, sqliteRepo),
-		Metrics:   usecase.NewMetrics(duckHelper, sqliteRepo),
-		Summaries: usecase.NewSummariesReadOnly(logger, sqliteRepo),
+		Ingest:     usecase.NewIngest(logger, govinfoClient, parquetRepo, repo),
+		Snapshot:   usecase.NewSnapshot(parquetRepo, repo),
+		Metrics:    usecase.NewMetrics(duckHelper, repo),
+		Agencies:   usecase.NewAgencies(repo),
+		Summaries:  usecase.NewSummariesReadOnly(logger, repo),
+		Scoreboard: usecase.NewScoreboard(repo),
+		Search:     usecase.NewSearch(repo),
+		Titles:     usecase.NewTitles(repo),
+		Sections:   usecase.NewSections(repo),
 	}
 
 	r := chi.NewRouter()
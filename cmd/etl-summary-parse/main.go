@@ -140,9 +140,9 @@ func main() {
 
 	if len(summaries) > 0 {
 		// Save to Parquet
-		// We use the current date as the snapshot date for this repair job
-		snapshotDate := time.Now().Format("2006-01-02")
-		if err := parquetRepo.WriteSummaries(ctx, snapshotDate, summaries); err != nil {
+		// The repair job's batch is named for the day it ran; it is not a snapshot
+		batch := time.Now().UTC().Format("2006-01-02")
+		if err := parquetRepo.WriteSummaries(ctx, batch, summaries); err != nil {
 			logger.Fatal("Failed to write summaries to Parquet", zap.Error(err))
 		}
 		logger.Info("Successfully saved summaries to Parquet")
@@ -7,10 +7,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/ecfr"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/govinfo"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/lsa"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/parquet"
-	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/store"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/platform"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/usecase"
@@ -35,8 +37,7 @@ func main() {
 	var parquetRepo *parquet.Repo
 	var err error
 
-	// GCS backend (prod/staging)
-	if config.Env == "local" || config.Env == "dev" {
+	if config.StorageBackend == platform.StorageLocal {
 		// Local filesystem backend
 		parquetRepo, err = parquet.NewLocalRepo(config.DataDir, config.ParquetPrefix)
 		if err != nil {
@@ -47,29 +48,85 @@ func main() {
 			zap.String("prefix", config.ParquetPrefix),
 		)
 	} else {
-		parquetRepo, err = parquet.NewRepo(ctx, config.ParquetBucket, config.ParquetPrefix)
+		// Bucket backend (GCS or S3-compatible)
+		store, err := blob.NewBucketStore(ctx, config, config.ParquetBucket)
 		if err != nil {
-			logger.Fatal("Failed to create Parquet repo", zap.Error(err))
+			logger.Fatal("Failed to create Parquet repo", zap.String("backend", config.StorageBackend), zap.Error(err))
 		}
+		parquetRepo = parquet.NewStoreRepo(store, config.ParquetPrefix)
+		logger.Info("Using bucket Parquet repo",
+			zap.String("backend", config.StorageBackend),
+			zap.String("bucket", config.ParquetBucket),
+			zap.String("prefix", config.ParquetPrefix),
+		)
 	}
 
-	sqlitePath := config.DataDir + "/ecfr.db"
-	logger.Info("Initializing storage adapters", zap.String("sqlite_path", sqlitePath))
-	sqliteRepo, err := sqlite.NewRepo(sqlitePath)
+	logger.Info("Initializing storage adapters", zap.Bool("postgres", config.DatabaseURL != ""))
+	repo, err := store.Open(config)
+	if err != nil {
+		logger.Fatal("Failed to initialize relational store", zap.Error(err))
+	}
+	defer repo.Close()
+
+	// Sync the agency registry from the eCFR admin API, or AGENCIES_FILE when set. On failure
+	// the agencies from the last sync stay in place.
+	var agencies []ecfr.Agency
+	if config.AgenciesFile != "" {
+		logger.Info("Loading agency registry", zap.String("path", config.AgenciesFile))
+		agencies, err = ecfr.LoadAgencies(config.AgenciesFile)
+	} else {
+		logger.Info("Fetching agency registry from the eCFR admin API")
+		agencies, err = ecfr.NewClient().GetAgencies(ctx)
+	}
 	if err != nil {
-		logger.Fatal("Failed to initialize SQLite repo", zap.String("path", sqlitePath), zap.Error(err))
+		logger.Warn("Agency registry unavailable (keeping the current agencies)", zap.Error(err))
+	} else if changes, err := repo.SyncAgencies(agencies, time.Now()); err != nil {
+		logger.Warn("Agency sync failed (keeping the current agencies)", zap.Error(err))
+	} else {
+		for _, c := range changes {
+			logger.Info("Agency changed",
+				zap.String("agency_id", c.AgencyID),
+				zap.String("kind", c.Kind),
+				zap.String("old", c.OldValue),
+				zap.String("new", c.NewValue))
+		}
+		logger.Info("Agency registry synced", zap.Int("changes", len(changes)))
 	}
 
-	// Ingest agency data from JSON (refreshes every ETL run)
-	agenciesPath := "ecfr_agencies.json"
-	logger.Info("Ingesting agency data", zap.String("path", agenciesPath))
-	if err := sqliteRepo.IngestAgencies(agenciesPath); err != nil {
-		logger.Warn("Agency ingestion failed (continuing without agency mapping)", zap.Error(err))
+	// Title names and amendment dates for /api/titles; the last stored catalog stays on failure
+	if titles, err := ecfr.NewClient().GetTitles(ctx); err != nil {
+		logger.Warn("Title catalog unavailable (keeping the stored titles)", zap.Error(err))
+	} else if err := repo.UpsertTitles(titles); err != nil {
+		logger.Warn("Title catalog update failed (keeping the stored titles)", zap.Error(err))
 	} else {
-		logger.Info("Agency data ingested successfully")
+		logger.Info("Title catalog updated", zap.Int("titles", len(titles)))
+	}
+
+	// Part-level assignments that override the agencies' CFR references (optional)
+	partOverridesPath := "part_owner_overrides.json"
+	partOverrides, err := ecfr.LoadPartOverrides(partOverridesPath)
+	if err != nil {
+		logger.Fatal("Failed to load part owner overrides", zap.String("path", partOverridesPath), zap.Error(err))
 	}
 
-	govinfoClient, err := govinfo.NewClient(ctx, config.RawXMLBucket, config.RawXMLPrefix)
+	// Federal Register to eCFR agency matches that the name matching gets wrong or misses (optional)
+	frOverridesPath := "fr_agency_overrides.json"
+	frOverrides, err := lsa.LoadAgencyOverrides(frOverridesPath)
+	if err != nil {
+		logger.Fatal("Failed to load Federal Register agency overrides", zap.String("path", frOverridesPath), zap.Error(err))
+	}
+
+	// Raw XML always lives in a bucket; the local backend keeps reading it from GCS
+	var govinfoClient *govinfo.Client
+	if config.StorageBackend == platform.StorageLocal {
+		govinfoClient, err = govinfo.NewClient(ctx, config.RawXMLBucket, config.RawXMLPrefix)
+	} else {
+		var rawStore blob.Store
+		rawStore, err = blob.NewBucketStore(ctx, config, config.RawXMLBucket)
+		if err == nil {
+			govinfoClient = govinfo.NewStoreClient(rawStore, config.RawXMLPrefix)
+		}
+	}
 	if err != nil {
 		if config.Env == "local" {
 			logger.Warn("Failed to create GovInfo client (skipping ETL steps that require it)", zap.Error(err))
@@ -81,13 +138,38 @@ func main() {
 
 	lsaCollector := lsa.NewCollector()
 
-	ingestUseCase := usecase.NewIngest(logger, govinfoClient, parquetRepo, sqliteRepo)
-	snapshotUseCase := usecase.NewSnapshot(parquetRepo, sqliteRepo)
+	ingestUseCase := usecase.NewIngest(logger, govinfoClient, parquetRepo, repo)
+	snapshotUseCase := usecase.NewSnapshot(parquetRepo, repo)
+	attributionUseCase := usecase.NewAttribution(logger, lsaCollector, repo)
+	reconciliationUseCase := usecase.NewAgencyReconciliation(logger, lsaCollector, repo)
+
+	// Snapshots from before manifests existed get one, so they stay readable and diffable
+	backfilled, err := parquetRepo.BackfillLegacyManifests(ctx)
+	if err != nil {
+		logger.Fatal("Failed to backfill legacy snapshot manifests", zap.Error(err))
+	}
+	if len(backfilled) > 0 {
+		logger.Info("Backfilled legacy snapshot manifests", zap.Strings("snapshots", backfilled))
+	}
+
+	// Each run writes its own snapshot, so a rerun on the same day never overwrites earlier output
+	snapshotID := domain.NewSnapshotID(pipelineStart)
+	logger.Info("Writing snapshot", zap.String("snapshot", snapshotID))
 
-	snapshotDate := time.Now().Format("2006-01-02")
+	// The manifest starts out incomplete so readers ignore this snapshot until the run finishes
+	manifest := usecase.NewManifestBuilder(snapshotID, pipelineStart)
+	if err := parquetRepo.WriteManifest(ctx, manifest.Build(domain.SnapshotIncomplete, time.Time{})); err != nil {
+		logger.Fatal("Failed to write initial snapshot manifest", zap.Error(err))
+	}
+
+	// Every title is diffed against the same previous snapshot, resolved once for the run
+	prevSnapshot, err := snapshotUseCase.PrevSnapshot(ctx, snapshotID)
+	if err != nil {
+		logger.Fatal("Failed to resolve the previous snapshot", zap.Error(err))
+	}
 
 	// Step 1: Fetch title catalog
-	logger.Info("Step 1/5: Fetching changed titles (Extract)")
+	logger.Info("Step 1/6: Fetching changed titles (Extract)")
 	extractStart := time.Now()
 	changedTitles, err := ingestUseCase.FetchChangedTitles(ctx)
 	if err != nil {
@@ -97,20 +179,6 @@ func main() {
 		zap.Int("count", len(changedTitles)),
 		zap.Duration("duration", time.Since(extractStart)))
 
-	// --- OPTIMIZATION: SQLite Writer Actor ---
-	// A dedicated goroutine for SQLite writes to prevent lock contention
-	sqliteCh := make(chan []domain.Section, 100) // Buffered channel
-	var sqliteWg sync.WaitGroup
-	sqliteWg.Add(1)
-	go func() {
-		defer sqliteWg.Done()
-		for sections := range sqliteCh {
-			if err := sqliteRepo.InsertSections(sections); err != nil {
-				logger.Error("SQLite insert failed", zap.Error(err))
-			}
-		}
-	}()
-
 	// --- OPTIMIZATION: Parallel Title Processing ---
 	// Limit concurrency to avoid FD exhaustion (e.g., 4 concurrent titles)
 	// While we have many cores, we don't want to hammer external APIs too hard
@@ -118,6 +186,12 @@ func main() {
 	sem := make(chan struct{}, maxConcurrentTitles)
 	var wg sync.WaitGroup
 
+	// Titles and diffs are only loaded into the relational store once the snapshot is published,
+	// so the API never serves a run that fails partway
+	var loadTitles []string
+	var allDiffs []domain.Diff
+	var diffsMu sync.Mutex
+
 	totalTitles := len(changedTitles)
 
 	for i, title := range changedTitles {
@@ -136,38 +210,47 @@ func main() {
 
 			// Step 2: Pull sections (Extract)
 			// Note: IngestTitle is now internally parallelized for regex ops
-			sections, err := ingestUseCase.IngestTitle(ctx, t)
+			sections, err := ingestUseCase.IngestTitle(ctx, snapshotID, t)
 			if err != nil {
 				if err == domain.ErrNotFound {
 					logger.Warn("Title not found (skipping)", zap.String("title", t.Title))
+					manifest.TitleFailed(t.Title, domain.TitleSkipped, err)
 					return
 				}
 				logger.Error("Ingest failed for title", zap.String("title", t.Title), zap.Error(err))
+				manifest.TitleFailed(t.Title, domain.TitleFailed, err)
 				return
 			}
 
 			// Write to Parquet (Thread-safe for different titles)
-			if err := parquetRepo.WriteSections(ctx, snapshotDate, t.Title, sections); err != nil {
+			if err := parquetRepo.WriteSections(ctx, snapshotID, t.Title, sections); err != nil {
 				logger.Error("Parquet write failed", zap.String("title", t.Title), zap.Error(err))
+				manifest.TitleFailed(t.Title, domain.TitleFailed, err)
+				return
 			}
 
-			// Send to SQLite Writer (Non-blocking if buffer space exists)
-			select {
-			case sqliteCh <- sections:
-			case <-ctx.Done():
-				return
+			sourceHash, err := ingestUseCase.SourceChecksum(ctx, t)
+			if err != nil {
+				logger.Warn("Source XML checksum failed", zap.String("title", t.Title), zap.Error(err))
 			}
+			manifest.TitleSucceeded(t.Title, len(sections), sourceHash)
+			recordFile(ctx, logger, parquetRepo, manifest, snapshotID, t.Title, t.Title+".parquet", len(sections))
 
 			// Step 3: Compute deltas (Transform)
-			diffs, err := snapshotUseCase.ComputeDiffs(ctx, snapshotDate, t.Title)
+			diffs, err := snapshotUseCase.ComputeDiffs(ctx, prevSnapshot, t.Title, sections)
 			if err != nil {
 				logger.Error("Diff compute failed", zap.String("title", t.Title), zap.Error(err))
+			} else if err := parquetRepo.WriteDiffs(ctx, snapshotID, t.Title, diffs); err != nil {
+				logger.Error("Diff write failed", zap.String("title", t.Title), zap.Error(err))
 			} else {
-				if err := parquetRepo.WriteDiffs(ctx, snapshotDate, t.Title, diffs); err != nil {
-					logger.Error("Diff write failed", zap.String("title", t.Title), zap.Error(err))
-				}
+				recordFile(ctx, logger, parquetRepo, manifest, snapshotID, t.Title, t.Title+"_diffs.parquet", len(diffs))
 			}
 
+			diffsMu.Lock()
+			loadTitles = append(loadTitles, t.Title)
+			allDiffs = append(allDiffs, diffs...)
+			diffsMu.Unlock()
+
 			logger.Info("Completed title",
 				zap.String("title", t.Title),
 				zap.Duration("duration", time.Since(titleStart)))
@@ -178,42 +261,92 @@ func main() {
 	// Wait for all title workers to finish
 	wg.Wait()
 
-	// Close SQLite channel and wait for writer to drain
-	close(sqliteCh)
-	sqliteWg.Wait()
-
 	// Step 4: Collect Agency-level LSA data from Federal Register API
 	logger.Info("Step 4/6: Collecting agency-level LSA data (Transform)")
 	agencyLSAStart := time.Now()
 
-	agencyLSARecords, err := lsaCollector.CollectAgencyLSABatch(ctx)
-	if err != nil {
-		logger.Error("Agency LSA batch collection failed", zap.Error(err))
+	agencyLSARecords, lsaErr := lsaCollector.CollectAgencyLSABatch(ctx)
+	if lsaErr != nil {
+		logger.Error("Agency LSA batch collection failed", zap.Error(lsaErr))
 	} else {
 		logger.Info("Collected agency LSA data",
 			zap.Int("agency_count", len(agencyLSARecords)),
 			zap.Duration("duration", time.Since(agencyLSAStart)))
 
-		// Write to SQLite
-		if err := sqliteRepo.InsertAgencyLSABatch(agencyLSARecords); err != nil {
-			logger.Error("Agency LSA SQLite write failed", zap.Error(err))
-		} else {
-			logger.Info("Agency LSA data written to SQLite")
-		}
-
 		// Write to Parquet
-		if err := parquetRepo.WriteAgencyLSA(ctx, snapshotDate, agencyLSARecords); err != nil {
+		if err := parquetRepo.WriteAgencyLSA(ctx, snapshotID, agencyLSARecords); err != nil {
 			logger.Error("Agency LSA Parquet write failed", zap.Error(err))
 		} else {
 			logger.Info("Agency LSA data written to Parquet")
+			recordFile(ctx, logger, parquetRepo, manifest, snapshotID, "", "agency_lsa.parquet", len(agencyLSARecords))
+		}
+	}
+
+	// Publish the manifest before touching the relational store: the snapshot becomes readable
+	// only once it is complete, which it never is if a title failed
+	status := manifest.Status()
+	completedAt := time.Now()
+	if err := parquetRepo.WriteManifest(ctx, manifest.Build(status, completedAt)); err != nil {
+		logger.Fatal("Failed to write snapshot manifest", zap.Error(err))
+	}
+	if status != domain.SnapshotComplete {
+		logger.Fatal("Snapshot left incomplete and unpublished",
+			zap.String("snapshot", snapshotID), zap.Strings("failed_titles", manifest.FailedTitles()))
+	}
+	published, err := snapshotUseCase.Publish(ctx, snapshotID)
+	if err != nil {
+		logger.Fatal("Failed to publish snapshot", zap.Error(err))
+	}
+	logger.Info("Published snapshot", zap.String("day", published.Day), zap.String("snapshot", published.SnapshotID))
+
+	// Load the published sections, read back from Parquet a title at a time
+	logger.Info("Loading the published snapshot into the relational store")
+	loadStart := time.Now()
+	for _, title := range loadTitles {
+		if _, err := snapshotUseCase.LoadSections(ctx, snapshotID, title); err != nil {
+			logger.Error("Section load failed", zap.String("title", title), zap.Error(err))
 		}
 	}
+	logger.Info("Loaded sections", zap.Int("titles", len(loadTitles)), zap.Duration("duration", time.Since(loadStart)))
+
+	// Every agency metric below joins sections to agencies through their parts' owners
+	if err := repo.ResolvePartOwners(partOverrides); err != nil {
+		logger.Error("Part ownership resolution failed (keeping the previous owners)", zap.Error(err))
+	} else {
+		logger.Info("Resolved part ownership", zap.Int("overrides", len(partOverrides)))
+	}
+
+	if err := repo.InsertDiffs(snapshotID, allDiffs); err != nil {
+		logger.Error("Diff SQLite write failed", zap.Error(err))
+	}
+
+	// Attribute changed sections to the Federal Register final rules that cite their parts
+	attributions, err := attributionUseCase.AttributeDiffs(ctx, snapshotID, allDiffs)
+	if err != nil {
+		logger.Error("Diff attribution failed", zap.Error(err))
+	} else {
+		logger.Info("Attributed section changes to Federal Register documents",
+			zap.Int("attributions", len(attributions)))
+	}
+
+	if lsaErr == nil {
+		if err := repo.InsertAgencyLSABatch(agencyLSARecords); err != nil {
+			logger.Error("Agency LSA SQLite write failed", zap.Error(err))
+		} else {
+			logger.Info("Agency LSA data written to SQLite")
+		}
+	}
+
+	// LSA records are keyed by Federal Register slugs; match them to the eCFR agencies
+	if _, err := reconciliationUseCase.Reconcile(ctx, frOverrides); err != nil {
+		logger.Error("Federal Register agency reconciliation failed (keeping the previous matches)", zap.Error(err))
+	}
 
 	// Step 5: Pre-compute agency content checksums
 	logger.Info("Step 5/6: Pre-computing agency content checksums")
 	checksumStart := time.Now()
 
-	agencyIDs, err := sqliteRepo.GetAllAgencyIDs()
+	agencyIDs, err := repo.GetAllAgencyIDs()
 	if err != nil {
 		logger.Error("Failed to get agency IDs for checksum computation", zap.Error(err))
 	} else {
@@ -231,7 +364,7 @@ func main() {
 				defer checksumWg.Done()
 				defer func() { <-checksumSem }() // Release
 
-				checksum, err := sqliteRepo.GetAgencyChecksum(id)
+				checksum, err := repo.GetAgencyChecksum(id)
 				if err != nil {
 					logger.Error("Failed to compute checksum", zap.String("agency", id), zap.Error(err))
 					return
@@ -240,7 +373,7 @@ func main() {
 					return // No sections for this agency
 				}
 
-				if err := sqliteRepo.UpdateAgencyChecksum(id, checksum); err != nil {
+				if err := repo.UpdateAgencyChecksum(id, checksum); err != nil {
 					logger.Error("Failed to update checksum", zap.String("agency", id), zap.Error(err))
 					return
 				}
@@ -257,7 +390,44 @@ func main() {
 			zap.Duration("duration", time.Since(checksumStart)))
 	}
 
+	// Step 6: Materialize per-agency metrics for /api/agencies and the time series
+	logger.Info("Step 6/6: Materializing agency metrics and history")
+	historyStart := time.Now()
+
+	agencyMetrics, err := repo.SnapshotAgencyMetrics(snapshotID, time.Now())
+	if err != nil {
+		logger.Error("Agency metrics snapshot failed", zap.Error(err))
+	} else {
+		if err := parquetRepo.WriteAgencyMetrics(ctx, snapshotID, agencyMetrics); err != nil {
+			logger.Error("Agency metrics Parquet write failed", zap.Error(err))
+		} else {
+			recordFile(ctx, logger, parquetRepo, manifest, snapshotID, "", "agency_metrics.parquet", len(agencyMetrics))
+			// The metrics come from the relational store, so they join the manifest after publishing
+			if err := parquetRepo.WriteManifest(ctx, manifest.Build(status, completedAt)); err != nil {
+				logger.Error("Failed to add agency metrics to the snapshot manifest", zap.Error(err))
+			}
+		}
+		logger.Info("Agency metrics history recorded",
+			zap.Int("agency_count", len(agencyMetrics)),
+			zap.Duration("duration", time.Since(historyStart)))
+	}
+
 	logger.Info("ETL Pipeline Completed Successfully",
-		zap.String("snapshot", snapshotDate),
+		zap.String("snapshot", snapshotID),
 		zap.Duration("total_duration", time.Since(pipelineStart)))
 }
+
+// recordFile adds a written snapshot file to the manifest. An empty title marks a snapshot-wide file.
+func recordFile(ctx context.Context, logger *zap.Logger, repo *parquet.Repo, manifest *usecase.ManifestBuilder, snapshot, title, name string, rows int) {
+	info, err := repo.FileInfo(ctx, snapshot, name)
+	if err != nil {
+		logger.Warn("Failed to checksum snapshot file", zap.String("file", name), zap.Error(err))
+		return
+	}
+	info.Rows = rows
+	if title == "" {
+		manifest.AddFile(info)
+		return
+	}
+	manifest.AddTitleFile(title, info)
+}
@@ -7,9 +7,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/ecfr"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/parquet"
-	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/store"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/vertexai"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/platform"
@@ -25,7 +26,7 @@ func main() {
 	defer logger.Sync()
 
 	titleFilter := flag.String("title", "", "Generate summary for a specific title (e.g., '1', '42'). If empty, processes all titles.")
-	snapshotDate := flag.String("snapshot-date", time.Now().Format("2006-01-02"), "Snapshot date for output (YYYY-MM-DD)")
+	batch := flag.String("batch", time.Now().UTC().Format("2006-01-02"), "Name of the output batch, written to summaries/<batch>.parquet")
 	maxConcurrency := flag.Int("concurrency", 2, "Maximum concurrent title processing (recommended: 2-4 for API rate limits)")
 	flag.Parse()
 
@@ -33,7 +34,7 @@ func main() {
 		zap.String("env", config.Env),
 		zap.String("data_dir", config.DataDir),
 		zap.String("title_filter", *titleFilter),
-		zap.String("snapshot_date", *snapshotDate),
+		zap.String("batch", *batch),
 		zap.Int("max_concurrency", *maxConcurrency),
 		zap.Int("gomaxprocs", runtime.GOMAXPROCS(0)))
 	jobStart := time.Now()
@@ -43,7 +44,7 @@ func main() {
 	var parquetRepo *parquet.Repo
 	var err error
 
-	if config.Env == "local" || config.Env == "dev" {
+	if config.StorageBackend == platform.StorageLocal {
 		parquetRepo, err = parquet.NewLocalRepo(config.DataDir, config.ParquetPrefix)
 		if err != nil {
 			logger.Fatal("Failed to create local Parquet repo", zap.Error(err))
@@ -53,10 +54,11 @@ func main() {
 			zap.String("prefix", config.ParquetPrefix),
 		)
 	} else {
-		parquetRepo, err = parquet.NewRepo(ctx, config.ParquetBucket, config.ParquetPrefix)
+		store, err := blob.NewBucketStore(ctx, config, config.ParquetBucket)
 		if err != nil {
-			logger.Fatal("Failed to create Parquet repo", zap.Error(err))
+			logger.Fatal("Failed to create Parquet repo", zap.String("backend", config.StorageBackend), zap.Error(err))
 		}
+		parquetRepo = parquet.NewStoreRepo(store, config.ParquetPrefix)
 	}
 
 	vertexClient, err := vertexai.NewClient(ctx, config.VertexProjectID, config.VertexLocation, config.VertexModelID, config.GCSBucket)
@@ -66,15 +68,16 @@ func main() {
 
 	ecfrClient := ecfr.NewClient()
 
-	sqliteRepo, err := sqlite.NewRepo(config.DataDir + "/ecfr.db")
+	repo, err := store.Open(config)
 	if err != nil {
-		logger.Fatal("Failed to create SQLite repo", zap.Error(err))
+		logger.Fatal("Failed to open relational store", zap.Error(err))
 	}
+	defer repo.Close()
 
-	summariesUseCase := usecase.NewSummaries(logger, vertexClient, parquetRepo, sqliteRepo)
+	summariesUseCase := usecase.NewSummaries(logger, vertexClient, parquetRepo, repo)
 
 	logger.Info("Fetching title catalog from eCFR API")
-	titles, err := ecfrClient.GetTitles()
+	titles, err := ecfrClient.GetTitles(ctx)
 	if err != nil {
 		logger.Fatal("Failed to fetch title catalog", zap.Error(err))
 	}
@@ -130,7 +133,7 @@ func main() {
 
 	if len(allSummaries) > 0 {
 		logger.Info("Writing summaries to Parquet", zap.Int("count", len(allSummaries)))
-		if err := parquetRepo.WriteSummaries(ctx, *snapshotDate, allSummaries); err != nil {
+		if err := parquetRepo.WriteSummaries(ctx, *batch, allSummaries); err != nil {
 			logger.Fatal("Failed to write summaries", zap.Error(err))
 		}
 	} else {
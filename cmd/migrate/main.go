@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/postgres"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
+)
+
+const usage = `Usage: migrate [-db path | -postgres dsn] status|up|down [steps]
+
+  status      list migrations and when each was applied
+  up          apply every pending migration
+  down [n]    revert the last n applied migrations (default 1)
+`
+
+// status is one migration as both stores report it.
+type status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// migrator is the migration API the SQLite and PostgreSQL stores share.
+type migrator interface {
+	MigrateUp() (int, error)
+	MigrateDown(steps int) (int, error)
+	Close() error
+}
+
+func main() {
+	dbPath := flag.String("db", filepath.Join(getEnv("DATA_DIR", "./data"), "ecfr.db"), "SQLite database path")
+	dsn := flag.String("postgres", os.Getenv("DATABASE_URL"), "PostgreSQL connection string; migrates PostgreSQL instead of SQLite")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var repo migrator
+	var listStatus func() ([]status, error)
+	if *dsn != "" {
+		pg, err := postgres.Open(*dsn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open database: %v\n", err)
+			os.Exit(1)
+		}
+		repo = pg
+		listStatus = func() ([]status, error) {
+			ms, err := pg.MigrationStatus()
+			out := make([]status, len(ms))
+			for i, m := range ms {
+				out[i] = status(m)
+			}
+			return out, err
+		}
+	} else {
+		lite, err := sqlite.Open(*dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open database: %v\n", err)
+			os.Exit(1)
+		}
+		repo = lite
+		listStatus = func() ([]status, error) {
+			ms, err := lite.MigrationStatus()
+			out := make([]status, len(ms))
+			for i, m := range ms {
+				out[i] = status(m)
+			}
+			return out, err
+		}
+	}
+	defer repo.Close()
+
+	switch flag.Arg(0) {
+	case "status":
+		all, err := listStatus()
+		if err != nil {
+			fail(repo, err)
+		}
+		for _, m := range all {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-28s %s\n", m.Version, m.Name, applied)
+		}
+	case "up":
+		n, err := repo.MigrateUp()
+		if err != nil {
+			fail(repo, err)
+		}
+		fmt.Printf("Applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			if _, err := fmt.Sscan(flag.Arg(1), &steps); err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "Invalid step count %q\n", flag.Arg(1))
+				os.Exit(2)
+			}
+		}
+		n, err := repo.MigrateDown(steps)
+		if err != nil {
+			fail(repo, err)
+		}
+		fmt.Printf("Reverted %d migration(s)\n", n)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func fail(repo migrator, err error) {
+	fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+	repo.Close()
+	os.Exit(1)
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
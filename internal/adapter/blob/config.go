@@ -0,0 +1,31 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/platform"
+)
+
+// NewBucketStore opens a bucket on the configured remote backend (gcs or s3). The local
+// backend has no buckets; callers use NewLocal with a directory instead.
+func NewBucketStore(ctx context.Context, cfg platform.Config, bucket string) (Store, error) {
+	switch cfg.StorageBackend {
+	case platform.StorageGCS:
+		c, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return NewGCS(c, bucket), nil
+	case platform.StorageS3:
+		c, err := NewS3Client(cfg.S3Endpoint, cfg.S3Region, cfg.S3AccessKeyID, cfg.S3SecretAccessKey, cfg.S3UseSSL)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3(c, bucket), nil
+	default:
+		return nil, fmt.Errorf("storage backend %q has no buckets", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,170 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// GCS stores objects in a Google Cloud Storage bucket
+type GCS struct {
+	client *storage.Client
+	bucket string
+}
+
+func NewGCS(client *storage.Client, bucket string) *GCS {
+	return &GCS{client: client, bucket: bucket}
+}
+
+func (s *GCS) object(key string) *storage.ObjectHandle {
+	return s.client.Bucket(s.bucket).Object(key)
+}
+
+func (s *GCS) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, err := s.object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (s *GCS) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	rc, err := s.object(key).NewRangeReader(ctx, offset, length)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// Create uploads with a generation precondition: the write only succeeds if the object is
+// still absent, or still at the generation seen here, so a concurrent writer is never
+// silently overwritten. GCS only publishes an object once its upload completes.
+func (s *GCS) Create(ctx context.Context, key string) (Writer, error) {
+	obj := s.object(key)
+	cond := storage.Conditions{DoesNotExist: true}
+	attrs, err := obj.Attrs(ctx)
+	if err == nil {
+		cond = storage.Conditions{GenerationMatch: attrs.Generation}
+	} else if !errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, err
+	}
+
+	// Cancelling the upload's context is how a GCS write is abandoned without saving
+	ctx, cancel := context.WithCancel(ctx)
+	return &gcsWriter{w: obj.If(cond).NewWriter(ctx), cancel: cancel, key: key}, nil
+}
+
+type gcsWriter struct {
+	w       *storage.Writer
+	cancel  context.CancelFunc
+	key     string
+	done    bool
+	aborted bool
+}
+
+func (w *gcsWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w *gcsWriter) Close() error {
+	if w.aborted {
+		return errAborted
+	}
+	if w.done {
+		return nil
+	}
+	w.done = true
+	defer w.cancel()
+
+	err := w.w.Close()
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %s", ErrConflict, w.key)
+	}
+	return err
+}
+
+func (w *gcsWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.aborted = true
+	w.cancel()
+	// Close reports the cancellation; nothing was written
+	_ = w.w.Close()
+	return nil
+}
+
+func (s *GCS) List(ctx context.Context, prefix string) ([]string, error) {
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	var keys []string
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, attrs.Name)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *GCS) ListPrefixes(ctx context.Context, dir string) ([]string, error) {
+	prefix := dirPrefix(dir)
+	// With a delimiter, "directories" come back as ObjectAttrs with only Prefix set
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: prefix, Delimiter: "/"})
+	var names []string
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if attrs.Prefix != "" {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(attrs.Prefix, prefix), "/"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *GCS) Stat(ctx context.Context, key string) (Attrs, error) {
+	attrs, err := s.object(key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return Attrs{}, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	if err != nil {
+		return Attrs{}, err
+	}
+	return Attrs{Key: key, Size: attrs.Size, Updated: attrs.Updated}, nil
+}
+
+func (s *GCS) Delete(ctx context.Context, key string) error {
+	err := s.object(key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	return err
+}
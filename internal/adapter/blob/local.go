@@ -0,0 +1,200 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Local stores objects as files under a root directory
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{root: root}, nil
+}
+
+func (s *Local) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *Local) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, offset, length), f}, nil
+}
+
+// Create writes to a hidden temp file next to the target and renames it into place on Close,
+// so a crash mid-write never leaves a truncated object at key.
+func (s *Local) Create(ctx context.Context, key string) (Writer, error) {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+tempMarker+"*")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{f: f, path: path}, nil
+}
+
+// tempMarker tags in-progress temp files so listings skip them
+const tempMarker = ".tmp-"
+
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, tempMarker)
+}
+
+type localWriter struct {
+	f       *os.File
+	path    string
+	done    bool
+	aborted bool
+}
+
+func (w *localWriter) Write(p []byte) (int, error) {
+	return w.f.Write(p)
+}
+
+func (w *localWriter) Close() error {
+	if w.aborted {
+		return errAborted
+	}
+	if w.done {
+		return nil
+	}
+	w.done = true
+	if err := w.f.Sync(); err != nil {
+		w.discard()
+		return err
+	}
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	if err := os.Chmod(w.f.Name(), 0o644); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	if err := os.Rename(w.f.Name(), w.path); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	return nil
+}
+
+func (w *localWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.aborted = true
+	return w.discard()
+}
+
+func (w *localWriter) discard() error {
+	w.f.Close()
+	return os.Remove(w.f.Name())
+}
+
+func (s *Local) List(ctx context.Context, prefix string) ([]string, error) {
+	// Walk the deepest directory the prefix names, then filter on the full prefix
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i]
+	}
+
+	var keys []string
+	err := filepath.WalkDir(s.path(dir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isTempFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *Local) ListPrefixes(ctx context.Context, dir string) ([]string, error) {
+	entries, err := os.ReadDir(s.path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Local) Stat(ctx context.Context, key string) (Attrs, error) {
+	info, err := os.Stat(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Attrs{}, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	if err != nil {
+		return Attrs{}, err
+	}
+	return Attrs{Key: key, Size: info.Size(), Updated: info.ModTime()}, nil
+}
+
+func (s *Local) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	return err
+}
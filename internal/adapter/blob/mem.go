@@ -0,0 +1,144 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Mem keeps objects in memory. It is meant for tests.
+type Mem struct {
+	mu      sync.RWMutex
+	objects map[string]memObject
+}
+
+type memObject struct {
+	data    []byte
+	updated time.Time
+}
+
+func NewMem() *Mem {
+	return &Mem{objects: make(map[string]memObject)}
+}
+
+func (s *Mem) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (s *Mem) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	return io.NopCloser(io.NewSectionReader(bytes.NewReader(obj.data), offset, length)), nil
+}
+
+// Create buffers writes and stores the object when the writer is closed.
+func (s *Mem) Create(ctx context.Context, key string) (Writer, error) {
+	return &memWriter{store: s, key: key}, nil
+}
+
+type memWriter struct {
+	store   *Mem
+	key     string
+	buf     bytes.Buffer
+	done    bool
+	aborted bool
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memWriter) Close() error {
+	if w.aborted {
+		return errAborted
+	}
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	w.store.objects[w.key] = memObject{data: bytes.Clone(w.buf.Bytes()), updated: time.Now()}
+	return nil
+}
+
+func (w *memWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.aborted = true
+	w.buf.Reset()
+	return nil
+}
+
+func (s *Mem) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *Mem) ListPrefixes(ctx context.Context, dir string) ([]string, error) {
+	prefix := dirPrefix(dir)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	var names []string
+	for key := range s.objects {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		name, _, isDir := strings.Cut(rest, "/")
+		if isDir && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Mem) Stat(ctx context.Context, key string) (Attrs, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return Attrs{}, fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	return Attrs{Key: key, Size: int64(len(obj.data)), Updated: obj.updated}, nil
+}
+
+func (s *Mem) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[key]; !ok {
+		return fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	delete(s.objects, key)
+	return nil
+}
@@ -0,0 +1,80 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ReaderAt reads an object through ranged reads, so a caller that only needs parts of a large
+// object, such as the row groups of a Parquet file it did not skip, fetches only those. The
+// object's last tailSize bytes, where formats like Parquet keep their footer, are fetched once
+// up front and served from memory.
+type ReaderAt struct {
+	ctx   context.Context
+	store Store
+	key   string
+	size  int64
+	tail  []byte
+}
+
+// NewReaderAt stats the object at key and prefetches its tail. ctx bounds every later read.
+func NewReaderAt(ctx context.Context, store Store, key string, tailSize int64) (*ReaderAt, error) {
+	attrs, err := store.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	r := &ReaderAt{ctx: ctx, store: store, key: key, size: attrs.Size}
+	if tailSize > r.size {
+		tailSize = r.size
+	}
+	if tailSize > 0 {
+		r.tail = make([]byte, tailSize)
+		if err := r.fetch(r.tail, r.size-tailSize); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Size returns the object's size in bytes.
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("blob: negative offset reading %s", r.key)
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if remaining := r.size - off; int64(n) > remaining {
+		n = int(remaining)
+	}
+
+	if tailStart := r.size - int64(len(r.tail)); off >= tailStart {
+		copy(p[:n], r.tail[off-tailStart:])
+	} else if err := r.fetch(p[:n], off); err != nil {
+		return 0, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch fills p with the object's bytes starting at off.
+func (r *ReaderAt) fetch(p []byte, off int64) error {
+	rc, err := r.store.OpenRange(r.ctx, r.key, off, int64(len(p)))
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if _, err := io.ReadFull(rc, p); err != nil {
+		return fmt.Errorf("blob: reading %d bytes of %s at %d: %w", len(p), r.key, off, err)
+	}
+	return nil
+}
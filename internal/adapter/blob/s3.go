@@ -0,0 +1,174 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// S3 stores objects in a bucket of any S3-compatible service (AWS S3, MinIO, Cloudflare R2)
+type S3 struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Client connects to an S3-compatible endpoint such as "s3.amazonaws.com" or "localhost:9000".
+// Empty keys fall back to the AWS environment variables and instance credentials.
+func NewS3Client(endpoint, region, accessKeyID, secretAccessKey string, useSSL bool) (*minio.Client, error) {
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.Static{Value: credentials.Value{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SignerType:      credentials.SignatureV4,
+		}},
+		&credentials.EnvAWS{},
+		&credentials.IAM{},
+	})
+	return minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Region: region,
+		Secure: useSSL,
+	})
+}
+
+func NewS3(client *minio.Client, bucket string) *S3 {
+	return &S3{client: client, bucket: bucket}
+}
+
+// notFound maps S3 "no such key" responses to domain.ErrNotFound.
+func notFound(err error, key string) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return fmt.Errorf("%w: %s", domain.ErrNotFound, key)
+	}
+	return err
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, notFound(err, key)
+	}
+	// GetObject is lazy; Stat surfaces a missing key before the caller starts reading
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, notFound(err, key)
+	}
+	return obj, nil
+}
+
+func (s *S3) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	var opts minio.GetObjectOptions
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, opts)
+	if err != nil {
+		return nil, notFound(err, key)
+	}
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, notFound(err, key)
+	}
+	return obj, nil
+}
+
+// Create streams writes to a single PutObject call, which completes when the writer is closed.
+// S3 only publishes an object once the upload succeeds; Abort fails the upload instead.
+func (s *S3) Create(ctx context.Context, key string) (Writer, error) {
+	pr, pw := io.Pipe()
+	w := &s3Writer{pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := s.client.PutObject(ctx, s.bucket, key, pr, -1, minio.PutObjectOptions{})
+		// Unblock any pending Write if the upload failed early
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+type s3Writer struct {
+	pw      *io.PipeWriter
+	done    chan error
+	closed  bool
+	aborted bool
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *s3Writer) Close() error {
+	if w.aborted {
+		return errAborted
+	}
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+func (w *s3Writer) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.aborted = true
+	w.pw.CloseWithError(errAborted)
+	<-w.done
+	return nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		keys = append(keys, obj.Key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *S3) ListPrefixes(ctx context.Context, dir string) ([]string, error) {
+	prefix := dirPrefix(dir)
+	var names []string
+	// Non-recursive listings return common prefixes as keys ending in "/"
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(obj.Key, prefix), "/"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Attrs, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return Attrs{}, notFound(err, key)
+	}
+	return Attrs{Key: key, Size: info.Size, Updated: info.LastModified}, nil
+}
+
+// Delete removes the object. S3 deletes succeed for missing keys, so existence is checked first.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
@@ -0,0 +1,48 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// TestS3Store runs the shared store checks against an S3-compatible server. Start one with
+// `docker compose --profile s3 up minio` and set MINIO_ENDPOINT=localhost:9000.
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINIO_ENDPOINT not set")
+	}
+	accessKey := os.Getenv("MINIO_ROOT_USER")
+	if accessKey == "" {
+		accessKey = "minioadmin"
+	}
+	secretKey := os.Getenv("MINIO_ROOT_PASSWORD")
+	if secretKey == "" {
+		secretKey = "minioadmin"
+	}
+
+	client, err := NewS3Client(endpoint, "us-east-1", accessKey, secretKey, false)
+	if err != nil {
+		t.Fatalf("NewS3Client failed: %v", err)
+	}
+
+	// A fresh bucket per run keeps results independent of earlier runs
+	ctx := context.Background()
+	bucket := fmt.Sprintf("ecfr-test-%d", time.Now().UnixNano())
+	if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatalf("MakeBucket failed: %v", err)
+	}
+	t.Cleanup(func() {
+		for obj := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+			_ = client.RemoveObject(ctx, bucket, obj.Key, minio.RemoveObjectOptions{})
+		}
+		_ = client.RemoveBucket(ctx, bucket)
+	})
+
+	testStore(t, NewS3(client, bucket))
+}
@@ -0,0 +1,76 @@
+// Package blob is the object storage shared by the adapters that persist files: snapshot
+// Parquet, raw GovInfo XML and Vertex batch input/output. Keys are "/"-separated paths
+// relative to the store's root (a bucket or a local directory).
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrConflict is returned by Writer.Close when the object was replaced by another writer
+// after Create was called.
+var ErrConflict = errors.New("blob: object changed while it was being written")
+
+// errAborted is returned by Writer.Close after Abort.
+var errAborted = errors.New("blob: write aborted")
+
+// Store reads and writes objects by key. Missing objects are reported with an error wrapping
+// domain.ErrNotFound.
+type Store interface {
+	// Open returns a reader for the object at key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// OpenRange returns a reader for length bytes of the object starting at offset. The
+	// range must lie within the object.
+	OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Create returns a writer that replaces the object at key. Writes are atomic: readers
+	// see the previous object (or none) until Close returns nil, and never a partial one.
+	Create(ctx context.Context, key string) (Writer, error)
+	// List returns the keys of every object whose key starts with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// ListPrefixes returns the names of the immediate "directories" under dir, sorted.
+	ListPrefixes(ctx context.Context, dir string) ([]string, error)
+	// Stat returns the object's attributes.
+	Stat(ctx context.Context, key string) (Attrs, error)
+	// Delete removes the object at key.
+	Delete(ctx context.Context, key string) error
+}
+
+// Writer is returned by Store.Create. Close publishes the object; Abort discards everything
+// written so far and leaves any existing object untouched. Abort after Close is a no-op, so
+// it can be deferred right after Create.
+type Writer interface {
+	io.WriteCloser
+	Abort() error
+}
+
+// Attrs describes a stored object
+type Attrs struct {
+	Key     string
+	Size    int64
+	Updated time.Time
+}
+
+// Join joins key segments with "/", skipping empty ones.
+func Join(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		p = strings.Trim(p, "/")
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, "/")
+}
+
+// dirPrefix turns a directory key into a listing prefix ending in "/" ("" for the root).
+func dirPrefix(dir string) string {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return ""
+	}
+	return dir + "/"
+}
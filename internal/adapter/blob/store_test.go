@@ -0,0 +1,205 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+func TestStores(t *testing.T) {
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	for name, store := range map[string]Store{"local": local, "mem": NewMem()} {
+		t.Run(name, func(t *testing.T) { testStore(t, store) })
+	}
+}
+
+// testStore exercises the behaviour every Store implementation must share.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	put := func(key, data string) {
+		t.Helper()
+		w, err := s.Create(ctx, key)
+		if err != nil {
+			t.Fatalf("Create(%s) failed: %v", key, err)
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			t.Fatalf("Write(%s) failed: %v", key, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close(%s) failed: %v", key, err)
+		}
+	}
+	put("parquet/2025-01-01/1.parquet", "one")
+	put("parquet/2025-01-01/manifest.json", "{}")
+	put("parquet/2025-01-02/1.parquet", "two")
+	put("parquet/published/2025-01-02.json", "{}")
+	put("raw/ECFR-title1.xml", "<xml/>")
+
+	rc, err := s.Open(ctx, "parquet/2025-01-02/1.parquet")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "two" {
+		t.Errorf("Open read %q, %v", data, err)
+	}
+
+	if _, err := s.Open(ctx, "parquet/missing"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound opening a missing key, got %v", err)
+	}
+
+	rc, err = s.OpenRange(ctx, "raw/ECFR-title1.xml", 1, 3)
+	if err != nil {
+		t.Fatalf("OpenRange failed: %v", err)
+	}
+	data, err = io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "xml" {
+		t.Errorf("OpenRange read %q, %v; want %q", data, err, "xml")
+	}
+	if _, err := s.OpenRange(ctx, "parquet/missing", 0, 1); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound opening a range of a missing key, got %v", err)
+	}
+
+	dirs, err := s.ListPrefixes(ctx, "parquet")
+	if err != nil {
+		t.Fatalf("ListPrefixes failed: %v", err)
+	}
+	if want := []string{"2025-01-01", "2025-01-02", "published"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("ListPrefixes = %v, want %v", dirs, want)
+	}
+	if dirs, err := s.ListPrefixes(ctx, "nothing"); err != nil || len(dirs) != 0 {
+		t.Errorf("Expected no prefixes under a missing dir, got %v, %v", dirs, err)
+	}
+
+	keys, err := s.List(ctx, "parquet/2025-01-01/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := []string{"parquet/2025-01-01/1.parquet", "parquet/2025-01-01/manifest.json"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List = %v, want %v", keys, want)
+	}
+	if keys, err := s.List(ctx, "raw/ECFR-"); err != nil || len(keys) != 1 {
+		t.Errorf("Expected List to match a partial name, got %v, %v", keys, err)
+	}
+
+	// An aborted write leaves the previous object in place
+	w, err := s.Create(ctx, "parquet/2025-01-02/1.parquet")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	io.WriteString(w, "partial")
+	if err := w.Abort(); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if err := w.Close(); err == nil {
+		t.Errorf("Expected Close after Abort to fail")
+	}
+	rc, err = s.Open(ctx, "parquet/2025-01-02/1.parquet")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, _ = io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "two" {
+		t.Errorf("Expected the aborted write to leave %q, got %q", "two", data)
+	}
+
+	// An unfinished write is invisible until Close
+	w, err = s.Create(ctx, "parquet/2025-01-03/1.parquet")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	io.WriteString(w, "three")
+	if _, err := s.Stat(ctx, "parquet/2025-01-03/1.parquet"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound before Close, got %v", err)
+	}
+	if keys, err := s.List(ctx, "parquet/2025-01-03/"); err != nil || len(keys) != 0 {
+		t.Errorf("Expected no keys listed before Close, got %v, %v", keys, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Abort(); err != nil {
+		t.Errorf("Expected Abort after Close to be a no-op, got %v", err)
+	}
+	if _, err := s.Stat(ctx, "parquet/2025-01-03/1.parquet"); err != nil {
+		t.Errorf("Expected the object after Close, got %v", err)
+	}
+
+	attrs, err := s.Stat(ctx, "raw/ECFR-title1.xml")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if attrs.Size != 6 {
+		t.Errorf("Stat size = %d, want 6", attrs.Size)
+	}
+
+	if err := s.Delete(ctx, "raw/ECFR-title1.xml"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Stat(ctx, "raw/ECFR-title1.xml"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := s.Delete(ctx, "raw/ECFR-title1.xml"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+// rangeCounter counts the ranged reads made through a store.
+type rangeCounter struct {
+	Store
+	ranges int
+}
+
+func (c *rangeCounter) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	c.ranges++
+	return c.Store.OpenRange(ctx, key, offset, length)
+}
+
+func TestReaderAt(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMem()
+	w, _ := mem.Create(ctx, "obj")
+	io.WriteString(w, "0123456789")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	store := &rangeCounter{Store: mem}
+
+	r, err := NewReaderAt(ctx, store, "obj", 4)
+	if err != nil {
+		t.Fatalf("NewReaderAt failed: %v", err)
+	}
+	if r.Size() != 10 || store.ranges != 1 {
+		t.Fatalf("size = %d after %d ranged reads, want 10 after prefetching the tail", r.Size(), store.ranges)
+	}
+
+	// Reads inside the tail are served from memory
+	buf := make([]byte, 3)
+	if n, err := r.ReadAt(buf, 7); n != 3 || err != nil || string(buf) != "789" || store.ranges != 1 {
+		t.Errorf("ReadAt(7) = %d, %v, %q after %d ranged reads", n, err, buf, store.ranges)
+	}
+	if n, err := r.ReadAt(buf, 2); n != 3 || err != nil || string(buf) != "234" || store.ranges != 2 {
+		t.Errorf("ReadAt(2) = %d, %v, %q after %d ranged reads", n, err, buf, store.ranges)
+	}
+	if n, err := r.ReadAt(buf, 8); n != 2 || err != io.EOF || string(buf[:n]) != "89" {
+		t.Errorf("ReadAt(8) = %d, %v, %q; want a short read and io.EOF", n, err, buf[:n])
+	}
+	if n, err := r.ReadAt(buf, 10); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt(10) = %d, %v; want io.EOF", n, err)
+	}
+
+	if _, err := NewReaderAt(ctx, store, "missing", 4); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing object, got %v", err)
+	}
+}
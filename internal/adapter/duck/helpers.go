@@ -6,7 +6,6 @@ import (
 	_ "github.com/duckdb/duckdb-go/v2"
 
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/parquet"
-	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
@@ -14,25 +13,27 @@ type Helper struct {
 	db *sql.DB
 }
 
-func NewHelper(parquet *parquet.Repo, sqlite *sqlite.Repo, enableUI bool) (*Helper, error) {
+func NewHelper(parquet *parquet.Repo, sqlitePath string, enableUI bool) (*Helper, error) {
 	db, err := sql.Open("duckdb", "")
 	if err != nil {
 		return nil, err
 	}
 
 	// Attach SQLite database for combined queries across SQLite and Parquet
-	if sqlite.Path != "" {
-		_, err = db.Exec("ATTACH '" + sqlite.Path + "' (TYPE sqlite);")
+	if sqlitePath != "" {
+		_, err = db.Exec("ATTACH '" + sqlitePath + "' (TYPE sqlite);")
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// Create a view for Parquet data if files exist (ignore errors if no files yet)
-	// This allows analysts to query sections_parquet directly
+	// This allows analysts to query sections_parquet directly. Section files are named after
+	// their title number; diffs and snapshot-wide files are left out. Section text is in the
+	// attached SQLite database's section_texts table.
 	_, _ = db.Exec(`
 		CREATE OR REPLACE VIEW sections_parquet AS
-		SELECT * FROM read_parquet('data/parquet/*/*.parquet')
+		SELECT * FROM read_parquet(['data/parquet/*/[0-9].parquet', 'data/parquet/*/[0-9][0-9].parquet'], union_by_name = true)
 	`)
 
 	// Start DuckDB web UI on port 4213 for interactive analytics
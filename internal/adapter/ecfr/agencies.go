@@ -0,0 +1,180 @@
+package ecfr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// AgencyRef represents a CFR reference from ecfr_agencies.json. Most name a chapter; some name
+// a whole subtitle, or narrow a chapter to one subchapter or part.
+type AgencyRef struct {
+	Title      int    `json:"title"`
+	Subtitle   string `json:"subtitle"`
+	Chapter    string `json:"chapter"`
+	Subchapter string `json:"subchapter"`
+	Part       string `json:"part"`
+}
+
+// Agency represents an agency from ecfr_agencies.json
+type Agency struct {
+	Name         string      `json:"name"`
+	ShortName    string      `json:"short_name"`
+	DisplayName  string      `json:"display_name"`
+	SortableName string      `json:"sortable_name"`
+	Slug         string      `json:"slug"`
+	Children     []Agency    `json:"children"`
+	CFRRefs      []AgencyRef `json:"cfr_references"`
+}
+
+// AgenciesRoot is the root structure of ecfr_agencies.json
+type AgenciesRoot struct {
+	Agencies []Agency `json:"agencies"`
+}
+
+// LoadAgencies reads the agency hierarchy saved from the eCFR agencies endpoint.
+func LoadAgencies(jsonPath string) ([]Agency, error) {
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var root AgenciesRoot
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	return root.Agencies, nil
+}
+
+// Label returns the name the agency is shown under: DisplayName if available, otherwise Name.
+func (a Agency) Label() string {
+	if a.DisplayName != "" {
+		return a.DisplayName
+	}
+	return a.Name
+}
+
+// AgencyEntry is one agency of the hierarchy without its children, as stored in the agencies
+// table. ParentID is empty for top-level agencies.
+type AgencyEntry struct {
+	ID           string
+	Name         string
+	ShortName    string
+	SortableName string
+	ParentID     string
+	CFRRefs      []AgencyRef
+}
+
+// Flatten lists every agency in the hierarchy, each parent before its children.
+func Flatten(agencies []Agency) []AgencyEntry {
+	var entries []AgencyEntry
+	var walk func(a Agency, parentID string)
+	walk = func(a Agency, parentID string) {
+		entries = append(entries, AgencyEntry{
+			ID:           a.Slug,
+			Name:         a.Label(),
+			ShortName:    a.ShortName,
+			SortableName: a.SortableName,
+			ParentID:     parentID,
+			CFRRefs:      a.CFRRefs,
+		})
+		for _, child := range a.Children {
+			walk(child, a.Slug)
+		}
+	}
+	for _, a := range agencies {
+		walk(a, "")
+	}
+	return entries
+}
+
+// DiffAgencies lists the changes that turn the current agencies into next: additions, renames
+// and re-parentings in the order of next, then removals by ID. Only Name and ParentID are
+// compared. An empty next, which would remove every agency, or one with a missing or repeated
+// slug is domain.ErrInvalidData.
+func DiffAgencies(current, next []AgencyEntry, at time.Time) ([]domain.AgencyChange, error) {
+	if len(next) == 0 {
+		return nil, fmt.Errorf("%w: the agency list is empty", domain.ErrInvalidData)
+	}
+
+	before := make(map[string]AgencyEntry, len(current))
+	for _, a := range current {
+		before[a.ID] = a
+	}
+
+	var changes []domain.AgencyChange
+	seen := make(map[string]bool, len(next))
+	for _, a := range next {
+		if a.ID == "" {
+			return nil, fmt.Errorf("%w: agency %q has no slug", domain.ErrInvalidData, a.Name)
+		}
+		if seen[a.ID] {
+			return nil, fmt.Errorf("%w: agency %q is listed twice", domain.ErrInvalidData, a.ID)
+		}
+		seen[a.ID] = true
+		old, ok := before[a.ID]
+		if !ok {
+			changes = append(changes, domain.AgencyChange{AgencyID: a.ID, Kind: domain.AgencyAdded, NewValue: a.Name, ChangedAt: at})
+			continue
+		}
+		if old.Name != a.Name {
+			changes = append(changes, domain.AgencyChange{AgencyID: a.ID, Kind: domain.AgencyRenamed, OldValue: old.Name, NewValue: a.Name, ChangedAt: at})
+		}
+		if old.ParentID != a.ParentID {
+			changes = append(changes, domain.AgencyChange{AgencyID: a.ID, Kind: domain.AgencyReparented, OldValue: old.ParentID, NewValue: a.ParentID, ChangedAt: at})
+		}
+	}
+
+	var removed []domain.AgencyChange
+	for _, a := range current {
+		if !seen[a.ID] {
+			removed = append(removed, domain.AgencyChange{AgencyID: a.ID, Kind: domain.AgencyRemoved, OldValue: a.Name, ChangedAt: at})
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].AgencyID < removed[j].AgencyID })
+	return append(changes, removed...), nil
+}
+
+// partOverridesFile is the layout of the part ownership override file:
+//
+//	{"overrides": [{"title": "7", "part": "1924", "agency_id": "rural-housing-service"}]}
+type partOverridesFile struct {
+	Overrides []domain.PartOwnerOverride `json:"overrides"`
+}
+
+// LoadPartOverrides reads the part ownership overrides at jsonPath. A missing file means no
+// overrides.
+func LoadPartOverrides(jsonPath string) ([]domain.PartOwnerOverride, error) {
+	data, err := os.ReadFile(jsonPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file partOverridesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", domain.ErrInvalidData, jsonPath, err)
+	}
+	for i, o := range file.Overrides {
+		o.Title = strings.TrimSpace(o.Title)
+		o.Part = strings.TrimSpace(o.Part)
+		o.AgencyID = strings.TrimSpace(o.AgencyID)
+		if n, err := strconv.Atoi(o.Title); err != nil || n < 1 || n > 50 {
+			return nil, fmt.Errorf("%w: %s: override %d has title %q", domain.ErrInvalidData, jsonPath, i, o.Title)
+		}
+		if o.Part == "" || o.AgencyID == "" {
+			return nil, fmt.Errorf("%w: %s: override %d needs a part and an agency_id", domain.ErrInvalidData, jsonPath, i)
+		}
+		file.Overrides[i] = o
+	}
+	return file.Overrides, nil
+}
@@ -1,34 +1,116 @@
 package ecfr
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
 type Client struct {
-	baseURL string
-	client  *http.Client
+	baseURL      string
+	adminURL     string
+	versionerURL string
+	client       *http.Client
 }
 
 func NewClient() *Client {
 	return &Client{
-		baseURL: "https://www.ecfr.gov/api/renderer/v1",
-		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL:      "https://www.ecfr.gov/api/renderer/v1",
+		adminURL:     "https://www.ecfr.gov/api/admin/v1",
+		versionerURL: "https://www.ecfr.gov/api/versioner/v1",
+		client:       &http.Client{Timeout: 30 * time.Second},
 	}
 }
 
-func (c *Client) GetTitles() ([]domain.Title, error) {
-	resp, err := c.client.Get(c.baseURL + "/titles")
+// GetAgencies fetches the agency hierarchy from the eCFR admin API, in the same layout as
+// ecfr_agencies.json.
+func (c *Client) GetAgencies(ctx context.Context) ([]Agency, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.adminURL+"/agencies.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	var titles []domain.Title
-	err = json.NewDecoder(resp.Body).Decode(&titles)
-	return titles, err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("eCFR agencies request failed: %s", resp.Status)
+	}
+
+	var root AgenciesRoot
+	if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return nil, err
+	}
+	return root.Agencies, nil
+}
+
+// GetTitles fetches the title catalog from the eCFR versioner API: every title number with its
+// name, amendment and issue dates, and whether it is reserved.
+func (c *Client) GetTitles(ctx context.Context) ([]domain.Title, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.versionerURL+"/titles.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("eCFR titles request failed: %s", resp.Status)
+	}
+
+	var root titlesRoot
+	if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return nil, err
+	}
+	return root.domainTitles()
+}
+
+type titlesRoot struct {
+	Titles []struct {
+		Number          int    `json:"number"`
+		Name            string `json:"name"`
+		LatestAmendedOn string `json:"latest_amended_on"`
+		LatestIssueDate string `json:"latest_issue_date"`
+		UpToDateAsOf    string `json:"up_to_date_as_of"`
+		Reserved        bool   `json:"reserved"`
+	} `json:"titles"`
+}
+
+// domainTitles converts the catalog, leaving dates the eCFR omits (null for reserved titles) zero
+func (r titlesRoot) domainTitles() ([]domain.Title, error) {
+	titles := make([]domain.Title, 0, len(r.Titles))
+	for _, t := range r.Titles {
+		title := domain.Title{Title: strconv.Itoa(t.Number), Name: t.Name, Reserved: t.Reserved}
+		for _, d := range []struct {
+			value string
+			dst   *time.Time
+		}{
+			{t.LatestAmendedOn, &title.LatestAmendedOn},
+			{t.LatestIssueDate, &title.LatestIssueDate},
+			{t.UpToDateAsOf, &title.UpToDateAsOf},
+		} {
+			if d.value == "" {
+				continue
+			}
+			parsed, err := time.Parse("2006-01-02", d.value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: title %d date %q", domain.ErrInvalidData, t.Number, d.value)
+			}
+			*d.dst = parsed
+		}
+		titles = append(titles, title)
+	}
+	return titles, nil
 }
 
 func (c *Client) GetSectionsForTitle(title string) ([]domain.RawSection, error) {
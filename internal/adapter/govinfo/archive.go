@@ -0,0 +1,192 @@
+package govinfo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Raw XML is archived under <rawPrefix>/archive: every distinct document once, zstd-compressed
+// and addressed by the SHA-256 of the uncompressed XML, with one index entry per fetch under
+// archive/index/title-<n>/. The archive is never rewritten, so any snapshot can be re-parsed
+// from the exact XML it was built from.
+const (
+	archiveDir    = "archive"
+	archiveSuffix = ".xml.zst"
+
+	// fetchKeyLayout names index entries so that they sort by fetch time
+	fetchKeyLayout = "2006-01-02T150405.000000000Z"
+)
+
+// ArchivedXMLPath returns where the XML with the given SHA-256 is archived.
+func (c *Client) ArchivedXMLPath(hash string) string {
+	return c.objectPath(path.Join(archiveDir, hash[:2], hash+archiveSuffix))
+}
+
+func (c *Client) fetchIndexDir(title int) string {
+	return c.objectPath(path.Join(archiveDir, "index", fmt.Sprintf("title-%d", title)))
+}
+
+// ArchiveHistory returns every recorded fetch of a title, oldest first.
+func (c *Client) ArchiveHistory(ctx context.Context, title int) ([]domain.RawXMLFetch, error) {
+	keys, err := c.rawStore.List(ctx, c.fetchIndexDir(title)+"/")
+	if err != nil {
+		return nil, err
+	}
+	fetches := make([]domain.RawXMLFetch, 0, len(keys))
+	for _, key := range keys {
+		f, err := c.readFetch(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		fetches = append(fetches, *f)
+	}
+	sort.SliceStable(fetches, func(i, j int) bool { return fetches[i].FetchedAt.Before(fetches[j].FetchedAt) })
+	return fetches, nil
+}
+
+// latestFetch returns the newest recorded fetch of a title, or domain.ErrNotFound. Index keys
+// sort by fetch time, so only the last one listed is read.
+func (c *Client) latestFetch(ctx context.Context, title int) (*domain.RawXMLFetch, error) {
+	keys, err := c.rawStore.List(ctx, c.fetchIndexDir(title)+"/")
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return c.readFetch(ctx, keys[len(keys)-1])
+}
+
+func (c *Client) readFetch(ctx context.Context, key string) (*domain.RawXMLFetch, error) {
+	rc, err := c.rawStore.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var f domain.RawXMLFetch
+	if err := json.NewDecoder(rc).Decode(&f); err != nil {
+		return nil, fmt.Errorf("%w: fetch index %s: %v", domain.ErrInvalidData, key, err)
+	}
+	return &f, nil
+}
+
+func (c *Client) recordFetch(ctx context.Context, f domain.RawXMLFetch) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	key := c.fetchIndexDir(f.Title) + "/" + f.FetchedAt.UTC().Format(fetchKeyLayout) + ".json"
+	w, err := c.rawStore.Create(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// archive stores the XML read from r unless an identical document is already archived, and
+// returns its SHA-256 and uncompressed size.
+func (c *Client) archive(ctx context.Context, r io.Reader) (string, int64, error) {
+	// Spool to disk: the hash, and so the key, is only known once the whole document is read
+	tmp, err := os.CreateTemp("", "ecfr-xml-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return "", 0, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	key := c.ArchivedXMLPath(hash)
+	if _, err := c.rawStore.Stat(ctx, key); err == nil {
+		return hash, size, nil
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return "", 0, err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	w, err := c.rawStore.Create(ctx, key)
+	if err != nil {
+		return "", 0, fmt.Errorf("creating XML object %q: %w", key, err)
+	}
+	// A failed upload leaves no partial object behind
+	defer w.Abort()
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := io.Copy(enc, tmp); err != nil {
+		enc.Close()
+		return "", 0, fmt.Errorf("writing XML object %q: %w", key, err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := w.Close(); err != nil {
+		return "", 0, fmt.Errorf("closing XML writer for %q: %w", key, err)
+	}
+	return hash, size, nil
+}
+
+// importLegacy archives the uncompressed ECFR-title<n>.xml written before the archive existed,
+// recording it as fetched when it was stored. It returns domain.ErrNotFound if there is none.
+func (c *Client) importLegacy(ctx context.Context, title int) (*domain.RawXMLFetch, error) {
+	key := c.objectPath(titleXMLName(title))
+	attrs, err := c.rawStore.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := c.rawStore.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	hash, size, err := c.archive(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+	f := domain.RawXMLFetch{Title: title, FetchedAt: attrs.Updated.UTC(), SHA256: hash, Bytes: size, SourceURL: key}
+	if err := c.recordFetch(ctx, f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ParseArchivedXML parses the archived XML with the given SHA-256, for example the source
+// recorded in a snapshot manifest.
+func (c *Client) ParseArchivedXML(ctx context.Context, hash string) ([]domain.Section, error) {
+	if len(hash) != sha256.Size*2 {
+		return nil, fmt.Errorf("%w: XML hash %q", domain.ErrInvalidData, hash)
+	}
+	return c.ParseTitleXML(ctx, c.ArchivedXMLPath(hash))
+}
+
+// archivedHash returns the hash an archive path is addressed by, if it is one.
+func archivedHash(objPath string) (string, bool) {
+	hash, ok := strings.CutSuffix(path.Base(objPath), archiveSuffix)
+	return hash, ok && len(hash) == sha256.Size*2
+}
@@ -2,7 +2,10 @@ package govinfo
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,17 +14,19 @@ import (
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/klauspost/compress/zstd"
 
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
 type Client struct {
 	baseURL string
+	bulkURL string
 	client  *http.Client
 
-	gcsClient     *storage.Client
-	rawBucketName string
-	rawPrefix     string
+	rawStore  blob.Store
+	rawPrefix string
 }
 
 func NewClient(ctx context.Context, rawBucketName, rawPrefix string) (*Client, error) {
@@ -29,13 +34,18 @@ func NewClient(ctx context.Context, rawBucketName, rawPrefix string) (*Client, e
 	if err != nil {
 		return nil, err
 	}
+	return NewStoreClient(blob.NewGCS(gcs, rawBucketName), rawPrefix), nil
+}
+
+// NewStoreClient keeps downloaded XML under rawPrefix in any blob store.
+func NewStoreClient(rawStore blob.Store, rawPrefix string) *Client {
 	return &Client{
-		baseURL:       "https://www.govinfo.gov/bulkdata/json/ECFR",
-		client:        &http.Client{Timeout: 10 * time.Minute},
-		gcsClient:     gcs,
-		rawBucketName: rawBucketName,
-		rawPrefix:     rawPrefix,
-	}, nil
+		baseURL:   "https://www.govinfo.gov/bulkdata/json/ECFR",
+		bulkURL:   "https://www.govinfo.gov/bulkdata/ECFR",
+		client:    &http.Client{Timeout: 10 * time.Minute},
+		rawStore:  rawStore,
+		rawPrefix: rawPrefix,
+	}
 }
 
 func (c *Client) objectPath(xmlName string) string {
@@ -45,64 +55,121 @@ func (c *Client) objectPath(xmlName string) string {
 	return path.Join(c.rawPrefix, xmlName)
 }
 
-// DownloadTitleXML downloads the latest XML for a given title into GCS.
+func titleXMLName(title int) string {
+	return fmt.Sprintf("ECFR-title%d.xml", title)
+}
+
+// ChecksumTitleXML returns the hex SHA-256 of the XML most recently fetched for a title.
+func (c *Client) ChecksumTitleXML(ctx context.Context, title int) (string, error) {
+	f, err := c.latestFetch(ctx, title)
+	if err != nil {
+		return "", err
+	}
+	return f.SHA256, nil
+}
+
+// DownloadTitleXML fetches the latest XML for a given title into the raw archive and returns
+// its archived path. Every fetch is recorded in the archive index; GovInfo is asked only for
+// XML modified since the last fetch, and unchanged documents are not stored again.
 func (c *Client) DownloadTitleXML(ctx context.Context, title int) (string, error) {
 	// NOTE: The GovInfo Bulk Data JSON API is currently returning 404s.
 	// We fallback to using the predictable XML paths directly.
 	// Pattern: https://www.govinfo.gov/bulkdata/ECFR/title-{title}/ECFR-title{title}.xml
 
-	xmlName := fmt.Sprintf("ECFR-title%d.xml", title)
-	xmlLink := fmt.Sprintf("https://www.govinfo.gov/bulkdata/ECFR/title-%d/%s", title, xmlName)
-
-	// Step 5: Download file to GCS
-	objPath := c.objectPath(xmlName)
-	obj := c.gcsClient.Bucket(c.rawBucketName).Object(objPath)
+	xmlName := titleXMLName(title)
+	xmlLink := fmt.Sprintf("%s/title-%d/%s", c.bulkURL, title, xmlName)
 
-	// Check if already exists
-	if _, err := obj.Attrs(ctx); err == nil {
-		return objPath, nil
+	// Copies downloaded before the archive existed are archived first
+	latest, err := c.latestFetch(ctx, title)
+	if errors.Is(err, domain.ErrNotFound) {
+		latest, err = c.importLegacy(ctx, title)
+	}
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return "", err
 	}
 
-	resp, err := c.client.Get(xmlLink)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, xmlLink, nil)
+	if err != nil {
+		return "", err
+	}
+	if latest != nil {
+		since := latest.LastModified
+		if since == "" {
+			since = latest.FetchedAt.UTC().Format(http.TimeFormat)
+		}
+		req.Header.Set("If-Modified-Since", since)
+	}
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		// Read body for error details
-		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-
+	fetch := domain.RawXMLFetch{
+		Title:        title,
+		FetchedAt:    time.Now().UTC(),
+		SourceURL:    xmlLink,
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && latest != nil:
+		fetch.SHA256, fetch.Bytes = latest.SHA256, latest.Bytes
+		if fetch.LastModified == "" {
+			fetch.LastModified = latest.LastModified
+		}
+	case resp.StatusCode == http.StatusOK:
+		fetch.SHA256, fetch.Bytes, err = c.archive(ctx, resp.Body)
+		if err != nil {
+			return "", err
+		}
+	case resp.StatusCode == http.StatusNotFound:
 		// If 404, it might be a missing/reserved title (not all 1-50 exist).
 		// We return a specific error that the caller can check to skip gracefully.
-		if resp.StatusCode == http.StatusNotFound {
-			return "", domain.ErrNotFound
-		}
-
+		return "", domain.ErrNotFound
+	default:
+		// Read body for error details
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
 		return "", fmt.Errorf("failed to download XML from %s: status %s, body: %q", xmlLink, resp.Status, string(bodyBytes))
 	}
 
-	w := obj.NewWriter(ctx)
-	if _, err := io.Copy(w, resp.Body); err != nil {
-		_ = w.Close()
-		return "", fmt.Errorf("writing XML to GCS object %q: %w", objPath, err)
-	}
-	if err := w.Close(); err != nil {
-		return "", fmt.Errorf("closing GCS writer for %q: %w", objPath, err)
+	if err := c.recordFetch(ctx, fetch); err != nil {
+		return "", err
 	}
-
-	return objPath, nil
+	return c.ArchivedXMLPath(fetch.SHA256), nil
 }
 
-// ParseTitleXML reads XML from GCS instead of local disk.
+// ParseTitleXML reads XML from the raw store. Archived XML is decompressed and checked against
+// the hash it is addressed by.
 func (c *Client) ParseTitleXML(ctx context.Context, objPath string) ([]domain.Section, error) {
-	rc, err := c.gcsClient.Bucket(c.rawBucketName).Object(objPath).NewReader(ctx)
+	rc, err := c.rawStore.Open(ctx, objPath)
 	if err != nil {
 		return nil, err
 	}
 	defer rc.Close()
 
-	return c.parseXML(rc)
+	hash, archived := archivedHash(objPath)
+	if !archived {
+		return c.parseXML(rc)
+	}
+
+	zr, err := zstd.NewReader(rc)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	h := sha256.New()
+	sections, err := c.parseXML(io.TeeReader(zr, h))
+	if err != nil {
+		return nil, err
+	}
+	// parseXML stops at the last token; hash whatever trails it too
+	if _, err := io.Copy(h, zr); err != nil {
+		return nil, err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != hash {
+		return nil, fmt.Errorf("%w: %s has sha256 %s", domain.ErrChecksumMismatch, objPath, got)
+	}
+	return sections, nil
 }
 
 func (c *Client) parseXML(r io.Reader) ([]domain.Section, error) {
@@ -112,7 +179,11 @@ func (c *Client) parseXML(r io.Reader) ([]domain.Section, error) {
 	var currentText strings.Builder
 	var inSection bool
 	var sectionID string
-	var currentAgencyID string
+	var heading strings.Builder
+	var inHeading, headingDone bool
+	// The TYPE of each open DIV, and the N of the open SUBTITLE, CHAPTER, SUBCHAP and PART
+	var divTypes []string
+	hierarchy := make(map[string]string)
 
 	for {
 		t, err := decoder.Token()
@@ -125,28 +196,55 @@ func (c *Client) parseXML(r io.Reader) ([]domain.Section, error) {
 
 		switch se := t.(type) {
 		case xml.StartElement:
-			if getAttr(se, "TYPE") == "CHAPTER" {
-				currentAgencyID = getAttr(se, "N")
+			if strings.HasPrefix(se.Name.Local, "DIV") {
+				typ := getAttr(se, "TYPE")
+				divTypes = append(divTypes, typ)
+				switch typ {
+				case "SUBTITLE", "CHAPTER", "SUBCHAP", "PART":
+					hierarchy[typ] = getAttr(se, "N")
+				}
 			}
 			if se.Name.Local == "DIV8" {
 				inSection = true
 				sectionID = getAttr(se, "N")
 				currentText.Reset()
+				heading.Reset()
+				headingDone = false
+			}
+			// The first HEAD in a section is its own heading
+			if inSection && se.Name.Local == "HEAD" && !headingDone {
+				inHeading = true
 			}
 		case xml.CharData:
 			if inSection {
 				currentText.Write(se)
 			}
+			if inHeading {
+				heading.Write(se)
+			}
 		case xml.EndElement:
+			if inHeading && se.Name.Local == "HEAD" {
+				inHeading = false
+				headingDone = true
+			}
 			if se.Name.Local == "DIV8" {
 				inSection = false
 				sections = append(sections, domain.Section{
-					ID:       sectionID,
-					Section:  sectionID,
-					AgencyID: currentAgencyID,
-					Text:     currentText.String(),
+					ID:         sectionID,
+					Part:       hierarchy["PART"],
+					Section:    sectionID,
+					Heading:    strings.TrimSpace(heading.String()),
+					AgencyID:   hierarchy["CHAPTER"],
+					Subtitle:   hierarchy["SUBTITLE"],
+					Subchapter: hierarchy["SUBCHAP"],
+					Text:       currentText.String(),
 				})
 			}
+			// Leaving a DIV leaves its level of the hierarchy
+			if strings.HasPrefix(se.Name.Local, "DIV") && len(divTypes) > 0 {
+				delete(hierarchy, divTypes[len(divTypes)-1])
+				divTypes = divTypes[:len(divTypes)-1]
+			}
 		}
 	}
 	return sections, nil
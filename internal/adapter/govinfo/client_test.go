@@ -1,9 +1,19 @@
 package govinfo
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
 func TestParseTitleXML_AgencyID(t *testing.T) {
@@ -66,6 +76,9 @@ func TestParseTitleXML_AgencyID(t *testing.T) {
 	if sections[0].ID != "§ 1.1" {
 		t.Errorf("Section 1: Expected ID '§ 1.1', got '%s'", sections[0].ID)
 	}
+	if sections[0].Heading != "§ 1.1 Test Section." {
+		t.Errorf("Section 1: Expected Heading '§ 1.1 Test Section.', got '%s'", sections[0].Heading)
+	}
 
 	// Check second section
 	if sections[1].AgencyID != "II" {
@@ -75,3 +88,192 @@ func TestParseTitleXML_AgencyID(t *testing.T) {
 		t.Errorf("Section 2: Expected ID '§ 2.1', got '%s'", sections[1].ID)
 	}
 }
+
+func TestParseTitleXML_Part(t *testing.T) {
+	xmlContent := `<?xml version="1.0" encoding="UTF-8" ?>
+<DLPSTEXTCLASS>
+<TEXT>
+<BODY>
+<DIV1 N="40" TYPE="TITLE">
+	<DIV3 N="I" TYPE="CHAPTER">
+		<DIV5 N="60" TYPE="PART">
+			<DIV8 N="§ 60.1" TYPE="SECTION"><P>Applicability.</P></DIV8>
+		</DIV5>
+		<DIV5 N="61" TYPE="PART">
+			<DIV8 N="§ 61.1" TYPE="SECTION"><P>Scope.</P></DIV8>
+		</DIV5>
+	</DIV3>
+</DIV1>
+</BODY>
+</TEXT>
+</DLPSTEXTCLASS>`
+
+	client := &Client{}
+	sections, err := client.parseXML(strings.NewReader(xmlContent))
+	if err != nil {
+		t.Fatalf("parseXML failed: %v", err)
+	}
+	if len(sections) != 2 {
+		t.Fatalf("Expected 2 sections, got %d", len(sections))
+	}
+	if sections[0].Part != "60" || sections[1].Part != "61" {
+		t.Errorf("Expected parts 60 and 61, got %q and %q", sections[0].Part, sections[1].Part)
+	}
+}
+
+func TestParseTitleXML_Hierarchy(t *testing.T) {
+	xmlContent := `<DIV1 N="7" TYPE="TITLE">
+	<DIV2 N="A" TYPE="SUBTITLE">
+		<DIV5 N="1" TYPE="PART"><DIV8 N="§ 1.1" TYPE="SECTION"><P>a</P></DIV8></DIV5>
+	</DIV2>
+	<DIV2 N="B" TYPE="SUBTITLE">
+		<DIV3 N="XVIII" TYPE="CHAPTER">
+			<DIV4 N="A" TYPE="SUBCHAP">
+				<DIV5 N="1900" TYPE="PART"><DIV8 N="§ 1900.1" TYPE="SECTION"><P>b</P></DIV8></DIV5>
+			</DIV4>
+			<DIV5 N="1980" TYPE="PART"><DIV8 N="§ 1980.1" TYPE="SECTION"><P>c</P></DIV8></DIV5>
+		</DIV3>
+	</DIV2>
+</DIV1>`
+
+	client := &Client{}
+	sections, err := client.parseXML(strings.NewReader(xmlContent))
+	if err != nil {
+		t.Fatalf("parseXML failed: %v", err)
+	}
+	if len(sections) != 3 {
+		t.Fatalf("Expected 3 sections, got %d", len(sections))
+	}
+	// Each level ends with its DIV, so later parts do not inherit it
+	want := [][4]string{{"A", "", "", "1"}, {"B", "XVIII", "A", "1900"}, {"B", "XVIII", "", "1980"}}
+	for i, s := range sections {
+		if got := [4]string{s.Subtitle, s.AgencyID, s.Subchapter, s.Part}; got != want[i] {
+			t.Errorf("Section %s: subtitle/chapter/subchapter/part = %q, want %q", s.ID, got, want[i])
+		}
+	}
+}
+
+func TestDownloadTitleXML_ImportsStoredCopy(t *testing.T) {
+	ctx := context.Background()
+	store := blob.NewMem()
+	w, _ := store.Create(ctx, "raw/ECFR-title7.xml")
+	io.WriteString(w, `<DIV1 N="7" TYPE="TITLE"><DIV8 N="§ 7.1" TYPE="SECTION"><P>Stored text</P></DIV8></DIV1>`)
+	w.Close()
+
+	// GovInfo has nothing newer than the stored copy
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == "" {
+			t.Errorf("Expected a conditional request")
+		}
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	c := NewStoreClient(store, "raw")
+	c.bulkURL = srv.URL
+	path, err := c.DownloadTitleXML(ctx, 7)
+	if err != nil {
+		t.Fatalf("DownloadTitleXML failed: %v", err)
+	}
+	if !strings.HasPrefix(path, "raw/archive/") || !strings.HasSuffix(path, ".xml.zst") {
+		t.Errorf("Expected an archived path, got %q", path)
+	}
+	sections, err := c.ParseTitleXML(ctx, path)
+	if err != nil {
+		t.Fatalf("ParseTitleXML failed: %v", err)
+	}
+	if len(sections) != 1 || sections[0].Text != "Stored text" {
+		t.Errorf("Unexpected sections: %+v", sections)
+	}
+
+	// The import and the unchanged fetch both point at the same document
+	history, err := c.ArchiveHistory(ctx, 7)
+	if err != nil {
+		t.Fatalf("ArchiveHistory failed: %v", err)
+	}
+	if len(history) != 2 || history[0].SHA256 != history[1].SHA256 {
+		t.Errorf("Expected two fetches of one document, got %+v", history)
+	}
+}
+
+func TestDownloadTitleXML_Archive(t *testing.T) {
+	ctx := context.Background()
+	store := blob.NewMem()
+	versions := []string{
+		`<DIV1><DIV8 N="§ 7.1" TYPE="SECTION"><P>First</P></DIV8></DIV1>`,
+		`<DIV1><DIV8 N="§ 7.1" TYPE="SECTION"><P>Second</P></DIV8></DIV1>`,
+		`<DIV1><DIV8 N="§ 7.1" TYPE="SECTION"><P>First</P></DIV8></DIV1>`,
+	}
+	served := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/title-7/ECFR-title7.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Last-Modified", fmt.Sprintf("Mon, 0%d Jan 2024 00:00:00 GMT", served+1))
+		io.WriteString(w, versions[served])
+		served++
+	}))
+	defer srv.Close()
+
+	c := NewStoreClient(store, "raw")
+	c.bulkURL = srv.URL
+	var paths []string
+	for range versions {
+		path, err := c.DownloadTitleXML(ctx, 7)
+		if err != nil {
+			t.Fatalf("DownloadTitleXML failed: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	if paths[0] != paths[2] || paths[0] == paths[1] {
+		t.Errorf("Expected identical documents to share a path, got %v", paths)
+	}
+
+	archived, err := store.List(ctx, "raw/archive/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var documents int
+	for _, key := range archived {
+		if strings.HasSuffix(key, ".xml.zst") {
+			documents++
+		}
+	}
+	if documents != 2 {
+		t.Errorf("Expected 2 distinct documents archived, got %v", archived)
+	}
+
+	history, err := c.ArchiveHistory(ctx, 7)
+	if err != nil {
+		t.Fatalf("ArchiveHistory failed: %v", err)
+	}
+	if len(history) != 3 || history[2].LastModified != "Mon, 03 Jan 2024 00:00:00 GMT" {
+		t.Errorf("Expected every fetch indexed, got %+v", history)
+	}
+	sum, err := c.ChecksumTitleXML(ctx, 7)
+	if err != nil || sum != history[2].SHA256 {
+		t.Errorf("ChecksumTitleXML = %q, %v", sum, err)
+	}
+
+	// An earlier fetch can still be re-parsed from its hash
+	sections, err := c.ParseArchivedXML(ctx, history[1].SHA256)
+	if err != nil {
+		t.Fatalf("ParseArchivedXML failed: %v", err)
+	}
+	if len(sections) != 1 || sections[0].Text != "Second" {
+		t.Errorf("Unexpected sections: %+v", sections)
+	}
+
+	// Only the newest index entry is read, so an unreadable older one is not noticed
+	w, _ := store.Create(ctx, "raw/archive/index/title-7/2000-01-01T000000.000000000Z.json")
+	io.WriteString(w, "not json")
+	w.Close()
+	if latest, err := c.latestFetch(ctx, 7); err != nil || latest.SHA256 != history[2].SHA256 {
+		t.Errorf("latestFetch = %+v, %v", latest, err)
+	}
+
+	if _, err := c.DownloadTitleXML(ctx, 99); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing title, got %v", err)
+	}
+}
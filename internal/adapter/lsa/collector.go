@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
@@ -29,6 +30,30 @@ type FederalRegisterDoc struct {
 	AgencyNames     []string `json:"agency_names"`
 }
 
+// FederalRegisterRule is a document from the documents endpoint requested with CFR reference fields
+type FederalRegisterRule struct {
+	DocumentNumber  string                  `json:"document_number"`
+	Type            string                  `json:"type"`
+	Title           string                  `json:"title"`
+	PublicationDate string                  `json:"publication_date"`
+	HTMLURL         string                  `json:"html_url"`
+	CFRReferences   []FederalRegisterCFRRef `json:"cfr_references"`
+}
+
+// FederalRegisterCFRRef is a CFR citation on a Federal Register document.
+// The API returns title as a number and part as either a number or a string.
+type FederalRegisterCFRRef struct {
+	Title json.Number `json:"title"`
+	Part  any         `json:"part"`
+}
+
+type federalRegisterRulesPage struct {
+	Count       int                   `json:"count"`
+	TotalPages  int                   `json:"total_pages"`
+	NextPageURL string                `json:"next_page_url"`
+	Results     []FederalRegisterRule `json:"results"`
+}
+
 type FederalRegisterFacets struct {
 	Agency map[string]int `json:"agency"`
 }
@@ -67,7 +92,7 @@ func (c *Collector) CollectAgencyLSA(ctx context.Context, agencies []string) ([]
 	// Reset cache
 	c.agencyLSAData = make(map[string]domain.AgencyLSA)
 
-	snapshotDate := time.Now().Format("2006-01-02")
+	snapshotDate := time.Now().UTC().Format("2006-01-02")
 	capturedAt := time.Now()
 
 	// Calculate date range: last 30 days
@@ -121,7 +146,7 @@ func (c *Collector) CollectAgencyLSA(ctx context.Context, agencies []string) ([]
 // CollectAgencyLSABatch fetches LSA data for multiple agencies efficiently using faceted search.
 // This is more efficient than individual queries when collecting for many agencies.
 func (c *Collector) CollectAgencyLSABatch(ctx context.Context) ([]domain.AgencyLSA, error) {
-	snapshotDate := time.Now().Format("2006-01-02")
+	snapshotDate := time.Now().UTC().Format("2006-01-02")
 	capturedAt := time.Now()
 
 	// Calculate date range: last 30 days
@@ -311,3 +336,77 @@ func (c *Collector) GetAgencyLSA(agencySlug string) (domain.AgencyLSA, bool) {
 	lsa, ok := c.agencyLSAData[agencySlug]
 	return lsa, ok
 }
+
+// FetchFinalRules returns the final rules published between startDate and endDate (inclusive)
+// together with the CFR parts they cite. All result pages are followed.
+func (c *Collector) FetchFinalRules(ctx context.Context, startDate, endDate time.Time) ([]domain.FRDocument, error) {
+	params := url.Values{}
+	params.Set("conditions[type][]", "RULE")
+	params.Set("conditions[publication_date][gte]", startDate.Format("2006-01-02"))
+	params.Set("conditions[publication_date][lte]", endDate.Format("2006-01-02"))
+	params.Set("per_page", "1000")
+	params.Set("order", "oldest")
+	for _, f := range []string{"document_number", "type", "title", "publication_date", "html_url", "cfr_references"} {
+		params.Add("fields[]", f)
+	}
+
+	reqURL := "https://www.federalregister.gov/api/v1/documents.json?" + params.Encode()
+
+	var docs []domain.FRDocument
+	for reqURL != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var page federalRegisterRulesPage
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("federal register documents API returned %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range page.Results {
+			docs = append(docs, r.toDomain())
+		}
+		reqURL = page.NextPageURL
+	}
+
+	return docs, nil
+}
+
+func (r FederalRegisterRule) toDomain() domain.FRDocument {
+	doc := domain.FRDocument{
+		DocumentNumber: r.DocumentNumber,
+		Type:           r.Type,
+		Title:          r.Title,
+		HTMLURL:        r.HTMLURL,
+	}
+	if t, err := time.Parse("2006-01-02", r.PublicationDate); err == nil {
+		doc.PublicationDate = t
+	}
+	for _, ref := range r.CFRReferences {
+		part := ""
+		switch p := ref.Part.(type) {
+		case string:
+			part = p
+		case float64:
+			part = strconv.FormatFloat(p, 'f', -1, 64)
+		}
+		if ref.Title == "" || part == "" {
+			continue
+		}
+		doc.CFRReferences = append(doc.CFRReferences, domain.CFRReference{Title: ref.Title.String(), Part: part})
+	}
+	return doc
+}
@@ -0,0 +1,49 @@
+package lsa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// agencyOverridesFile is the layout of the Federal Register agency override file:
+//
+//	{"overrides": [{"fr_slug": "antitrust-division", "agency_id": "justice-department"}]}
+type agencyOverridesFile struct {
+	Overrides []domain.FRAgencyOverride `json:"overrides"`
+}
+
+// LoadAgencyOverrides reads the Federal Register to eCFR agency overrides at jsonPath. A missing
+// file means no overrides.
+func LoadAgencyOverrides(jsonPath string) ([]domain.FRAgencyOverride, error) {
+	data, err := os.ReadFile(jsonPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file agencyOverridesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", domain.ErrInvalidData, jsonPath, err)
+	}
+	seen := make(map[string]bool, len(file.Overrides))
+	for i, o := range file.Overrides {
+		o.FRSlug = strings.TrimSpace(o.FRSlug)
+		o.AgencyID = strings.TrimSpace(o.AgencyID)
+		if o.FRSlug == "" {
+			return nil, fmt.Errorf("%w: %s: override %d needs an fr_slug", domain.ErrInvalidData, jsonPath, i)
+		}
+		if seen[o.FRSlug] {
+			return nil, fmt.Errorf("%w: %s: %q is overridden twice", domain.ErrInvalidData, jsonPath, o.FRSlug)
+		}
+		seen[o.FRSlug] = true
+		file.Overrides[i] = o
+	}
+	return file.Overrides, nil
+}
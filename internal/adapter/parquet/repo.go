@@ -3,26 +3,30 @@ package parquet
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/parquet-go/parquet-go"
-	"google.golang.org/api/iterator"
 
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
 type Repo struct {
-	client     *storage.Client
-	bucketName string
+	store      blob.Store
 	rootPrefix string
-
-	// Local mode
-	localDir string // if non-empty, use filesystem backend
+	texts      *TextStore
 }
 
 func NewRepo(ctx context.Context, bucketName, rootPrefix string) (*Repo, error) {
@@ -30,270 +34,437 @@ func NewRepo(ctx context.Context, bucketName, rootPrefix string) (*Repo, error)
 	if err != nil {
 		return nil, err
 	}
-	return &Repo{
-		client:     c,
-		bucketName: bucketName,
-		rootPrefix: rootPrefix,
-	}, nil
+	return NewStoreRepo(blob.NewGCS(c, bucketName), rootPrefix), nil
 }
 
 func NewLocalRepo(rootDir, rootPrefix string) (*Repo, error) {
-	// Ensure base directory exists
-	if err := os.MkdirAll(filepath.Join(rootDir, rootPrefix), 0o755); err != nil {
+	store, err := blob.NewLocal(rootDir)
+	if err != nil {
 		return nil, err
 	}
-	return &Repo{
-		localDir:   rootDir,
-		rootPrefix: rootPrefix,
-	}, nil
+	return NewStoreRepo(store, rootPrefix), nil
+}
+
+// NewStoreRepo keeps snapshots under rootPrefix in any blob store, with section text shared
+// between snapshots under <rootPrefix>/texts.
+func NewStoreRepo(store blob.Store, rootPrefix string) *Repo {
+	return &Repo{store: store, rootPrefix: rootPrefix, texts: NewTextStore(store, blob.Join(rootPrefix, "texts"))}
+}
+
+// ReadSectionText returns the section text whose SHA-256 is hash, from any snapshot.
+func (r *Repo) ReadSectionText(ctx context.Context, hash string) (string, error) {
+	return r.texts.Get(ctx, hash)
 }
 
 func (r *Repo) objectPath(parts ...string) string {
-	// join with "/": <root>/<snapshot>/<file>
-	p := r.rootPrefix
-	for _, part := range parts {
-		if p == "" {
-			p = part
-		} else {
-			p = p + "/" + part
-		}
-	}
-	return p
+	// <root>/<snapshot>/<file>
+	return blob.Join(append([]string{r.rootPrefix}, parts...)...)
+}
+
+// sidecarKey is where the hex SHA-256 of a snapshot file is kept, in sha256sum format
+func sidecarKey(key string) string {
+	return key + ".sha256"
 }
 
-func (r *Repo) localPath(parts ...string) string {
-	// <localDir>/<rootPrefix>/<snapshot>/<file>
-	rel := r.objectPath(parts...)
-	if r.localDir == "" {
-		return rel
+// writeRows writes rows as a zstd-compressed Parquet file at key, with its checksum sidecar.
+func writeRows[T any](ctx context.Context, store blob.Store, key string, rows []T, options ...parquet.WriterOption) error {
+	// Encode in memory so the sidecar can be written before the data: a crash in between
+	// leaves data that fails verification, never unverified data that passes it.
+	var buf bytes.Buffer
+	options = append([]parquet.WriterOption{parquet.Compression(&parquet.Zstd)}, options...)
+	writer := parquet.NewGenericWriter[T](&buf, options...)
+	if _, err := writer.Write(rows); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	sidecar := hex.EncodeToString(sum[:]) + "  " + path.Base(key) + "\n"
+	if err := putObject(ctx, store, sidecarKey(key), []byte(sidecar)); err != nil {
+		return err
 	}
-	return filepath.Join(r.localDir, rel)
+	return putObject(ctx, store, key, buf.Bytes())
 }
 
-func (r *Repo) isLocal() bool {
-	return r.localDir != ""
+// putObject atomically replaces the object at key with data.
+func putObject(ctx context.Context, store blob.Store, key string, data []byte) error {
+	w, err := store.Create(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
 }
 
-func (r *Repo) WriteSections(ctx context.Context, snapshot, title string, sections []domain.Section) error {
-	if r.isLocal() {
-		path := r.localPath(snapshot, title+".parquet")
-		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-			return err
-		}
-		f, err := os.Create(path)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+// readVerified reads the object at key and checks it against its sidecar. Files written
+// before sidecars existed have none and are returned unchecked.
+func (r *Repo) readVerified(ctx context.Context, key string) ([]byte, error) {
+	rc, err := r.store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
 
-		writer := parquet.NewGenericWriter[domain.Section](f)
-		if _, err := writer.Write(sections); err != nil {
-			return err
-		}
-		if err := writer.Close(); err != nil {
-			return err
-		}
-		return nil
+	want, err := r.readSidecar(ctx, key)
+	if errors.Is(err, domain.ErrNotFound) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("%w: %s has sha256 %s, sidecar has %s", domain.ErrChecksumMismatch, key, got, want)
 	}
+	return data, nil
+}
 
-	path := r.objectPath(snapshot, title+".parquet")
-	w := r.client.Bucket(r.bucketName).Object(path).NewWriter(ctx)
-	defer w.Close()
+// readSidecar returns the checksum recorded for key, or domain.ErrNotFound if there is none.
+func (r *Repo) readSidecar(ctx context.Context, key string) (string, error) {
+	rc, err := r.store.Open(ctx, sidecarKey(key))
+	if errors.Is(err, domain.ErrNotFound) {
+		return "", domain.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
 
-	writer := parquet.NewGenericWriter[domain.Section](w)
-	if _, err := writer.Write(sections); err != nil {
+	data, err := io.ReadAll(io.LimitReader(rc, 1024))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("%w: malformed sidecar for %s", domain.ErrChecksumMismatch, key)
+	}
+	return fields[0], nil
+}
+
+// writeJSON stores v as indented JSON at key.
+func (r *Repo) writeJSON(ctx context.Context, key string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
 		return err
 	}
-	if err := writer.Close(); err != nil {
+	return putObject(ctx, r.store, key, data)
+}
+
+// readJSON decodes the JSON object at key into v. Missing objects return domain.ErrNotFound.
+func (r *Repo) readJSON(ctx context.Context, key string, v any) error {
+	rc, err := r.store.Open(ctx, key)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
 		return err
 	}
-	return w.Close()
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(v)
 }
 
-func (r *Repo) ReadSections(ctx context.Context, snapshot, title string) ([]domain.Section, error) {
-	var reader io.Reader
+// listSnapshotDirs returns every snapshot directory under the root prefix, oldest first,
+// whether or not its manifest is complete.
+func (r *Repo) listSnapshotDirs(ctx context.Context) ([]string, error) {
+	names, err := r.store.ListPrefixes(ctx, r.rootPrefix)
+	if err != nil {
+		return nil, err
+	}
 
-	if r.isLocal() {
-		path := r.localPath(snapshot, title+".parquet")
-		f, err := os.Open(path)
-		if err != nil {
-			return nil, err
+	var snapshots []string
+	for _, name := range names {
+		// Skip anything that is not a snapshot ID, such as the published/ pointers and summaries/
+		if _, err := domain.ParseSnapshotID(name); err == nil {
+			snapshots = append(snapshots, name)
 		}
-		defer f.Close()
-		reader = f
-	} else {
-		path := r.objectPath(snapshot, title+".parquet")
-		obj := r.client.Bucket(r.bucketName).Object(path)
+	}
+	// Snapshot IDs sort chronologically, legacy date-only IDs before the runs of that day
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
 
-		rc, err := obj.NewReader(ctx)
+// ListSnapshots returns the snapshots with a complete manifest, oldest first.
+func (r *Repo) ListSnapshots(ctx context.Context) ([]string, error) {
+	dirs, err := r.listSnapshotDirs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var complete []string
+	for _, snap := range dirs {
+		m, err := r.ReadManifest(ctx, snap)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
-		defer rc.Close()
-		reader = rc
+		if m.Status == domain.SnapshotComplete {
+			complete = append(complete, snap)
+		}
 	}
+	return complete, nil
+}
 
-	// parquet.NewGenericReader needs io.ReaderAt; buffer entire object in memory.
-	buf, err := io.ReadAll(reader)
+// ListManifests returns the manifest of every snapshot directory, oldest first. Directories
+// without a manifest (for example from before manifests were written) are reported as incomplete.
+func (r *Repo) ListManifests(ctx context.Context) ([]domain.SnapshotManifest, error) {
+	dirs, err := r.listSnapshotDirs(ctx)
 	if err != nil {
 		return nil, err
 	}
-	pr := parquet.NewGenericReader[domain.Section](bytes.NewReader(buf))
-	defer pr.Close()
-
-	rows := make([]domain.Section, pr.NumRows())
-	_, err = pr.Read(rows)
-	return rows, err
+	manifests := make([]domain.SnapshotManifest, 0, len(dirs))
+	for _, snap := range dirs {
+		m, err := r.ReadManifest(ctx, snap)
+		if errors.Is(err, domain.ErrNotFound) {
+			manifests = append(manifests, domain.SnapshotManifest{SnapshotID: snap, Status: domain.SnapshotIncomplete})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, *m)
+	}
+	return manifests, nil
 }
 
-func (r *Repo) GetLatestSnapshot(ctx context.Context) (time.Time, error) {
-	if r.isLocal() {
-		base := filepath.Join(r.localDir, r.rootPrefix)
-		entries, err := os.ReadDir(base)
+// BackfillLegacyManifests writes a complete manifest into every date-only snapshot that has
+// none but holds at least one <title>.parquet sections file, and returns their IDs. Those
+// snapshots predate manifests; every run since writes an incomplete manifest before anything
+// else. Date-only directories without sections, such as the ones summary jobs used to write,
+// are left alone. The manifest lists the snapshot's files with their rows and checksums, and
+// marks a title succeeded for each sections file. Scoring version and run times are unknown
+// and left empty.
+func (r *Repo) BackfillLegacyManifests(ctx context.Context) ([]string, error) {
+	dirs, err := r.listSnapshotDirs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var backfilled []string
+	for _, snap := range dirs {
+		day, err := time.Parse(domain.SnapshotDayLayout, snap)
 		if err != nil {
-			if os.IsNotExist(err) {
-				return time.Time{}, nil
-			}
-			return time.Time{}, err
+			continue
 		}
-
-		var maxTime time.Time
-		for _, e := range entries {
-			if !e.IsDir() {
-				continue
-			}
-			name := e.Name() // expect "YYYY-MM-DD"
-			t, err := time.Parse("2006-01-02", name)
-			if err == nil && t.After(maxTime) {
-				maxTime = t
+		if _, err := r.ReadManifest(ctx, snap); !errors.Is(err, domain.ErrNotFound) {
+			if err != nil {
+				return backfilled, err
 			}
+			continue
 		}
-		return maxTime, nil
+		m, err := r.legacyManifest(ctx, snap, day)
+		if err != nil {
+			return backfilled, fmt.Errorf("backfill manifest for %s: %w", snap, err)
+		}
+		if m == nil {
+			continue
+		}
+		if err := r.WriteManifest(ctx, *m); err != nil {
+			return backfilled, err
+		}
+		backfilled = append(backfilled, snap)
 	}
+	return backfilled, nil
+}
 
-	// List "directories" under rootPrefix by using Delimiter="/"
-	it := r.client.Bucket(r.bucketName).Objects(ctx, &storage.Query{
-		Prefix:    r.rootPrefix + "/",
-		Delimiter: "/",
-	})
-
-	var maxTime time.Time
-	for {
-		attrs, err := it.Next()
-		if err == iterator.Done {
-			break
+// legacyManifest builds the manifest of a pre-manifest snapshot from the files it holds. It
+// returns nil for a directory with no sections file, which no ETL run wrote.
+func (r *Repo) legacyManifest(ctx context.Context, snapshot string, day time.Time) (*domain.SnapshotManifest, error) {
+	keys, err := r.store.List(ctx, r.objectPath(snapshot)+"/")
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(keys, isSectionsKey) {
+		return nil, nil
+	}
+	m := &domain.SnapshotManifest{SnapshotID: snapshot, Status: domain.SnapshotComplete, StartedAt: day}
+	titles := make(map[string]*domain.ManifestTitle)
+	var order []string
+	for _, key := range keys {
+		name := path.Base(key)
+		if !strings.HasSuffix(name, ".parquet") {
+			continue
 		}
+		info, err := r.FileInfo(ctx, snapshot, name)
 		if err != nil {
-			return time.Time{}, err
+			return nil, err
 		}
-		// When Delimiter is set, prefixes are returned as ObjectAttrs with Prefix set
-		if attrs.Prefix != "" {
-			// strip rootPrefix + "/"
-			snap := strings.TrimPrefix(attrs.Prefix, r.rootPrefix+"/")
-			snap = strings.TrimSuffix(snap, "/")
-			t, err := time.Parse("2006-01-02", snap)
-			if err == nil && t.After(maxTime) {
-				maxTime = t
-			}
+		f, _, err := r.openParquet(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		info.Rows = int(f.NumRows())
+
+		// <title>.parquet, <title>_diffs.parquet and <title>_text.parquet belong to a title
+		title, _, _ := strings.Cut(strings.TrimSuffix(name, ".parquet"), "_")
+		if _, err := strconv.Atoi(title); err != nil {
+			m.Files = append(m.Files, info)
+			continue
+		}
+		t, ok := titles[title]
+		if !ok {
+			t = &domain.ManifestTitle{Title: title, Status: domain.TitleSucceeded}
+			titles[title] = t
+			order = append(order, title)
 		}
+		if name == sectionsKey(title) {
+			t.Rows = info.Rows
+		}
+		t.Files = append(t.Files, info)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, _ := strconv.Atoi(order[i])
+		b, _ := strconv.Atoi(order[j])
+		return a < b
+	})
+	m.Titles = make([]domain.ManifestTitle, 0, len(order))
+	for _, title := range order {
+		m.Titles = append(m.Titles, *titles[title])
+	}
+	return m, nil
+}
+
+// isSectionsKey reports whether key names a <title>.parquet sections file
+func isSectionsKey(key string) bool {
+	title, ok := strings.CutSuffix(path.Base(key), ".parquet")
+	if !ok {
+		return false
 	}
-	return maxTime, nil
+	_, err := strconv.Atoi(title)
+	return err == nil
 }
 
+// GetLatestSnapshot returns the ID of the newest complete snapshot, or "" if there is none.
+func (r *Repo) GetLatestSnapshot(ctx context.Context) (string, error) {
+	snapshots, err := r.ListSnapshots(ctx)
+	if err != nil || len(snapshots) == 0 {
+		return "", err
+	}
+	return snapshots[len(snapshots)-1], nil
+}
+
+// GetPrevSnapshot returns the newest complete snapshot before snapshot, or "" if there is none.
 func (r *Repo) GetPrevSnapshot(ctx context.Context, snapshot string) (string, error) {
-	currentTime, err := time.Parse("2006-01-02", snapshot)
+	if _, err := domain.ParseSnapshotID(snapshot); err != nil {
+		return "", err
+	}
+	snapshots, err := r.ListSnapshots(ctx)
 	if err != nil {
 		return "", err
 	}
+	prev := ""
+	for _, snap := range snapshots {
+		if snap < snapshot {
+			prev = snap
+		}
+	}
+	// Return empty if no previous snapshot found, caller handles it
+	return prev, nil
+}
 
-	var prevDate time.Time
+// WriteManifest stores manifest.json for the manifest's snapshot.
+func (r *Repo) WriteManifest(ctx context.Context, m domain.SnapshotManifest) error {
+	return r.writeJSON(ctx, r.objectPath(m.SnapshotID, "manifest.json"), m)
+}
 
-	if r.isLocal() {
-		base := filepath.Join(r.localDir, r.rootPrefix)
-		entries, err := os.ReadDir(base)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return "", nil
-			}
-			return "", err
-		}
+// ReadManifest loads manifest.json for a snapshot. It returns domain.ErrNotFound if the
+// snapshot has no manifest.
+func (r *Repo) ReadManifest(ctx context.Context, snapshot string) (*domain.SnapshotManifest, error) {
+	var m domain.SnapshotManifest
+	if err := r.readJSON(ctx, r.objectPath(snapshot, "manifest.json"), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
 
-		for _, e := range entries {
-			if !e.IsDir() {
-				continue
-			}
-			name := e.Name()
-			t, err := time.Parse("2006-01-02", name)
-			if err == nil && t.Before(currentTime) && t.After(prevDate) {
-				prevDate = t
-			}
-		}
-	} else {
-		it := r.client.Bucket(r.bucketName).Objects(ctx, &storage.Query{
-			Prefix:    r.rootPrefix + "/",
-			Delimiter: "/",
-		})
-
-		for {
-			attrs, err := it.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				return "", err
-			}
-			if attrs.Prefix != "" {
-				snap := strings.TrimPrefix(attrs.Prefix, r.rootPrefix+"/")
-				snap = strings.TrimSuffix(snap, "/")
-				t, err := time.Parse("2006-01-02", snap)
-				if err == nil && t.Before(currentTime) && t.After(prevDate) {
-					prevDate = t
-				}
-			}
-		}
+// PublishSnapshot points the snapshot's day at it by writing published/<day>.json. Only complete
+// snapshots can be published; publishing a later rerun of the same day replaces the pointer.
+func (r *Repo) PublishSnapshot(ctx context.Context, snapshot string, publishedAt time.Time) (*domain.PublishedSnapshot, error) {
+	day, err := domain.SnapshotDay(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.requireComplete(ctx, snapshot); err != nil {
+		return nil, err
 	}
 
-	if prevDate.IsZero() {
-		// Return empty if no previous snapshot found, caller handles it
-		return "", nil
+	p := &domain.PublishedSnapshot{Day: day, SnapshotID: snapshot, PublishedAt: publishedAt.UTC()}
+	if err := r.writeJSON(ctx, r.objectPath("published", day+".json"), p); err != nil {
+		return nil, err
 	}
-	return prevDate.Format("2006-01-02"), nil
+	return p, nil
 }
 
-func (r *Repo) WriteDiffs(ctx context.Context, snapshot, title string, diffs []domain.Diff) error {
-	if r.isLocal() {
-		path := r.localPath(snapshot, title+"_diffs.parquet")
-		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-			return err
-		}
-		f, err := os.Create(path)
+// GetPublishedSnapshot returns the pointer for a YYYY-MM-DD day, or domain.ErrNotFound if no
+// snapshot has been published for it.
+func (r *Repo) GetPublishedSnapshot(ctx context.Context, day string) (*domain.PublishedSnapshot, error) {
+	if _, err := time.Parse(domain.SnapshotDayLayout, day); err != nil {
+		return nil, fmt.Errorf("%w: day %q", domain.ErrInvalidData, day)
+	}
+
+	var p domain.PublishedSnapshot
+	if err := r.readJSON(ctx, r.objectPath("published", day+".json"), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FileInfo reports the size and SHA-256 of a file written into a snapshot, taking the checksum
+// from its sidecar when there is one. Rows are left for the caller to fill in.
+func (r *Repo) FileInfo(ctx context.Context, snapshot, name string) (domain.ManifestFile, error) {
+	key := r.objectPath(snapshot, name)
+	sum, err := r.readSidecar(ctx, key)
+	if err == nil {
+		attrs, err := r.store.Stat(ctx, key)
 		if err != nil {
-			return err
+			return domain.ManifestFile{}, err
 		}
-		defer f.Close()
+		return domain.ManifestFile{Name: name, Bytes: attrs.Size, SHA256: sum}, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return domain.ManifestFile{}, err
+	}
 
-		writer := parquet.NewGenericWriter[domain.Diff](f)
-		if _, err := writer.Write(diffs); err != nil {
-			return err
-		}
-		if err := writer.Close(); err != nil {
-			return err
-		}
-		return nil
+	rc, err := r.store.Open(ctx, key)
+	if err != nil {
+		return domain.ManifestFile{}, err
 	}
+	defer rc.Close()
 
-	path := r.objectPath(snapshot, title+"_diffs.parquet")
-	w := r.client.Bucket(r.bucketName).Object(path).NewWriter(ctx)
-	defer w.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		return domain.ManifestFile{}, err
+	}
+	return domain.ManifestFile{Name: name, Bytes: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
 
-	writer := parquet.NewGenericWriter[domain.Diff](w)
-	if _, err := writer.Write(diffs); err != nil {
-		return err
+// requireComplete refuses snapshots whose manifest is missing or not complete.
+func (r *Repo) requireComplete(ctx context.Context, snapshot string) error {
+	m, err := r.ReadManifest(ctx, snapshot)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("%w: %s has no manifest", domain.ErrIncompleteSnapshot, snapshot)
 	}
-	if err := writer.Close(); err != nil {
+	if err != nil {
 		return err
 	}
-	return w.Close()
+	if m.Status != domain.SnapshotComplete {
+		return fmt.Errorf("%w: %s is %s", domain.ErrIncompleteSnapshot, snapshot, m.Status)
+	}
+	return nil
+}
+
+func (r *Repo) WriteDiffs(ctx context.Context, snapshot, title string, diffs []domain.Diff) error {
+	return writeRows(ctx, r.store, r.objectPath(snapshot, title+"_diffs.parquet"), diffs)
 }
 
 // AgencyLSARecord is a parquet-compatible representation of per-agency LSA activity
@@ -325,74 +496,49 @@ func (r *Repo) WriteAgencyLSA(ctx context.Context, snapshot string, lsaRecords [
 			SourceHint:     lsa.SourceHint,
 		}
 	}
+	return writeRows(ctx, r.store, r.objectPath(snapshot, "agency_lsa.parquet"), records)
+}
 
-	if r.isLocal() {
-		path := r.localPath(snapshot, "agency_lsa.parquet")
-		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-			return err
-		}
-		f, err := os.Create(path)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		writer := parquet.NewGenericWriter[AgencyLSARecord](f)
-		if _, err := writer.Write(records); err != nil {
-			return err
-		}
-		if err := writer.Close(); err != nil {
-			return err
-		}
-		return nil
-	}
-
-	path := r.objectPath(snapshot, "agency_lsa.parquet")
-	w := r.client.Bucket(r.bucketName).Object(path).NewWriter(ctx)
-	defer w.Close()
-
-	writer := parquet.NewGenericWriter[AgencyLSARecord](w)
-	if _, err := writer.Write(records); err != nil {
-		return err
-	}
-	if err := writer.Close(); err != nil {
-		return err
-	}
-	return w.Close()
+// WriteSummaries writes a batch of generated summaries to summaries/<batch>.parquet. The batch
+// lives outside the snapshot directories, so a summary job never creates a snapshot.
+func (r *Repo) WriteSummaries(ctx context.Context, batch string, summaries []domain.Summary) error {
+	return writeRows(ctx, r.store, r.objectPath("summaries", batch+".parquet"), summaries)
 }
 
-func (r *Repo) WriteSummaries(ctx context.Context, snapshot string, summaries []domain.Summary) error {
-	if r.isLocal() {
-		path := r.localPath(snapshot, "summaries.parquet")
-		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-			return err
-		}
-		f, err := os.Create(path)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+// AgencyMetricsRecord is a parquet-compatible representation of a per-agency metrics rollup
+type AgencyMetricsRecord struct {
+	AgencyID        string    `parquet:"agency_id"`
+	SnapshotID      string    `parquet:"snapshot_id"`
+	TotalWords      int       `parquet:"total_words"`
+	TotalRSCS       int       `parquet:"total_rscs"`
+	AvgRSCS         float64   `parquet:"avg_rscs"`
+	WeightedAvgRSCS float64   `parquet:"weighted_avg_rscs"`
+	MedianRSCS      float64   `parquet:"median_rscs"`
+	P90RSCS         float64   `parquet:"p90_rscs"`
+	TotalRSCSPer1K  float64   `parquet:"total_rscs_per_1k"`
+	Restrictions    int       `parquet:"restrictions"`
+	SectionCount    int       `parquet:"section_count"`
+	ComputedAt      time.Time `parquet:"computed_at"`
+}
 
-		writer := parquet.NewGenericWriter[domain.Summary](f)
-		if _, err := writer.Write(summaries); err != nil {
-			return err
-		}
-		if err := writer.Close(); err != nil {
-			return err
+// WriteAgencyMetrics writes the per-agency metrics rollup for a snapshot to Parquet
+func (r *Repo) WriteAgencyMetrics(ctx context.Context, snapshot string, metrics []domain.AgencyMetricSnapshot) error {
+	records := make([]AgencyMetricsRecord, len(metrics))
+	for i, m := range metrics {
+		records[i] = AgencyMetricsRecord{
+			AgencyID:        m.AgencyID,
+			SnapshotID:      m.SnapshotID,
+			TotalWords:      m.TotalWords,
+			TotalRSCS:       m.TotalRSCS,
+			AvgRSCS:         m.AvgRSCS,
+			WeightedAvgRSCS: m.WeightedAvgRSCS,
+			MedianRSCS:      m.MedianRSCS,
+			P90RSCS:         m.P90RSCS,
+			TotalRSCSPer1K:  m.TotalRSCSPer1K,
+			Restrictions:    m.Restrictions,
+			SectionCount:    m.SectionCount,
+			ComputedAt:      m.ComputedAt,
 		}
-		return nil
 	}
-
-	path := r.objectPath(snapshot, "summaries.parquet")
-	w := r.client.Bucket(r.bucketName).Object(path).NewWriter(ctx)
-	defer w.Close()
-
-	writer := parquet.NewGenericWriter[domain.Summary](w)
-	if _, err := writer.Write(summaries); err != nil {
-		return err
-	}
-	if err := writer.Close(); err != nil {
-		return err
-	}
-	return w.Close()
+	return writeRows(ctx, r.store, r.objectPath(snapshot, "agency_metrics.parquet"), records)
 }
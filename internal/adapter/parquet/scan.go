@@ -0,0 +1,284 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// SectionQuery selects the columns and rows ScanSections reads from a title.
+type SectionQuery struct {
+	// Columns to decode, named as in SectionRecord plus "text". Empty means every column except
+	// text. Columns the filters need are decoded as well.
+	Columns []string
+
+	// AgencyID keeps only sections owned by the agency
+	AgencyID string
+
+	// Parts keeps only sections in the listed parts
+	Parts []string
+
+	// ChangedFrom maps section IDs to checksums, e.g. from a previous snapshot. When set, only
+	// sections whose checksum differs, or whose ID is not in the map, are kept.
+	ChangedFrom map[string]string
+}
+
+// sectionColumn decodes one Parquet column into a domain.Section field. legacy is the column's
+// name in version 1 files.
+type sectionColumn struct {
+	legacy string
+	set    func(s *domain.Section, v parquet.Value)
+}
+
+var sectionColumns = map[string]sectionColumn{
+	"id":              {"ID", func(s *domain.Section, v parquet.Value) { s.ID = string(v.ByteArray()) }},
+	"title":           {"Title", func(s *domain.Section, v parquet.Value) { s.Title = string(v.ByteArray()) }},
+	"part":            {"Part", func(s *domain.Section, v parquet.Value) { s.Part = string(v.ByteArray()) }},
+	"section":         {"Section", func(s *domain.Section, v parquet.Value) { s.Section = string(v.ByteArray()) }},
+	"agency_id":       {"AgencyID", func(s *domain.Section, v parquet.Value) { s.AgencyID = string(v.ByteArray()) }},
+	"path":            {"Path", func(s *domain.Section, v parquet.Value) { s.Path = string(v.ByteArray()) }},
+	"rev_date":        {"RevDate", func(s *domain.Section, v parquet.Value) { s.RevDate = time.Unix(0, v.Int64()).UTC() }},
+	"checksum_sha256": {"ChecksumSHA256", func(s *domain.Section, v parquet.Value) { s.ChecksumSHA256 = string(v.ByteArray()) }},
+	"text_sha256":     {"TextSHA256", func(s *domain.Section, v parquet.Value) { s.TextSHA256 = string(v.ByteArray()) }},
+	"word_count":      {"WordCount", func(s *domain.Section, v parquet.Value) { s.WordCount = int(v.Int64()) }},
+	"def_count":       {"DefCount", func(s *domain.Section, v parquet.Value) { s.DefCount = int(v.Int64()) }},
+	"xref_count":      {"XrefCount", func(s *domain.Section, v parquet.Value) { s.XrefCount = int(v.Int64()) }},
+	"modal_count":     {"ModalCount", func(s *domain.Section, v parquet.Value) { s.ModalCount = int(v.Int64()) }},
+	"rscs_raw":        {"RSCSRaw", func(s *domain.Section, v parquet.Value) { s.RSCSRaw = int(v.Int64()) }},
+	"rscs_per_1k":     {"RSCSPer1K", func(s *domain.Section, v parquet.Value) { s.RSCSPer1K = v.Double() }},
+	"snapshot_date":   {"SnapshotDate", func(s *domain.Section, v parquet.Value) { s.SnapshotDate = string(v.ByteArray()) }},
+	"snapshot_id":     {"SnapshotID", func(s *domain.Section, v parquet.Value) { s.SnapshotID = string(v.ByteArray()) }},
+	"text":            {"Text", func(s *domain.Section, v parquet.Value) { s.Text = string(v.ByteArray()) }},
+}
+
+// SectionMetricColumns lists every section column except text, in file order.
+var SectionMetricColumns = []string{
+	"id", "title", "part", "section", "agency_id", "path", "rev_date", "checksum_sha256",
+	"text_sha256", "word_count", "def_count", "xref_count", "modal_count", "rscs_raw", "rscs_per_1k",
+	"snapshot_date", "snapshot_id",
+}
+
+// ScanSections calls fn for each section of a title in a complete snapshot that matches q, in
+// file order. Only the requested columns are decoded, one row group at a time, and row groups
+// whose statistics rule out the agency or part filters are skipped. Text is only read when
+// "text" is requested. Returning an error from fn stops the scan.
+func (r *Repo) ScanSections(ctx context.Context, snapshot, title string, q SectionQuery, fn func(domain.Section) error) error {
+	columns := q.Columns
+	if len(columns) == 0 {
+		columns = SectionMetricColumns
+	}
+	want := make(map[string]bool, len(columns)+3)
+	for _, c := range columns {
+		if _, ok := sectionColumns[c]; !ok {
+			return fmt.Errorf("%w: unknown section column %q", domain.ErrInvalidData, c)
+		}
+		want[c] = true
+	}
+	if q.AgencyID != "" {
+		want["agency_id"] = true
+	}
+	if len(q.Parts) > 0 {
+		want["part"] = true
+	}
+	if q.ChangedFrom != nil {
+		want["id"] = true
+		want["checksum_sha256"] = true
+	}
+	withText := want["text"]
+	delete(want, "text")
+
+	if err := r.requireComplete(ctx, snapshot); err != nil {
+		return err
+	}
+	f, version, err := r.openParquetRanged(ctx, r.objectPath(snapshot, sectionsKey(title)))
+	if err != nil {
+		return err
+	}
+	if version > SectionSchemaVersion {
+		return fmt.Errorf("%w: %s/%s has section schema version %d, newest supported is %d",
+			domain.ErrInvalidData, snapshot, title, version, SectionSchemaVersion)
+	}
+
+	// Version 1 files keep text inline, version 2 files in row groups that line up with the
+	// metrics file's, and version 3 in the TextStore, fetched only for rows that match
+	var textFile *parquet.File
+	if withText {
+		switch version {
+		case 1:
+			want["text"] = true
+		case 2:
+			if textFile, _, err = r.openParquetRanged(ctx, r.objectPath(snapshot, sectionTextKey(title))); err != nil {
+				return err
+			}
+		default:
+			want["text_sha256"] = true
+		}
+	}
+
+	parts := make(map[string]bool, len(q.Parts))
+	for _, p := range q.Parts {
+		parts[p] = true
+	}
+	match := func(s *domain.Section) bool {
+		if q.AgencyID != "" && s.AgencyID != q.AgencyID {
+			return false
+		}
+		if len(parts) > 0 && !parts[s.Part] {
+			return false
+		}
+		if q.ChangedFrom != nil {
+			if sum, ok := q.ChangedFrom[s.ID]; ok && sum == s.ChecksumSHA256 {
+				return false
+			}
+		}
+		return true
+	}
+
+	rowGroups := f.RowGroups()
+	if textFile != nil && len(textFile.RowGroups()) != len(rowGroups) {
+		return fmt.Errorf("%w: %s has %d row groups but its text has %d", domain.ErrInvalidData, title, len(rowGroups), len(textFile.RowGroups()))
+	}
+	for i, rg := range rowGroups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !rowGroupMatches(f, i, version, q.AgencyID, q.Parts) {
+			continue
+		}
+
+		rows := make([]domain.Section, rg.NumRows())
+		for name := range want {
+			if err := decodeColumn(f, rg, columnName(name, version), rows, sectionColumns[name].set); err != nil {
+				return fmt.Errorf("%s/%s column %s: %w", snapshot, title, name, err)
+			}
+		}
+		if textFile != nil {
+			trg := textFile.RowGroups()[i]
+			if trg.NumRows() != rg.NumRows() {
+				return fmt.Errorf("%w: %s row group %d has %d rows but its text has %d", domain.ErrInvalidData, title, i, rg.NumRows(), trg.NumRows())
+			}
+			if err := decodeColumn(textFile, trg, "text", rows, sectionColumns["text"].set); err != nil {
+				return fmt.Errorf("%s/%s text: %w", snapshot, title, err)
+			}
+		}
+
+		for j := range rows {
+			if !match(&rows[j]) {
+				continue
+			}
+			if withText && version >= 3 {
+				if rows[j].Text, err = r.texts.Get(ctx, rows[j].TextSHA256); err != nil {
+					return fmt.Errorf("section %s: %w", rows[j].ID, err)
+				}
+			}
+			if err := fn(rows[j]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// columnName maps a column to its name in a file of the given schema version.
+func columnName(name string, version int) string {
+	if version < 2 {
+		return sectionColumns[name].legacy
+	}
+	return name
+}
+
+// rowGroupMatches reports whether row group i of f can hold sections of agencyID in one of
+// parts, judging by the min/max statistics of those columns. Row groups without statistics
+// always match.
+func rowGroupMatches(f *parquet.File, i, version int, agencyID string, parts []string) bool {
+	if agencyID != "" && !statsMayContain(f, i, columnName("agency_id", version), agencyID) {
+		return false
+	}
+	if len(parts) == 0 {
+		return true
+	}
+	for _, p := range parts {
+		if statsMayContain(f, i, columnName("part", version), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// statsMayContain reports whether value lies within the min/max statistics of a string column
+// in row group i.
+func statsMayContain(f *parquet.File, i int, column, value string) bool {
+	leaf, ok := f.Schema().Lookup(column)
+	if !ok {
+		return true
+	}
+	chunks := f.Metadata().RowGroups[i].Columns
+	if leaf.ColumnIndex >= len(chunks) {
+		return true
+	}
+	stats := chunks[leaf.ColumnIndex].MetaData.Statistics
+	lo, hi := stats.MinValue, stats.MaxValue
+	if lo == nil || hi == nil {
+		// Older writers only fill the deprecated fields
+		lo, hi = stats.Min, stats.Max
+	}
+	if lo == nil || hi == nil {
+		return true
+	}
+	v := []byte(value)
+	return bytes.Compare(v, lo) >= 0 && bytes.Compare(v, hi) <= 0
+}
+
+// decodeColumn reads one column of a row group into rows, in row order.
+func decodeColumn(f *parquet.File, rg parquet.RowGroup, column string, rows []domain.Section, set func(*domain.Section, parquet.Value)) error {
+	leaf, ok := f.Schema().Lookup(column)
+	if !ok {
+		// Columns added after the file was written stay at their zero value
+		return nil
+	}
+	pages := rg.ColumnChunks()[leaf.ColumnIndex].Pages()
+	defer pages.Close()
+
+	row := 0
+	values := make([]parquet.Value, 1024)
+	for {
+		page, err := pages.ReadPage()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		reader := page.Values()
+		for {
+			n, err := reader.ReadValues(values)
+			for _, v := range values[:n] {
+				if row >= len(rows) {
+					parquet.Release(page)
+					return fmt.Errorf("%w: more values than rows", domain.ErrInvalidData)
+				}
+				set(&rows[row], v)
+				row++
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				parquet.Release(page)
+				return err
+			}
+		}
+		parquet.Release(page)
+	}
+	if row != len(rows) {
+		return fmt.Errorf("%w: %d values for %d rows", domain.ErrInvalidData, row, len(rows))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+func TestRowGroupMatches(t *testing.T) {
+	// Two full row groups: parts 1-4 then 5-8, all owned by agency "a"
+	var records []SectionRecord
+	for i := 0; i < 2*sectionRowGroupSize; i++ {
+		records = append(records, SectionRecord{
+			ID:       fmt.Sprintf("§ %d", i),
+			Part:     fmt.Sprint(1 + i*8/(2*sectionRowGroupSize)),
+			AgencyID: "a",
+		})
+	}
+	var buf bytes.Buffer
+	w := parquet.NewGenericWriter[SectionRecord](&buf, sectionWriterOptions()...)
+	if _, err := w.Write(records); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if n := len(f.RowGroups()); n != 2 {
+		t.Fatalf("Expected 2 row groups, got %d", n)
+	}
+
+	tests := []struct {
+		agency string
+		parts  []string
+		want   [2]bool
+	}{
+		{"", nil, [2]bool{true, true}},
+		{"", []string{"2"}, [2]bool{true, false}},
+		{"", []string{"7"}, [2]bool{false, true}},
+		{"", []string{"2", "7"}, [2]bool{true, true}},
+		{"", []string{"9"}, [2]bool{false, false}},
+		{"a", nil, [2]bool{true, true}},
+		{"b", nil, [2]bool{false, false}},
+	}
+	for _, tt := range tests {
+		for i := range 2 {
+			if got := rowGroupMatches(f, i, SectionSchemaVersion, tt.agency, tt.parts); got != tt.want[i] {
+				t.Errorf("rowGroupMatches(%d, %q, %v) = %v, want %v", i, tt.agency, tt.parts, got, tt.want[i])
+			}
+		}
+	}
+}
+
+// fetchCounter records how much of each Parquet file is read through a store.
+type fetchCounter struct {
+	blob.Store
+	opened  []string
+	fetched int64
+}
+
+func (c *fetchCounter) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if strings.HasSuffix(key, ".parquet") {
+		c.opened = append(c.opened, key)
+	}
+	return c.Store.Open(ctx, key)
+}
+
+func (c *fetchCounter) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	c.fetched += length
+	return c.Store.OpenRange(ctx, key, offset, length)
+}
+
+func TestScanSectionsRangedReads(t *testing.T) {
+	ctx := context.Background()
+	store := &fetchCounter{Store: blob.NewMem()}
+	repo := NewStoreRepo(store, "parquet")
+
+	// Eight row groups of incompressible checksums, one part each
+	const groups = 8
+	sections := make([]domain.Section, groups*sectionRowGroupSize)
+	for i := range sections {
+		sections[i] = domain.Section{
+			ID:             fmt.Sprintf("§ %d.%d", 1+i/sectionRowGroupSize, i),
+			Part:           fmt.Sprint(1 + i/sectionRowGroupSize),
+			AgencyID:       "a",
+			ChecksumSHA256: domain.TextHash(fmt.Sprint("checksum", i)),
+			Text:           fmt.Sprint("text ", i),
+		}
+	}
+	snapshot := "2025-01-01"
+	if err := repo.WriteSections(ctx, snapshot, "1", sections); err != nil {
+		t.Fatalf("WriteSections failed: %v", err)
+	}
+	if err := repo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: snapshot, Status: domain.SnapshotComplete}); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	attrs, err := store.Stat(ctx, repo.objectPath(snapshot, sectionsKey("1")))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if attrs.Size <= 2*rangedReadSize {
+		t.Fatalf("sections file is %d bytes; the test needs more than the footer prefetch", attrs.Size)
+	}
+
+	store.opened, store.fetched = nil, 0
+	var got []domain.Section
+	q := SectionQuery{Columns: []string{"id", "checksum_sha256"}, Parts: []string{"8"}}
+	if err := repo.ScanSections(ctx, snapshot, "1", q, func(s domain.Section) error {
+		got = append(got, s)
+		return nil
+	}); err != nil {
+		t.Fatalf("ScanSections failed: %v", err)
+	}
+	if len(got) != sectionRowGroupSize || got[0].ID != sections[7*sectionRowGroupSize].ID {
+		t.Fatalf("ScanSections returned %d sections starting at %+v", len(got), got[0])
+	}
+	if len(store.opened) != 0 {
+		t.Errorf("expected only ranged reads of the sections file, got whole reads of %v", store.opened)
+	}
+	if store.fetched >= attrs.Size {
+		t.Errorf("fetched %d bytes of a %d byte file; skipped row groups should not be read", store.fetched, attrs.Size)
+	}
+}
@@ -0,0 +1,274 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Section files record their layout version in the Parquet footer under schemaVersionKey.
+// Files written before the key existed are version 1: domain.Section's Go field names as
+// columns, text inline, uncompressed and unsorted. Version 2 moved text to <title>_text.parquet;
+// version 3 keeps it in the snapshot-wide TextStore, referenced by text_sha256.
+const (
+	schemaVersionKey     = "ecfr.schema_version"
+	SectionSchemaVersion = 3
+
+	// sectionRowGroupSize keeps large titles split into several row groups, each covering a
+	// narrow range of parts, so part filters can skip most of a file
+	sectionRowGroupSize = 4096
+)
+
+// SectionRecord is the layout of <title>.parquet from schema version 2. Column names are part of
+// the file format: add columns rather than renaming them, and bump SectionSchemaVersion when a
+// reader needs to tell layouts apart.
+type SectionRecord struct {
+	ID             string    `parquet:"id"`
+	Title          string    `parquet:"title"`
+	Part           string    `parquet:"part"`
+	Section        string    `parquet:"section"`
+	AgencyID       string    `parquet:"agency_id"`
+	Path           string    `parquet:"path"`
+	RevDate        time.Time `parquet:"rev_date"`
+	ChecksumSHA256 string    `parquet:"checksum_sha256"`
+	TextSHA256     string    `parquet:"text_sha256"` // From version 3
+	WordCount      int       `parquet:"word_count"`
+	DefCount       int       `parquet:"def_count"`
+	XrefCount      int       `parquet:"xref_count"`
+	ModalCount     int       `parquet:"modal_count"`
+	RSCSRaw        int       `parquet:"rscs_raw"`
+	RSCSPer1K      float64   `parquet:"rscs_per_1k"`
+	SnapshotDate   string    `parquet:"snapshot_date"`
+	SnapshotID     string    `parquet:"snapshot_id"`
+}
+
+// SectionTextRecord is the layout of <title>_text.parquet in version 2 snapshots. Rows are in the
+// same order as the title's SectionRecords.
+type SectionTextRecord struct {
+	ID   string `parquet:"id"`
+	Part string `parquet:"part"`
+	Text string `parquet:"text"`
+}
+
+func sectionsKey(title string) string {
+	return title + ".parquet"
+}
+
+func sectionTextKey(title string) string {
+	return title + "_text.parquet"
+}
+
+// sectionWriterOptions sorts row groups by part and stamps the schema version.
+func sectionWriterOptions() []parquet.WriterOption {
+	return []parquet.WriterOption{
+		parquet.KeyValueMetadata(schemaVersionKey, strconv.Itoa(SectionSchemaVersion)),
+		parquet.SortingWriterConfig(parquet.SortingColumns(parquet.Ascending("part"))),
+		parquet.MaxRowsPerRowGroup(sectionRowGroupSize),
+	}
+}
+
+// WriteSections writes a title's metrics to <title>.parquet, ordered by part with sections in
+// document order within a part, and adds any text the TextStore does not have yet. Texts are
+// stored first so that a metrics file never references text that is missing.
+func (r *Repo) WriteSections(ctx context.Context, snapshot, title string, sections []domain.Section) error {
+	if _, err := r.texts.PutSections(ctx, sections); err != nil {
+		return err
+	}
+
+	sorted := make([]domain.Section, len(sections))
+	copy(sorted, sections)
+	for i := range sorted {
+		if sorted[i].TextSHA256 == "" {
+			sorted[i].TextSHA256 = domain.TextHash(sorted[i].Text)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Part < sorted[j].Part })
+
+	records := make([]SectionRecord, len(sorted))
+	for i, s := range sorted {
+		records[i] = SectionRecord{
+			ID:             s.ID,
+			Title:          s.Title,
+			Part:           s.Part,
+			Section:        s.Section,
+			AgencyID:       s.AgencyID,
+			Path:           s.Path,
+			RevDate:        s.RevDate,
+			ChecksumSHA256: s.ChecksumSHA256,
+			TextSHA256:     s.TextSHA256,
+			WordCount:      s.WordCount,
+			DefCount:       s.DefCount,
+			XrefCount:      s.XrefCount,
+			ModalCount:     s.ModalCount,
+			RSCSRaw:        s.RSCSRaw,
+			RSCSPer1K:      s.RSCSPer1K,
+			SnapshotDate:   s.SnapshotDate,
+			SnapshotID:     s.SnapshotID,
+		}
+	}
+	return writeRows(ctx, r.store, r.objectPath(snapshot, sectionsKey(title)), records, sectionWriterOptions()...)
+}
+
+// ReadSections reads a title from a complete snapshot, text included. Snapshots that are still
+// being written, or that never finished, are refused with domain.ErrIncompleteSnapshot, and files
+// that do not match their checksum sidecar with domain.ErrChecksumMismatch.
+func (r *Repo) ReadSections(ctx context.Context, snapshot, title string) ([]domain.Section, error) {
+	sections, version, err := r.readSections(ctx, snapshot, title)
+	if err != nil || version < 2 {
+		// Version 1 files carry their text inline
+		return sections, err
+	}
+	if version >= 3 {
+		for i := range sections {
+			if sections[i].Text, err = r.texts.Get(ctx, sections[i].TextSHA256); err != nil {
+				return nil, fmt.Errorf("section %s: %w", sections[i].ID, err)
+			}
+		}
+		return sections, nil
+	}
+
+	f, _, err := r.openParquet(ctx, r.objectPath(snapshot, sectionTextKey(title)))
+	if err != nil {
+		return nil, err
+	}
+	texts, err := readAll[SectionTextRecord](f)
+	if err != nil {
+		return nil, err
+	}
+	if len(texts) != len(sections) {
+		return nil, fmt.Errorf("%w: %s has %d sections but %d texts", domain.ErrInvalidData, title, len(sections), len(texts))
+	}
+	for i := range sections {
+		if texts[i].ID != sections[i].ID {
+			return nil, fmt.Errorf("%w: %s text row %d is %s, want %s", domain.ErrInvalidData, title, i, texts[i].ID, sections[i].ID)
+		}
+		sections[i].Text = texts[i].Text
+	}
+	return sections, nil
+}
+
+// ReadSectionMetrics reads every column of a title except text from a complete snapshot.
+func (r *Repo) ReadSectionMetrics(ctx context.Context, snapshot, title string) ([]domain.Section, error) {
+	var sections []domain.Section
+	err := r.ScanSections(ctx, snapshot, title, SectionQuery{}, func(s domain.Section) error {
+		sections = append(sections, s)
+		return nil
+	})
+	return sections, err
+}
+
+// readSections reads <title>.parquet in whichever schema version it was written, returning
+// the version alongside the rows.
+func (r *Repo) readSections(ctx context.Context, snapshot, title string) ([]domain.Section, int, error) {
+	if err := r.requireComplete(ctx, snapshot); err != nil {
+		return nil, 0, err
+	}
+	f, version, err := r.openParquet(ctx, r.objectPath(snapshot, sectionsKey(title)))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch version {
+	case 1:
+		sections, err := readAll[domain.Section](f)
+		return sections, version, err
+	case 2, 3:
+		records, err := readAll[SectionRecord](f)
+		if err != nil {
+			return nil, 0, err
+		}
+		sections := make([]domain.Section, len(records))
+		for i, rec := range records {
+			sections[i] = domain.Section{
+				ID:             rec.ID,
+				Title:          rec.Title,
+				Part:           rec.Part,
+				Section:        rec.Section,
+				AgencyID:       rec.AgencyID,
+				Path:           rec.Path,
+				RevDate:        rec.RevDate,
+				ChecksumSHA256: rec.ChecksumSHA256,
+				TextSHA256:     rec.TextSHA256,
+				WordCount:      rec.WordCount,
+				DefCount:       rec.DefCount,
+				XrefCount:      rec.XrefCount,
+				ModalCount:     rec.ModalCount,
+				RSCSRaw:        rec.RSCSRaw,
+				RSCSPer1K:      rec.RSCSPer1K,
+				SnapshotDate:   rec.SnapshotDate,
+				SnapshotID:     rec.SnapshotID,
+			}
+		}
+		return sections, version, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: %s/%s has section schema version %d, newest supported is %d",
+			domain.ErrInvalidData, snapshot, title, version, SectionSchemaVersion)
+	}
+}
+
+// openParquet reads and verifies the file at key and returns it with its schema version.
+func (r *Repo) openParquet(ctx context.Context, key string) (*parquet.File, int, error) {
+	// Reading everything anyway, so fetch it in one go and verify it
+	buf, err := r.readVerified(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return openFile(key, bytes.NewReader(buf), int64(len(buf)))
+}
+
+// rangedReadSize is how much a ranged scan reads per request: the footer up front, then up to
+// this much of each column chunk it decodes.
+const rangedReadSize = 1 << 20
+
+// openParquetRanged opens the file at key through ranged reads. Only the footer and the column
+// chunks the caller decodes are fetched, so row groups a scan skips and columns it does not ask
+// for are never read. That rules out checking the whole file against its sidecar; damaged
+// chunks fail to decode instead.
+func (r *Repo) openParquetRanged(ctx context.Context, key string) (*parquet.File, int, error) {
+	ra, err := blob.NewReaderAt(ctx, r.store, key, rangedReadSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return openFile(key, ra, ra.Size(),
+		parquet.ReadBufferSize(rangedReadSize), parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+}
+
+// openFile opens a Parquet file and returns it with its schema version.
+func openFile(key string, ra io.ReaderAt, size int64, options ...parquet.FileOption) (*parquet.File, int, error) {
+	f, err := parquet.OpenFile(ra, size, options...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s: %v", domain.ErrInvalidData, key, err)
+	}
+
+	v, ok := f.Lookup(schemaVersionKey)
+	if !ok {
+		return f, 1, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s has schema version %q", domain.ErrInvalidData, key, v)
+	}
+	return f, version, nil
+}
+
+// readAll reads every row of f as T. Columns missing from the file are left as zero values.
+func readAll[T any](f *parquet.File) ([]T, error) {
+	pr := parquet.NewGenericReader[T](f)
+	defer pr.Close()
+
+	rows := make([]T, f.NumRows())
+	n, err := pr.Read(rows)
+	if err == io.EOF && n == len(rows) {
+		err = nil
+	}
+	return rows[:n], err
+}
@@ -0,0 +1,146 @@
+package parquet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Encoder and decoder are safe for concurrent EncodeAll/DecodeAll calls
+var (
+	textEncoder, _ = zstd.NewWriter(nil)
+	textDecoder, _ = zstd.NewReader(nil)
+)
+
+// TextStore keeps each distinct section text once, zstd-compressed and addressed by the hex
+// SHA-256 of its content (domain.TextHash), at <prefix>/<first two hex digits>/<hash>.zst.
+// Objects are never rewritten, so snapshots can share them freely.
+type TextStore struct {
+	store  blob.Store
+	prefix string
+
+	mu     sync.Mutex
+	loaded bool
+	known  map[string]bool
+}
+
+func NewTextStore(store blob.Store, prefix string) *TextStore {
+	return &TextStore{store: store, prefix: prefix, known: make(map[string]bool)}
+}
+
+func (t *TextStore) key(hash string) string {
+	return blob.Join(t.prefix, hash[:2], hash+".zst")
+}
+
+func validTextHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// load lists the stored hashes once, so writes only touch texts the store has not seen.
+func (t *TextStore) load(ctx context.Context) error {
+	if t.loaded {
+		return nil
+	}
+	keys, err := t.store.List(ctx, blob.Join(t.prefix)+"/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if hash, ok := strings.CutSuffix(path.Base(key), ".zst"); ok && validTextHash(hash) {
+			t.known[hash] = true
+		}
+	}
+	t.loaded = true
+	return nil
+}
+
+// Put stores text unless it is already present and returns its hash.
+func (t *TextStore) Put(ctx context.Context, text string) (string, error) {
+	hash := domain.TextHash(text)
+
+	t.mu.Lock()
+	err := t.load(ctx)
+	seen := t.known[hash]
+	t.mu.Unlock()
+	if err != nil || seen {
+		return hash, err
+	}
+
+	err = putObject(ctx, t.store, t.key(hash), textEncoder.EncodeAll([]byte(text), nil))
+	// A concurrent writer got there first with the same content
+	if err != nil && !errors.Is(err, blob.ErrConflict) {
+		return "", err
+	}
+	t.mu.Lock()
+	t.known[hash] = true
+	t.mu.Unlock()
+	return hash, nil
+}
+
+// PutSections stores the text of each section and returns how many texts were new. A section
+// whose TextSHA256 is set but does not match its text is refused with domain.ErrInvalidData.
+func (t *TextStore) PutSections(ctx context.Context, sections []domain.Section) (int, error) {
+	added := 0
+	for _, s := range sections {
+		hash := domain.TextHash(s.Text)
+		if s.TextSHA256 != "" && s.TextSHA256 != hash {
+			return added, fmt.Errorf("%w: section %s text hash %s does not match its text", domain.ErrInvalidData, s.ID, s.TextSHA256)
+		}
+		t.mu.Lock()
+		err := t.load(ctx)
+		seen := t.known[hash]
+		t.mu.Unlock()
+		if err != nil {
+			return added, err
+		}
+		if seen {
+			continue
+		}
+		if _, err := t.Put(ctx, s.Text); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
+
+// Get returns the text with the given hash. Unknown hashes return domain.ErrNotFound and
+// stored text that no longer matches its hash domain.ErrChecksumMismatch.
+func (t *TextStore) Get(ctx context.Context, hash string) (string, error) {
+	if !validTextHash(hash) {
+		return "", fmt.Errorf("%w: text hash %q", domain.ErrInvalidData, hash)
+	}
+	rc, err := t.store.Open(ctx, t.key(hash))
+	if err != nil {
+		return "", err
+	}
+	compressed, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := textDecoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: text %s: %v", domain.ErrChecksumMismatch, hash, err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != hash {
+		return "", fmt.Errorf("%w: text %s", domain.ErrChecksumMismatch, hash)
+	}
+	return string(data), nil
+}
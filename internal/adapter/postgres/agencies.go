@@ -0,0 +1,376 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/ecfr"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// SyncAgencies brings the agencies table in line with the agency hierarchy, in one transaction:
+// it adds new agencies, renames and re-parents existing ones, removes those no longer listed and
+// replaces every CFR reference. Each change is recorded in agency_changes and returned. Content
+// checksums of agencies that stay are kept.
+func (r *Repo) SyncAgencies(agencies []ecfr.Agency, syncedAt time.Time) ([]domain.AgencyChange, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := loadAgencyEntries(tx)
+	if err != nil {
+		return nil, err
+	}
+	next := ecfr.Flatten(agencies)
+	changes, err := ecfr.DiffAgencies(current, next, syncedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// References are not tracked individually, so they are reloaded whole
+	if _, err := tx.Exec(`DELETE FROM agency_cfr_references`); err != nil {
+		return nil, err
+	}
+	// Parents come before their children, so every parent_id exists when it is set
+	for _, a := range next {
+		var parentID *string
+		if a.ParentID != "" {
+			parentID = &a.ParentID
+		}
+		_, err := tx.Exec(`
+			INSERT INTO agencies (id, name, short_name, sortable_name, parent_id)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO UPDATE SET
+				name = excluded.name,
+				short_name = excluded.short_name,
+				sortable_name = excluded.sortable_name,
+				parent_id = excluded.parent_id`,
+			a.ID, a.Name, a.ShortName, a.SortableName, parentID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Duplicate references are kept, as in the source data (N:N mapping)
+		for _, ref := range a.CFRRefs {
+			_, err := tx.Exec(`
+				INSERT INTO agency_cfr_references (agency_id, title, subtitle, chapter, subchapter, part)
+				VALUES ($1, $2, $3, $4, $5, $6)`,
+				a.ID, ref.Title, ref.Subtitle, ref.Chapter, ref.Subchapter, ref.Part)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Removed agencies may be each other's parents; detach them all before deleting any
+	for _, c := range changes {
+		if c.Kind == domain.AgencyRemoved {
+			if _, err := tx.Exec(`UPDATE agencies SET parent_id = NULL WHERE id = $1`, c.AgencyID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, c := range changes {
+		if c.Kind == domain.AgencyRemoved {
+			if _, err := tx.Exec(`DELETE FROM agencies WHERE id = $1`, c.AgencyID); err != nil {
+				return nil, err
+			}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO agency_changes (agency_id, kind, old_value, new_value, changed_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			c.AgencyID, c.Kind, c.OldValue, c.NewValue, c.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return changes, tx.Commit()
+}
+
+// loadAgencyEntries reads the agencies table as the entries DiffAgencies compares.
+func loadAgencyEntries(tx *sql.Tx) ([]ecfr.AgencyEntry, error) {
+	rows, err := tx.Query(`SELECT id, name, COALESCE(parent_id, '') FROM agencies ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []ecfr.AgencyEntry
+	for rows.Next() {
+		var a ecfr.AgencyEntry
+		if err := rows.Scan(&a.ID, &a.Name, &a.ParentID); err != nil {
+			return nil, err
+		}
+		entries = append(entries, a)
+	}
+	return entries, rows.Err()
+}
+
+// GetAgencyChanges lists the recorded changes to an agency, oldest first. It returns
+// domain.ErrNotFound for an agency that neither exists nor ever did.
+func (r *Repo) GetAgencyChanges(agencyID string) ([]domain.AgencyChange, error) {
+	rows, err := r.db.Query(`
+		SELECT agency_id, kind, old_value, new_value, changed_at
+		FROM agency_changes
+		WHERE agency_id = $1
+		ORDER BY changed_at, id`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []domain.AgencyChange
+	for rows.Next() {
+		var c domain.AgencyChange
+		if err := rows.Scan(&c.AgencyID, &c.Kind, &c.OldValue, &c.NewValue, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil || len(changes) > 0 {
+		return changes, err
+	}
+	var n int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM agencies WHERE id = $1`, agencyID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w: agency %q", domain.ErrNotFound, agencyID)
+	}
+	return []domain.AgencyChange{}, nil
+}
+
+// GetAgencies lists every agency, by ID.
+func (r *Repo) GetAgencies() ([]domain.Agency, error) {
+	rows, err := r.db.Query(`
+		SELECT id, name, COALESCE(short_name, ''), COALESCE(sortable_name, ''), parent_id
+		FROM agencies
+		ORDER BY id COLLATE "C"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var agencies []domain.Agency
+	for rows.Next() {
+		var a domain.Agency
+		if err := rows.Scan(&a.ID, &a.Name, &a.ShortName, &a.SortableName, &a.ParentID); err != nil {
+			return nil, err
+		}
+		agencies = append(agencies, a)
+	}
+	return agencies, rows.Err()
+}
+
+// ReplaceFRAgencyMap replaces the stored Federal Register agency matches, in one transaction so
+// LSA counts never drop to zero while it runs.
+func (r *Repo) ReplaceFRAgencyMap(matches []domain.FRAgencyMatch) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM fr_agency_map`); err != nil {
+		return err
+	}
+	for _, m := range matches {
+		_, err := tx.Exec(`
+			INSERT INTO fr_agency_map (fr_slug, fr_name, agency_id, match_kind)
+			VALUES ($1, $2, $3, $4)`,
+			m.FRSlug, m.FRName, m.AgencyID, m.MatchKind)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// resolvePartOwners fills part_owners from cfr_parts and the agencies' CFR references. A part
+// belongs to the agencies whose references match it most narrowly: a part reference beats a
+// subchapter one, which beats a chapter one, which beats a subtitle one. Agencies tied at that
+// level own the part jointly. A part whose subtitle is not known, because its title has not been
+// loaded since cfr_parts was added, matches any subtitle reference of its title.
+const resolvePartOwners = `
+	INSERT INTO part_owners (title, part, agency_id, source)
+	SELECT DISTINCT title, part, agency_id, source
+	FROM (
+		SELECT p.title, p.part, acr.agency_id, acr.source, acr.level,
+			MAX(acr.level) OVER (PARTITION BY p.title, p.part) AS best
+		FROM cfr_parts p
+		JOIN (
+			SELECT agency_id, CAST(title AS TEXT) AS title, subtitle, chapter, subchapter, part,
+				CASE WHEN part <> '' THEN 4 WHEN subchapter <> '' THEN 3 WHEN chapter <> '' THEN 2 ELSE 1 END AS level,
+				CASE WHEN part <> '' THEN 'part' WHEN subchapter <> '' THEN 'subchapter' WHEN chapter <> '' THEN 'chapter' ELSE 'subtitle' END AS source
+			FROM agency_cfr_references
+			WHERE subtitle <> '' OR chapter <> '' OR subchapter <> '' OR part <> ''
+		) acr
+			ON acr.title = p.title
+			AND (acr.subtitle = '' OR acr.subtitle = p.subtitle OR p.subtitle = '')
+			AND (acr.chapter = '' OR acr.chapter = p.chapter)
+			AND (acr.subchapter = '' OR acr.subchapter = p.subchapter)
+			AND (acr.part = '' OR acr.part = p.part)
+	) matches
+	WHERE level = best`
+
+// ResolvePartOwners rebuilds part_owners, which every agency metric joins sections through, from
+// the loaded parts and the agencies' CFR references. Each override then replaces the resolved
+// owners of its part. An override naming an unknown agency is domain.ErrInvalidData.
+func (r *Repo) ResolvePartOwners(overrides []domain.PartOwnerOverride) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM part_owners`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(resolvePartOwners); err != nil {
+		return err
+	}
+
+	overridden := make(map[[2]string]bool)
+	for _, o := range overrides {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM agencies WHERE id = $1`, o.AgencyID).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: override for title %s part %s names unknown agency %q", domain.ErrInvalidData, o.Title, o.Part, o.AgencyID)
+		}
+		if key := [2]string{o.Title, o.Part}; !overridden[key] {
+			overridden[key] = true
+			if _, err := tx.Exec(`DELETE FROM part_owners WHERE title = $1 AND part = $2`, o.Title, o.Part); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`INSERT INTO part_owners (title, part, agency_id, source) VALUES ($1, $2, $3, 'override') ON CONFLICT DO NOTHING`,
+			o.Title, o.Part, o.AgencyID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAgencyDetail returns an agency's metrics as GetAgencySubtree computes them, its names,
+// parent and direct children, its CFR references, and its totals per title and per owned part.
+// With opts.Rollup, the per-title and per-part totals include the agency's descendants. It
+// returns domain.ErrNotFound for an unknown agency. The LSA record and summary are left empty.
+func (r *Repo) GetAgencyDetail(agencyID string, opts domain.AgencyMetricsOptions) (*domain.AgencyDetail, error) {
+	subtree, err := r.GetAgencySubtree(agencyID, opts)
+	if err != nil {
+		return nil, err
+	}
+	column, aggregation, err := rscsColumn(opts.Aggregation)
+	if err != nil {
+		return nil, err
+	}
+	d := &domain.AgencyDetail{
+		AgencyMetric:  subtree[0],
+		Children:      []domain.AgencyMetric{},
+		CFRReferences: []domain.AgencyCFRReference{},
+		Titles:        []domain.AgencyTitleMetric{},
+		Parts:         []domain.AgencyPartMetric{},
+	}
+	for _, a := range subtree[1:] {
+		if a.ParentID != nil && *a.ParentID == agencyID {
+			d.Children = append(d.Children, a)
+		}
+	}
+
+	var parentName sql.NullString
+	err = r.db.QueryRow(`
+		SELECT COALESCE(a.short_name, ''), COALESCE(a.sortable_name, ''), p.name
+		FROM agencies a
+		LEFT JOIN agencies p ON p.id = a.parent_id
+		WHERE a.id = $1`, agencyID).Scan(&d.ShortName, &d.SortableName, &parentName)
+	if err != nil {
+		return nil, err
+	}
+	if d.ParentID != nil {
+		d.Parent = &domain.AgencyRef{ID: *d.ParentID, Name: parentName.String}
+	}
+
+	rows, err := r.db.Query(`
+		SELECT DISTINCT title, COALESCE(subtitle, '') AS subtitle, COALESCE(chapter, '') AS chapter,
+			COALESCE(subchapter, '') AS subchapter, COALESCE(part, '') AS part
+		FROM agency_cfr_references
+		WHERE agency_id = $1
+		ORDER BY title, subtitle, chapter, subchapter, part`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ref domain.AgencyCFRReference
+		if err := rows.Scan(&ref.Title, &ref.Subtitle, &ref.Chapter, &ref.Subchapter, &ref.Part); err != nil {
+			return nil, err
+		}
+		d.CFRReferences = append(d.CFRReferences, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(`
+		SELECT m.title, COALESCE(t.name, ''), COALESCE(m.total_words, 0), COALESCE(m.total_rscs, 0),
+			COALESCE(m.`+column+`, 0), COALESCE(m.restrictions, 0), COALESCE(m.section_count, 0)
+		FROM agency_metrics m
+		LEFT JOIN titles t ON t.title = m.title
+		WHERE m.agency_id = $1 AND m.scope = $2 AND m.title <> ''
+		ORDER BY COALESCE(m.total_rscs, 0) DESC, LENGTH(m.title), m.title`, agencyID, metricScope(opts.Rollup))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t domain.AgencyTitleMetric
+		if err := rows.Scan(&t.Title, &t.TitleName, &t.TotalWords, &t.TotalRSCS, &t.AvgRSCS, &t.Restrictions, &t.SectionCount); err != nil {
+			return nil, err
+		}
+		d.Titles = append(d.Titles, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Each part once, however many of the agencies in scope own it, aggregated on read
+	rows, err = r.db.Query(`
+		WITH RECURSIVE subtree(id) AS (
+			SELECT $1::text
+			UNION
+			SELECT a.id FROM agencies a JOIN subtree ON a.parent_id = subtree.id WHERE $2::boolean
+		),
+		parts AS (
+			SELECT DISTINCT po.title, po.part FROM part_owners po JOIN subtree ON subtree.id = po.agency_id
+		)
+		SELECT s.title, s.part, COALESCE(SUM(s.word_count), 0), COALESCE(SUM(s.rscs_raw), 0),
+			COALESCE(`+partRSCS[aggregation]+`, 0), COUNT(*)
+		FROM parts p
+		JOIN current_sections s ON s.title = p.title AND s.part = p.part
+		GROUP BY s.title, s.part
+		ORDER BY COALESCE(SUM(s.rscs_raw), 0) DESC, s.title, s.part`, agencyID, opts.Rollup)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p domain.AgencyPartMetric
+		if err := rows.Scan(&p.Title, &p.Part, &p.TotalWords, &p.TotalRSCS, &p.AvgRSCS, &p.SectionCount); err != nil {
+			return nil, err
+		}
+		d.Parts = append(d.Parts, p)
+	}
+	return d, rows.Err()
+}
+
+// partRSCS is the SQL for each RSCS aggregation over a part's sections
+var partRSCS = map[string]string{
+	domain.RSCSAggregationMean:         "AVG(s.rscs_per_1k)",
+	domain.RSCSAggregationWeightedMean: "SUM(s.rscs_per_1k * s.word_count) / NULLIF(SUM(s.word_count) FILTER (WHERE s.rscs_per_1k IS NOT NULL), 0)",
+	domain.RSCSAggregationMedian:       "percentile_cont(0.5) WITHIN GROUP (ORDER BY s.rscs_per_1k)",
+	domain.RSCSAggregationP90:          "percentile_cont(0.9) WITHIN GROUP (ORDER BY s.rscs_per_1k)",
+	domain.RSCSAggregationTotalPer1K:   "(1000.0 * SUM(s.rscs_raw) / NULLIF(SUM(s.word_count), 0))::double precision",
+}
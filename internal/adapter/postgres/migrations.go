@@ -0,0 +1,615 @@
+package postgres
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// migrationLock is the advisory lock key held while migrating, so replicas starting together
+// apply each migration once between them.
+const migrationLock = 0x65636672 // "ecfr"
+
+// migration is one versioned step of the schema. up and down each run in their own
+// transaction, together with the schema_migrations bookkeeping. Append new migrations to the
+// end; never edit or reorder ones that have shipped.
+type migration struct {
+	version int
+	name    string
+	up      []string
+	down    []string
+}
+
+// The schema matches the SQLite store's as of its migration 14, so both back the same use cases.
+// Snapshot IDs and days sort bytewise, as in SQLite, whatever the database's locale.
+var migrations = []migration{
+	{1, "initial schema", []string{
+		`CREATE TABLE agencies (
+			id               TEXT PRIMARY KEY,
+			name             TEXT NOT NULL,
+			short_name       TEXT,
+			sortable_name    TEXT,
+			parent_id        TEXT REFERENCES agencies(id),
+			content_checksum TEXT
+		)`,
+
+		// Agency CFR references (N:N mapping - no PK)
+		`CREATE TABLE agency_cfr_references (
+			agency_id TEXT NOT NULL REFERENCES agencies(id),
+			title     INTEGER NOT NULL,
+			chapter   TEXT NOT NULL
+		)`,
+		`CREATE INDEX idx_acr_title_chapter ON agency_cfr_references(title, chapter)`,
+		`CREATE INDEX idx_acr_agency ON agency_cfr_references(agency_id)`,
+
+		// Each distinct section text once, keyed by its SHA-256
+		`CREATE TABLE section_texts (
+			text_sha256 TEXT PRIMARY KEY,
+			text        TEXT NOT NULL
+		)`,
+
+		// One row per section per snapshot, keyed by domain.SectionKey
+		`CREATE TABLE section_versions (
+			section_key     TEXT NOT NULL,
+			snapshot_id     TEXT COLLATE "C" NOT NULL,
+			id              TEXT,
+			title           TEXT,
+			part            TEXT,
+			section         TEXT,
+			agency_id       TEXT,
+			path            TEXT,
+			rev_date        TIMESTAMPTZ,
+			checksum_sha256 TEXT,
+			text_sha256     TEXT,
+			word_count      INTEGER,
+			def_count       INTEGER,
+			xref_count      INTEGER,
+			modal_count     INTEGER,
+			rscs_raw        INTEGER,
+			rscs_per_1k     DOUBLE PRECISION,
+			snapshot_date   TEXT,
+			PRIMARY KEY (section_key, snapshot_id)
+		)`,
+		`CREATE INDEX idx_section_versions_title_snapshot ON section_versions(title, snapshot_id)`,
+		`CREATE INDEX idx_section_versions_title_agency ON section_versions(title, agency_id)`,
+
+		currentSectionsView,
+
+		`CREATE TABLE lsa_activity (
+			id            BIGSERIAL PRIMARY KEY,
+			title         TEXT NOT NULL,
+			snapshot_date TEXT COLLATE "C" NOT NULL,
+			proposals     INTEGER DEFAULT 0,
+			amendments    INTEGER DEFAULT 0,
+			finals        INTEGER DEFAULT 0,
+			captured_at   TIMESTAMPTZ,
+			source_hint   TEXT,
+			UNIQUE(title, snapshot_date)
+		)`,
+
+		// Per-agency LSA data from Federal Register API
+		`CREATE TABLE agency_lsa (
+			id              BIGSERIAL PRIMARY KEY,
+			agency_id       TEXT NOT NULL,
+			agency_name     TEXT NOT NULL,
+			proposed_rules  INTEGER DEFAULT 0,
+			final_rules     INTEGER DEFAULT 0,
+			notices         INTEGER DEFAULT 0,
+			total_documents INTEGER DEFAULT 0,
+			snapshot_date   TEXT COLLATE "C" NOT NULL,
+			captured_at     TIMESTAMPTZ,
+			source_hint     TEXT,
+			UNIQUE(agency_id, snapshot_date)
+		)`,
+		`CREATE INDEX idx_agency_lsa_snapshot ON agency_lsa(snapshot_date)`,
+
+		// AI-generated summaries
+		`CREATE TABLE summaries (
+			id         BIGSERIAL PRIMARY KEY,
+			kind       TEXT NOT NULL DEFAULT 'title',
+			key        TEXT NOT NULL,
+			text       TEXT NOT NULL,
+			model      TEXT DEFAULT 'gemini-2.5-pro',
+			created_at TIMESTAMPTZ DEFAULT now(),
+			UNIQUE(kind, key)
+		)`,
+
+		// Per-agency rollups captured at each snapshot
+		`CREATE TABLE agency_metrics_history (
+			agency_id     TEXT NOT NULL,
+			snapshot_id   TEXT COLLATE "C" NOT NULL,
+			total_words   BIGINT DEFAULT 0,
+			total_rscs    BIGINT DEFAULT 0,
+			avg_rscs      DOUBLE PRECISION DEFAULT 0,
+			restrictions  BIGINT DEFAULT 0,
+			section_count BIGINT DEFAULT 0,
+			computed_at   TIMESTAMPTZ,
+			PRIMARY KEY (agency_id, snapshot_id)
+		)`,
+		`CREATE INDEX idx_amh_snapshot ON agency_metrics_history(snapshot_id)`,
+
+		// Changed sections found at each snapshot
+		`CREATE TABLE section_diffs (
+			snapshot_id         TEXT COLLATE "C" NOT NULL,
+			prev_snapshot_id    TEXT,
+			title               TEXT NOT NULL,
+			section_id          TEXT NOT NULL,
+			part                TEXT,
+			agency_id           TEXT,
+			status              TEXT NOT NULL,
+			words_before        INTEGER DEFAULT 0,
+			words_after         INTEGER DEFAULT 0,
+			restrictions_before INTEGER DEFAULT 0,
+			restrictions_after  INTEGER DEFAULT 0,
+			PRIMARY KEY (snapshot_id, title, section_id)
+		)`,
+		`CREATE INDEX idx_section_diffs_title_agency ON section_diffs(title, agency_id)`,
+
+		// Changed sections linked to Federal Register documents
+		`CREATE TABLE diff_attributions (
+			snapshot_id      TEXT COLLATE "C" NOT NULL,
+			title            TEXT NOT NULL,
+			section_id       TEXT NOT NULL,
+			document_number  TEXT NOT NULL,
+			document_title   TEXT,
+			publication_date TIMESTAMPTZ,
+			html_url         TEXT,
+			match_kind       TEXT,
+			PRIMARY KEY (snapshot_id, title, section_id, document_number)
+		)`,
+
+		// Points each day at the snapshot readers should use
+		`CREATE TABLE published_snapshots (
+			day          TEXT PRIMARY KEY,
+			snapshot_id  TEXT COLLATE "C" NOT NULL,
+			published_at TIMESTAMPTZ
+		)`,
+	}, []string{
+		`DROP TABLE published_snapshots`,
+		`DROP TABLE diff_attributions`,
+		`DROP TABLE section_diffs`,
+		`DROP TABLE agency_metrics_history`,
+		`DROP TABLE summaries`,
+		`DROP TABLE agency_lsa`,
+		`DROP TABLE lsa_activity`,
+		`DROP VIEW current_sections`,
+		`DROP TABLE section_versions`,
+		`DROP TABLE section_texts`,
+		`DROP TABLE agency_cfr_references`,
+		`DROP TABLE agencies`,
+	}},
+	// current_sections expanded v.* when it was created, so it is recreated to pick up the column
+	{2, "section headings", []string{
+		`ALTER TABLE section_versions ADD COLUMN heading TEXT`,
+		`DROP VIEW current_sections`,
+		currentSectionsView,
+	}, []string{
+		`DROP VIEW current_sections`,
+		`ALTER TABLE section_versions DROP COLUMN heading`,
+		currentSectionsView,
+	}},
+	{3, "agency metrics", []string{
+		// Per-agency rollups as of the last snapshot, read by /api/agencies. title '' holds the
+		// agency across all of its titles.
+		`CREATE TABLE agency_metrics (
+			agency_id     TEXT NOT NULL,
+			title         TEXT NOT NULL,
+			snapshot_id   TEXT COLLATE "C" NOT NULL,
+			total_words   BIGINT DEFAULT 0,
+			total_rscs    BIGINT DEFAULT 0,
+			avg_rscs      DOUBLE PRECISION DEFAULT 0,
+			restrictions  BIGINT DEFAULT 0,
+			section_count BIGINT DEFAULT 0,
+			computed_at   TIMESTAMPTZ,
+			PRIMARY KEY (agency_id, title)
+		)`,
+		`CREATE INDEX idx_agency_metrics_title ON agency_metrics(title)`,
+		// Filled from the current sections so the API has totals before the next ETL run
+		backfillAgencyMetrics("s.title", "acr.agency_id, s.title"),
+		backfillAgencyMetrics("''", "acr.agency_id"),
+	}, []string{
+		`DROP TABLE agency_metrics`,
+	}},
+	{4, "agency metric rollups", []string{
+		// The existing rows are an agency's own chapters
+		`ALTER TABLE agency_metrics ADD COLUMN scope TEXT NOT NULL DEFAULT 'direct'`,
+		`ALTER TABLE agency_metrics DROP CONSTRAINT agency_metrics_pkey`,
+		`ALTER TABLE agency_metrics ADD PRIMARY KEY (agency_id, title, scope)`,
+		`DROP INDEX idx_agency_metrics_title`,
+		`CREATE INDEX idx_agency_metrics_title ON agency_metrics(title, scope)`,
+		// Filled from the current sections so the API has them before the next ETL run
+		backfillAgencyRollups("s.title", "acr.agency_id, s.title"),
+		backfillAgencyRollups("''", "acr.agency_id"),
+	}, []string{
+		`DELETE FROM agency_metrics WHERE scope <> 'direct'`,
+		`ALTER TABLE agency_metrics DROP CONSTRAINT agency_metrics_pkey`,
+		`ALTER TABLE agency_metrics ADD PRIMARY KEY (agency_id, title)`,
+		`DROP INDEX idx_agency_metrics_title`,
+		`ALTER TABLE agency_metrics DROP COLUMN scope`,
+		`CREATE INDEX idx_agency_metrics_title ON agency_metrics(title)`,
+	}},
+	{5, "agency RSCS aggregations", []string{
+		`ALTER TABLE agency_metrics
+			ADD COLUMN weighted_avg_rscs DOUBLE PRECISION DEFAULT 0,
+			ADD COLUMN median_rscs       DOUBLE PRECISION DEFAULT 0,
+			ADD COLUMN p90_rscs          DOUBLE PRECISION DEFAULT 0,
+			ADD COLUMN total_rscs_per_1k DOUBLE PRECISION DEFAULT 0`,
+		// Filled from the current sections so the API has them before the next ETL run
+		backfillRSCSAggregations("direct", "s.title", "acr.agency_id, s.title"),
+		backfillRSCSAggregations("direct", "''", "acr.agency_id"),
+		backfillRSCSAggregations("rollup", "s.title", "acr.agency_id, s.title"),
+		backfillRSCSAggregations("rollup", "''", "acr.agency_id"),
+	}, []string{
+		`ALTER TABLE agency_metrics
+			DROP COLUMN weighted_avg_rscs,
+			DROP COLUMN median_rscs,
+			DROP COLUMN p90_rscs,
+			DROP COLUMN total_rscs_per_1k`,
+	}},
+	{6, "part ownership", []string{
+		// References narrower or wider than a chapter; '' where the reference does not say
+		`ALTER TABLE agency_cfr_references
+			ADD COLUMN subtitle   TEXT NOT NULL DEFAULT '',
+			ADD COLUMN subchapter TEXT NOT NULL DEFAULT '',
+			ADD COLUMN part       TEXT NOT NULL DEFAULT ''`,
+		// Where each part sits in its title, as last loaded
+		`CREATE TABLE cfr_parts (
+			title      TEXT NOT NULL,
+			part       TEXT NOT NULL,
+			subtitle   TEXT NOT NULL DEFAULT '',
+			chapter    TEXT NOT NULL DEFAULT '',
+			subchapter TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (title, part)
+		)`,
+		// The agencies owning each part, which every agency metric joins sections through
+		`CREATE TABLE part_owners (
+			title     TEXT NOT NULL,
+			part      TEXT NOT NULL,
+			agency_id TEXT NOT NULL,
+			source    TEXT NOT NULL,
+			PRIMARY KEY (title, part, agency_id)
+		)`,
+		`CREATE INDEX idx_part_owners_agency ON part_owners(agency_id)`,
+		// Until the next load records subtitles and subchapters, parts are owned by chapter, as
+		// before
+		`INSERT INTO cfr_parts (title, part, chapter)
+			SELECT title, part, COALESCE(MAX(agency_id), '')
+			FROM current_sections
+			WHERE COALESCE(part, '') <> ''
+			GROUP BY title, part`,
+		`INSERT INTO part_owners (title, part, agency_id, source)
+			SELECT DISTINCT p.title, p.part, acr.agency_id, 'chapter'
+			FROM cfr_parts p
+			JOIN agency_cfr_references acr
+				ON CAST(acr.title AS TEXT) = p.title
+				AND acr.chapter = p.chapter`,
+	}, []string{
+		`DROP TABLE part_owners`,
+		`DROP TABLE cfr_parts`,
+		`ALTER TABLE agency_cfr_references
+			DROP COLUMN subtitle,
+			DROP COLUMN subchapter,
+			DROP COLUMN part`,
+	}},
+	{7, "agency changes", []string{
+		// What each agency sync added, renamed, re-parented and removed
+		`CREATE TABLE agency_changes (
+			id         BIGSERIAL PRIMARY KEY,
+			agency_id  TEXT NOT NULL,
+			kind       TEXT NOT NULL,
+			old_value  TEXT NOT NULL DEFAULT '',
+			new_value  TEXT NOT NULL DEFAULT '',
+			changed_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX idx_agency_changes_agency ON agency_changes(agency_id, changed_at)`,
+	}, []string{
+		`DROP TABLE agency_changes`,
+	}},
+	{8, "federal register agency map", []string{
+		// Which eCFR agency each Federal Register agency is; agency_id is '' when unmatched
+		`CREATE TABLE fr_agency_map (
+			fr_slug    TEXT PRIMARY KEY,
+			fr_name    TEXT NOT NULL DEFAULT '',
+			agency_id  TEXT NOT NULL,
+			match_kind TEXT NOT NULL
+		)`,
+		`CREATE INDEX idx_fr_agency_map_agency ON fr_agency_map(agency_id)`,
+		// LSA counts were joined to agencies by slug until the first reconciliation
+		`INSERT INTO fr_agency_map (fr_slug, fr_name, agency_id, match_kind)
+			SELECT l.agency_id, COALESCE(MAX(l.agency_name), ''), l.agency_id, 'slug'
+			FROM agency_lsa l
+			JOIN agencies a ON a.id = l.agency_id
+			GROUP BY l.agency_id`,
+	}, []string{
+		`DROP TABLE fr_agency_map`,
+	}},
+	{9, "titles", []string{
+		// Title metadata from the eCFR; dates are YYYY-MM-DD, '' when the eCFR gives none
+		`CREATE TABLE titles (
+			title             TEXT PRIMARY KEY,
+			name              TEXT NOT NULL,
+			latest_amended_on TEXT NOT NULL DEFAULT '',
+			latest_issue_date TEXT NOT NULL DEFAULT '',
+			up_to_date_as_of  TEXT NOT NULL DEFAULT '',
+			reserved          BOOLEAN NOT NULL DEFAULT false
+		)`,
+		// Totals of each title's current sections, refreshed whenever a title is loaded
+		`CREATE TABLE title_metrics (
+			title         TEXT PRIMARY KEY,
+			snapshot_id   TEXT COLLATE "C" NOT NULL,
+			total_words   BIGINT NOT NULL,
+			total_rscs    BIGINT NOT NULL,
+			avg_rscs      DOUBLE PRECISION NOT NULL,
+			restrictions  BIGINT NOT NULL,
+			section_count BIGINT NOT NULL
+		)`,
+		`INSERT INTO title_metrics (title, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count)
+			SELECT title, MAX(snapshot_id), COALESCE(SUM(word_count), 0), COALESCE(SUM(rscs_raw), 0),
+				COALESCE(AVG(rscs_per_1k), 0), COALESCE(SUM(modal_count), 0), COUNT(*)
+			FROM current_sections
+			WHERE title IS NOT NULL
+			GROUP BY title`,
+	}, []string{
+		`DROP TABLE title_metrics`,
+		`DROP TABLE titles`,
+	}},
+	{10, "agency metrics history aggregations", []string{
+		`ALTER TABLE agency_metrics_history
+			ADD COLUMN weighted_avg_rscs DOUBLE PRECISION DEFAULT 0,
+			ADD COLUMN median_rscs       DOUBLE PRECISION DEFAULT 0,
+			ADD COLUMN p90_rscs          DOUBLE PRECISION DEFAULT 0,
+			ADD COLUMN total_rscs_per_1k DOUBLE PRECISION DEFAULT 0`,
+		// Earlier snapshots' sections are gone; only the latest one can be filled in
+		`UPDATE agency_metrics_history h SET
+			weighted_avg_rscs = m.weighted_avg_rscs,
+			median_rscs = m.median_rscs,
+			p90_rscs = m.p90_rscs,
+			total_rscs_per_1k = m.total_rscs_per_1k
+		FROM agency_metrics m
+		WHERE m.agency_id = h.agency_id AND m.snapshot_id = h.snapshot_id AND m.title = '' AND m.scope = 'direct'`,
+	}, []string{
+		`ALTER TABLE agency_metrics_history
+			DROP COLUMN weighted_avg_rscs,
+			DROP COLUMN median_rscs,
+			DROP COLUMN p90_rscs,
+			DROP COLUMN total_rscs_per_1k`,
+	}},
+}
+
+func backfillRSCSAggregations(scope, title, groupBy string) string {
+	chapters := `SELECT DISTINCT agency_id, title, chapter FROM agency_cfr_references`
+	if scope == "rollup" {
+		chapters = `
+			WITH RECURSIVE subtree(root, agency_id) AS (
+				SELECT id, id FROM agencies
+				UNION
+				SELECT subtree.root, a.id FROM agencies a JOIN subtree ON a.parent_id = subtree.agency_id
+			)
+			SELECT DISTINCT subtree.root AS agency_id, acr.title, acr.chapter
+			FROM subtree
+			JOIN agency_cfr_references acr ON acr.agency_id = subtree.agency_id`
+	}
+	return fmt.Sprintf(`
+		UPDATE agency_metrics m SET
+			weighted_avg_rscs = x.weighted_avg_rscs,
+			median_rscs = x.median_rscs,
+			p90_rscs = x.p90_rscs,
+			total_rscs_per_1k = x.total_rscs_per_1k
+		FROM (
+			SELECT
+				acr.agency_id,
+				%s AS title,
+				COALESCE(SUM(s.rscs_per_1k * s.word_count) / NULLIF(SUM(s.word_count) FILTER (WHERE s.rscs_per_1k IS NOT NULL), 0), 0) AS weighted_avg_rscs,
+				COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY s.rscs_per_1k), 0) AS median_rscs,
+				COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY s.rscs_per_1k), 0) AS p90_rscs,
+				COALESCE(1000.0 * SUM(s.rscs_raw) / NULLIF(SUM(s.word_count), 0), 0) AS total_rscs_per_1k
+			FROM (%s) acr
+			JOIN current_sections s
+				ON s.title = CAST(acr.title AS TEXT)
+				AND s.agency_id = acr.chapter
+			GROUP BY %s
+		) x
+		WHERE m.agency_id = x.agency_id AND m.title = x.title AND m.scope = '%s'`, title, chapters, groupBy, scope)
+}
+
+func backfillAgencyRollups(title, groupBy string) string {
+	return fmt.Sprintf(`
+		INSERT INTO agency_metrics
+		(agency_id, title, scope, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count, computed_at)
+		SELECT
+			acr.agency_id,
+			%s,
+			'rollup',
+			COALESCE((SELECT MAX(snapshot_id) FROM section_versions), ''),
+			COALESCE(SUM(s.word_count), 0),
+			COALESCE(SUM(s.rscs_raw), 0),
+			COALESCE(AVG(s.rscs_per_1k), 0),
+			COALESCE(SUM(s.modal_count), 0),
+			COUNT(*),
+			now()
+		FROM (
+			WITH RECURSIVE subtree(root, agency_id) AS (
+				SELECT id, id FROM agencies
+				UNION
+				SELECT subtree.root, a.id FROM agencies a JOIN subtree ON a.parent_id = subtree.agency_id
+			)
+			SELECT DISTINCT subtree.root AS agency_id, acr.title, acr.chapter
+			FROM subtree
+			JOIN agency_cfr_references acr ON acr.agency_id = subtree.agency_id
+		) acr
+		JOIN current_sections s
+			ON s.title = CAST(acr.title AS TEXT)
+			AND s.agency_id = acr.chapter
+		GROUP BY %s`, title, groupBy)
+}
+
+func backfillAgencyMetrics(title, groupBy string) string {
+	return fmt.Sprintf(`
+		INSERT INTO agency_metrics
+		(agency_id, title, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count, computed_at)
+		SELECT
+			acr.agency_id,
+			%s,
+			COALESCE((SELECT MAX(snapshot_id) FROM section_versions), ''),
+			COALESCE(SUM(s.word_count), 0),
+			COALESCE(SUM(s.rscs_raw), 0),
+			COALESCE(AVG(s.rscs_per_1k), 0),
+			COALESCE(SUM(s.modal_count), 0),
+			COUNT(*),
+			now()
+		FROM (SELECT DISTINCT agency_id, title, chapter FROM agency_cfr_references) acr
+		JOIN current_sections s
+			ON s.title = CAST(acr.title AS TEXT)
+			AND s.agency_id = acr.chapter
+		GROUP BY %s`, title, groupBy)
+}
+
+// Each title's sections as of the latest snapshot that loaded it
+const currentSectionsView = `CREATE VIEW current_sections AS
+	SELECT v.*
+	FROM section_versions v
+	JOIN (SELECT title, MAX(snapshot_id) AS snapshot_id FROM section_versions GROUP BY title) latest
+		ON latest.title = v.title
+		AND latest.snapshot_id = v.snapshot_id`
+
+// MigrationStatus reports whether one migration has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+func (r *Repo) ensureMigrationsTable() error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Concurrent CREATE TABLE IF NOT EXISTS can still collide in Postgres, so hold the lock
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus lists every known migration, oldest first, with when it was applied.
+func (r *Repo) MigrationStatus() ([]MigrationStatus, error) {
+	if err := r.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i] = MigrationStatus{Version: m.version, Name: m.name}
+		if at, ok := applied[m.version]; ok {
+			status[i].AppliedAt = &at
+		}
+	}
+	return status, nil
+}
+
+// MigrateUp applies every pending migration in order and returns how many ran.
+func (r *Repo) MigrateUp() (int, error) {
+	if err := r.ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+	ran := 0
+	for _, m := range migrations {
+		applied, err := r.runMigration(m, true)
+		if err != nil {
+			return ran, fmt.Errorf("%w: migration %d (%s): %v", domain.ErrPersistence, m.version, m.name, err)
+		}
+		if applied {
+			ran++
+		}
+	}
+	return ran, nil
+}
+
+// MigrateDown reverts the most recently applied migrations, up to steps of them, and returns
+// how many were reverted.
+func (r *Repo) MigrateDown(steps int) (int, error) {
+	if err := r.ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+	ran := 0
+	for i := len(migrations) - 1; i >= 0 && ran < steps; i-- {
+		m := migrations[i]
+		reverted, err := r.runMigration(m, false)
+		if err != nil {
+			return ran, fmt.Errorf("%w: reverting migration %d (%s): %v", domain.ErrPersistence, m.version, m.name, err)
+		}
+		if reverted {
+			ran++
+		}
+	}
+	return ran, nil
+}
+
+// runMigration applies (up) or reverts a migration unless that has already happened. The check
+// runs under the advisory lock, so a concurrent migrator cannot run it twice.
+func (r *Repo) runMigration(m migration, up bool) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return false, err
+	}
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, m.version).Scan(&n); err != nil {
+		return false, err
+	}
+	if up == (n > 0) {
+		return false, nil
+	}
+
+	stmts := m.down
+	if up {
+		stmts = m.up
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return false, err
+		}
+	}
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`, m.version, m.name, time.Now().UTC())
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, m.version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,854 @@
+package postgres
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlquery"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Repo is the PostgreSQL store. It holds the same tables as the SQLite store and answers the
+// same queries, so several API replicas can share one database.
+type Repo struct {
+	*sqlquery.Queries // Queries whose SQL SQLite shares: titles and section details
+
+	db *sql.DB
+}
+
+// NewRepo connects to the database at dsn and applies any pending migrations.
+func NewRepo(dsn string) (*Repo, error) {
+	r, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.MigrateUp(); err != nil {
+		r.db.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Open connects to the database at dsn without migrating it, for tools that manage migrations
+// themselves.
+func Open(dsn string) (*Repo, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Repo{Queries: sqlquery.NewPostgres(db), db: db}, nil
+}
+
+func (r *Repo) Close() error {
+	return r.db.Close()
+}
+
+// InsertSections stores one version of each section per snapshot in section_versions, keyed by
+// domain.SectionKey, with text kept once per text hash in section_texts. Loading a section again
+// under the same snapshot replaces that version; other snapshots' versions are kept. Each part's
+// place in the title's hierarchy is recorded in cfr_parts for ResolvePartOwners, and the loaded
+// titles' totals in title_metrics.
+func (r *Repo) InsertSections(sections []domain.Section) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	textStmt, err := tx.Prepare(`INSERT INTO section_texts (text_sha256, text) VALUES ($1, $2) ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return err
+	}
+	defer textStmt.Close()
+	stmt, err := tx.Prepare(`
+		INSERT INTO section_versions (section_key, snapshot_id, id, title, part, section, heading, agency_id, path, rev_date, checksum_sha256, text_sha256, word_count, def_count, xref_count, modal_count, rscs_raw, rscs_per_1k, snapshot_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		ON CONFLICT (section_key, snapshot_id) DO UPDATE SET
+			id = EXCLUDED.id, title = EXCLUDED.title, part = EXCLUDED.part, section = EXCLUDED.section, heading = EXCLUDED.heading,
+			agency_id = EXCLUDED.agency_id, path = EXCLUDED.path, rev_date = EXCLUDED.rev_date,
+			checksum_sha256 = EXCLUDED.checksum_sha256, text_sha256 = EXCLUDED.text_sha256,
+			word_count = EXCLUDED.word_count, def_count = EXCLUDED.def_count, xref_count = EXCLUDED.xref_count,
+			modal_count = EXCLUDED.modal_count, rscs_raw = EXCLUDED.rscs_raw, rscs_per_1k = EXCLUDED.rscs_per_1k,
+			snapshot_date = EXCLUDED.snapshot_date`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	partStmt, err := tx.Prepare(`
+		INSERT INTO cfr_parts (title, part, subtitle, chapter, subchapter)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (title, part) DO UPDATE SET
+			subtitle = EXCLUDED.subtitle, chapter = EXCLUDED.chapter, subchapter = EXCLUDED.subchapter`)
+	if err != nil {
+		return err
+	}
+	defer partStmt.Close()
+	titles := make(map[string]bool)
+	parts := make(map[[2]string]bool)
+	for _, s := range sections {
+		titles[s.Title] = true
+		if key := [2]string{s.Title, s.Part}; s.Part != "" && !parts[key] {
+			parts[key] = true
+			if _, err := partStmt.Exec(s.Title, s.Part, s.Subtitle, s.AgencyID, s.Subchapter); err != nil {
+				return err
+			}
+		}
+		textHash := s.TextSHA256
+		if textHash == "" {
+			textHash = domain.TextHash(s.Text)
+		}
+		if _, err := textStmt.Exec(textHash, s.Text); err != nil {
+			return err
+		}
+		section := s.Section
+		if section == "" {
+			section = s.ID
+		}
+		snapshotID := s.SnapshotID
+		if snapshotID == "" {
+			snapshotID = s.SnapshotDate
+		}
+		_, err = stmt.Exec(domain.SectionKey(s.Title, section), snapshotID, s.ID, s.Title, s.Part, s.Section, s.Heading, s.AgencyID, s.Path, s.RevDate, s.ChecksumSHA256, textHash, s.WordCount, s.DefCount, s.XrefCount, s.ModalCount, s.RSCSRaw, s.RSCSPer1K, s.SnapshotDate)
+		if err != nil {
+			return err
+		}
+	}
+	if err := r.RefreshTitleMetrics(tx, titles); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSectionText returns the section text with the given SHA-256 (domain.TextHash).
+func (r *Repo) GetSectionText(hash string) (string, error) {
+	var text string
+	err := r.db.QueryRow(`SELECT text FROM section_texts WHERE text_sha256 = $1`, hash).Scan(&text)
+	if err == sql.ErrNoRows {
+		return "", domain.ErrNotFound
+	}
+	return text, err
+}
+
+// GetAgencyTotals reads each agency's words and RSCS, aggregated as opts asks, from
+// agency_metrics as of the last SnapshotAgencyMetrics, optionally limited to one title. With
+// opts.Rollup, each agency's totals include all of its descendants. LSA counts are the agency's
+// own and never filtered by title.
+func (r *Repo) GetAgencyTotals(titleFilter *string, opts domain.AgencyMetricsOptions) ([]domain.AgencyMetric, error) {
+	column, aggregation, err := rscsColumn(opts.Aggregation)
+	if err != nil {
+		return nil, err
+	}
+	title := ""
+	if titleFilter != nil {
+		title = *titleFilter
+	}
+	query := `
+		WITH latest_agency_lsa AS (
+			-- Latest LSA counts of the Federal Register agencies each agency was matched to
+			SELECT fm.agency_id, SUM(l.total_documents) AS total_documents
+			FROM agency_lsa l
+			JOIN fr_agency_map fm ON fm.fr_slug = l.agency_id
+			WHERE l.snapshot_date = (SELECT MAX(snapshot_date) FROM agency_lsa) AND fm.agency_id <> ''
+			GROUP BY fm.agency_id
+		)
+		SELECT
+			a.id,
+			a.name,
+			a.parent_id,
+			COALESCE(m.total_words, 0) AS total_words,
+			COALESCE(m.` + column + `, 0) AS avg_rscs,
+			COALESCE(lsa.total_documents, 0) AS lsa_counts,
+			a.content_checksum
+		FROM agencies a
+		LEFT JOIN agency_metrics m ON m.agency_id = a.id AND m.title = $1 AND m.scope = $2
+		LEFT JOIN latest_agency_lsa lsa ON lsa.agency_id = a.id`
+	if title != "" {
+		query += " WHERE m.total_words > 0"
+	}
+	query += " ORDER BY total_words DESC"
+
+	rows, err := r.db.Query(query, title, metricScope(opts.Rollup))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []domain.AgencyMetric
+	for rows.Next() {
+		var m domain.AgencyMetric
+		var checksum sql.NullString
+		if err := rows.Scan(&m.ID, &m.Name, &m.ParentID, &m.TotalWords, &m.AvgRSCS, &m.LSACounts, &checksum); err != nil {
+			return nil, err
+		}
+		m.RSCSAggregation = aggregation
+		m.ContentChecksum = checksum.String
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// GetAgencySubtree returns an agency and all of its descendants with their agency-wide metrics,
+// parents before children and siblings by total words. With opts.Rollup, each node's totals
+// include its own descendants. It returns domain.ErrNotFound for an unknown agency.
+func (r *Repo) GetAgencySubtree(agencyID string, opts domain.AgencyMetricsOptions) ([]domain.AgencyMetric, error) {
+	column, aggregation, err := rscsColumn(opts.Aggregation)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(`
+		WITH RECURSIVE subtree(id, depth) AS (
+			SELECT id, 0 FROM agencies WHERE id = $1
+			UNION
+			SELECT a.id, subtree.depth + 1 FROM agencies a JOIN subtree ON a.parent_id = subtree.id
+		),
+		latest_agency_lsa AS (
+			-- Latest LSA counts of the Federal Register agencies each agency was matched to
+			SELECT fm.agency_id, SUM(l.total_documents) AS total_documents
+			FROM agency_lsa l
+			JOIN fr_agency_map fm ON fm.fr_slug = l.agency_id
+			WHERE l.snapshot_date = (SELECT MAX(snapshot_date) FROM agency_lsa) AND fm.agency_id <> ''
+			GROUP BY fm.agency_id
+		)
+		SELECT
+			a.id,
+			a.name,
+			a.parent_id,
+			COALESCE(m.total_words, 0) AS total_words,
+			COALESCE(m.`+column+`, 0) AS avg_rscs,
+			COALESCE(lsa.total_documents, 0) AS lsa_counts,
+			a.content_checksum
+		FROM subtree
+		JOIN agencies a ON a.id = subtree.id
+		LEFT JOIN agency_metrics m ON m.agency_id = a.id AND m.title = '' AND m.scope = $2
+		LEFT JOIN latest_agency_lsa lsa ON lsa.agency_id = a.id
+		ORDER BY subtree.depth, total_words DESC, a.id COLLATE "C"`, agencyID, metricScope(opts.Rollup))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []domain.AgencyMetric
+	for rows.Next() {
+		var m domain.AgencyMetric
+		var checksum sql.NullString
+		if err := rows.Scan(&m.ID, &m.Name, &m.ParentID, &m.TotalWords, &m.AvgRSCS, &m.LSACounts, &checksum); err != nil {
+			return nil, err
+		}
+		m.RSCSAggregation = aggregation
+		m.ContentChecksum = checksum.String
+		metrics = append(metrics, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("%w: agency %q", domain.ErrNotFound, agencyID)
+	}
+	return metrics, nil
+}
+
+func metricScope(rollup bool) string {
+	if rollup {
+		return scopeRollup
+	}
+	return scopeDirect
+}
+
+// The agency_metrics column holding each RSCS aggregation
+var rscsColumns = map[string]string{
+	domain.RSCSAggregationMean:         "avg_rscs",
+	domain.RSCSAggregationWeightedMean: "weighted_avg_rscs",
+	domain.RSCSAggregationMedian:       "median_rscs",
+	domain.RSCSAggregationP90:          "p90_rscs",
+	domain.RSCSAggregationTotalPer1K:   "total_rscs_per_1k",
+}
+
+// rscsColumn returns the column for an aggregation method and the method's name, with the mean
+// for an empty method.
+func rscsColumn(aggregation string) (string, string, error) {
+	if aggregation == "" {
+		aggregation = domain.RSCSAggregationMean
+	}
+	column, ok := rscsColumns[aggregation]
+	if !ok {
+		return "", "", fmt.Errorf("%w: unknown RSCS aggregation %q", domain.ErrInvalidData, aggregation)
+	}
+	return column, aggregation, nil
+}
+
+const upsertAgencyLSA = `
+	INSERT INTO agency_lsa
+	(agency_id, agency_name, proposed_rules, final_rules, notices, total_documents, snapshot_date, captured_at, source_hint)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (agency_id, snapshot_date) DO UPDATE SET
+		agency_name = EXCLUDED.agency_name, proposed_rules = EXCLUDED.proposed_rules,
+		final_rules = EXCLUDED.final_rules, notices = EXCLUDED.notices,
+		total_documents = EXCLUDED.total_documents, captured_at = EXCLUDED.captured_at,
+		source_hint = EXCLUDED.source_hint`
+
+// InsertAgencyLSA inserts or updates LSA activity data for an agency
+func (r *Repo) InsertAgencyLSA(lsa domain.AgencyLSA) error {
+	_, err := r.db.Exec(upsertAgencyLSA,
+		lsa.AgencyID, lsa.AgencyName, lsa.ProposedRules, lsa.FinalRules,
+		lsa.Notices, lsa.TotalDocuments, lsa.SnapshotDate, lsa.CapturedAt, lsa.SourceHint)
+	return err
+}
+
+// InsertAgencyLSABatch inserts multiple agency LSA records in a transaction
+func (r *Repo) InsertAgencyLSABatch(lsaRecords []domain.AgencyLSA) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(upsertAgencyLSA)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, lsa := range lsaRecords {
+		_, err = stmt.Exec(lsa.AgencyID, lsa.AgencyName, lsa.ProposedRules, lsa.FinalRules,
+			lsa.Notices, lsa.TotalDocuments, lsa.SnapshotDate, lsa.CapturedAt, lsa.SourceHint)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAgencyLSA retrieves the latest LSA data for an eCFR agency, through the Federal Register
+// agencies matched to it. When several are, it returns the one with the most documents.
+func (r *Repo) GetAgencyLSA(agencyID string) (*domain.AgencyLSA, error) {
+	var lsa domain.AgencyLSA
+	err := r.db.QueryRow(`
+		SELECT l.agency_id, l.agency_name, l.proposed_rules, l.final_rules, l.notices, l.total_documents, l.snapshot_date, l.captured_at, l.source_hint
+		FROM agency_lsa l
+		JOIN fr_agency_map fm ON fm.fr_slug = l.agency_id
+		WHERE fm.agency_id = $1
+		ORDER BY l.snapshot_date DESC, l.total_documents DESC
+		LIMIT 1`, agencyID).Scan(
+		&lsa.AgencyID, &lsa.AgencyName, &lsa.ProposedRules, &lsa.FinalRules,
+		&lsa.Notices, &lsa.TotalDocuments, &lsa.SnapshotDate, &lsa.CapturedAt, &lsa.SourceHint)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lsa, nil
+}
+
+// GetAllAgencyLSA retrieves the latest LSA data for all agencies
+func (r *Repo) GetAllAgencyLSA() ([]domain.AgencyLSA, error) {
+	rows, err := r.db.Query(`
+		SELECT agency_id, agency_name, proposed_rules, final_rules, notices, total_documents, snapshot_date, captured_at, source_hint
+		FROM agency_lsa
+		WHERE snapshot_date = (SELECT MAX(snapshot_date) FROM agency_lsa)
+		ORDER BY total_documents DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.AgencyLSA
+	for rows.Next() {
+		var lsa domain.AgencyLSA
+		if err := rows.Scan(&lsa.AgencyID, &lsa.AgencyName, &lsa.ProposedRules, &lsa.FinalRules,
+			&lsa.Notices, &lsa.TotalDocuments, &lsa.SnapshotDate, &lsa.CapturedAt, &lsa.SourceHint); err != nil {
+			return nil, err
+		}
+		results = append(results, lsa)
+	}
+	return results, rows.Err()
+}
+
+// HasRecentAgencyLSA checks if there is agency LSA data within the specified number of days
+func (r *Repo) HasRecentAgencyLSA(withinDays int) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM agency_lsa
+		WHERE snapshot_date >= to_char(CURRENT_DATE - $1::integer, 'YYYY-MM-DD')`, withinDays).Scan(&count)
+	return count > 0, err
+}
+
+// GetAgencyChecksum computes a SHA256 hash of all section content for an agency. Rows are
+// ordered bytewise, as SQLite orders them, so both stores produce the same checksum.
+func (r *Repo) GetAgencyChecksum(agencyID string) (string, error) {
+	rows, err := r.db.Query(`
+		SELECT COALESCE(st.text, '')
+		FROM current_sections s
+		LEFT JOIN section_texts st ON st.text_sha256 = s.text_sha256
+		JOIN part_owners po
+			ON po.title = s.title
+			AND po.part = s.part
+		WHERE po.agency_id = $1
+		ORDER BY s.id COLLATE "C", s.title COLLATE "C"`, agencyID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var combined strings.Builder
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return "", err
+		}
+		combined.WriteString(text)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if combined.Len() == 0 {
+		return "", nil
+	}
+
+	hash := sha256.Sum256([]byte(combined.String()))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// UpdateAgencyChecksum updates the pre-computed checksum for an agency
+func (r *Repo) UpdateAgencyChecksum(agencyID, checksum string) error {
+	_, err := r.db.Exec(`UPDATE agencies SET content_checksum = $1 WHERE id = $2`, checksum, agencyID)
+	return err
+}
+
+// GetAllAgencyIDs returns all agency IDs from the agencies table
+func (r *Repo) GetAllAgencyIDs() ([]string, error) {
+	rows, err := r.db.Query(`SELECT id FROM agencies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+const upsertSummary = `
+	INSERT INTO summaries (kind, key, text, model, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (kind, key) DO UPDATE SET
+		text = EXCLUDED.text, model = EXCLUDED.model, created_at = EXCLUDED.created_at`
+
+// InsertSummary inserts or updates a single summary
+func (r *Repo) InsertSummary(summary domain.Summary) error {
+	_, err := r.db.Exec(upsertSummary, summary.Kind, summary.Key, summary.Text, summary.Model, summary.CreatedAt)
+	return err
+}
+
+// InsertSummaries inserts or updates multiple summaries in a transaction
+func (r *Repo) InsertSummaries(summaries []domain.Summary) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(upsertSummary)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, s := range summaries {
+		if _, err := stmt.Exec(s.Kind, s.Key, s.Text, s.Model, s.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAllSummaries retrieves all summaries from the database
+func (r *Repo) GetAllSummaries() ([]domain.Summary, error) {
+	rows, err := r.db.Query(`
+		SELECT kind, key, text, model, created_at
+		FROM summaries
+		ORDER BY key COLLATE "C" ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []domain.Summary
+	for rows.Next() {
+		var s domain.Summary
+		if err := rows.Scan(&s.Kind, &s.Key, &s.Text, &s.Model, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// GetSummaryByKey retrieves a single summary by kind and key
+func (r *Repo) GetSummaryByKey(kind, key string) (*domain.Summary, error) {
+	var s domain.Summary
+	err := r.db.QueryRow(`
+		SELECT kind, key, text, model, created_at
+		FROM summaries
+		WHERE kind = $1 AND key = $2`, kind, key).Scan(&s.Kind, &s.Key, &s.Text, &s.Model, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Agency metric scopes: an agency's own parts, or those of its whole subtree
+const (
+	scopeDirect = "direct"
+	scopeRollup = "rollup"
+)
+
+// The (agency_id, title, part) rows each scope rolls up, from part_owners. Parts are distinct per
+// agency, so a parent and child owning the same part do not double count.
+var agencyParts = map[string]string{
+	scopeDirect: `SELECT agency_id, title, part FROM part_owners`,
+	scopeRollup: `
+		WITH RECURSIVE subtree(root, agency_id) AS (
+			SELECT id, id FROM agencies
+			UNION
+			SELECT subtree.root, a.id FROM agencies a JOIN subtree ON a.parent_id = subtree.agency_id
+		)
+		SELECT DISTINCT subtree.root AS agency_id, po.title, po.part
+		FROM subtree
+		JOIN part_owners po ON po.agency_id = subtree.agency_id`,
+}
+
+// rollupAgencyMetrics fills agency_metrics from the current sections, with the title expression,
+// GROUP BY list and agencyParts query substituted in.
+const rollupAgencyMetrics = `
+	INSERT INTO agency_metrics
+	(agency_id, title, scope, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs,
+		median_rscs, p90_rscs, total_rscs_per_1k, restrictions, section_count, computed_at)
+	SELECT
+		po.agency_id,
+		%[1]s,
+		$3::text,
+		$1::text,
+		COALESCE(SUM(s.word_count), 0),
+		COALESCE(SUM(s.rscs_raw), 0),
+		COALESCE(AVG(s.rscs_per_1k), 0),
+		COALESCE(SUM(s.rscs_per_1k * s.word_count) / NULLIF(SUM(s.word_count) FILTER (WHERE s.rscs_per_1k IS NOT NULL), 0), 0),
+		COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY s.rscs_per_1k), 0),
+		COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY s.rscs_per_1k), 0),
+		COALESCE(1000.0 * SUM(s.rscs_raw) / NULLIF(SUM(s.word_count), 0), 0),
+		COALESCE(SUM(s.modal_count), 0),
+		COUNT(*),
+		$2::timestamptz
+	FROM (%[3]s) po
+	JOIN current_sections s
+		ON s.title = po.title
+		AND s.part = po.part
+	GROUP BY %[2]s`
+
+// SnapshotAgencyMetrics rolls up the current sections into per-agency and per-(agency, title)
+// metrics, both for each agency's own parts and for its whole subtree, replacing
+// agency_metrics. It records the agency-wide rows of each agency's own parts in
+// agency_metrics_history under snapshotID and returns them. The ETL runs it once per snapshot, so API reads never aggregate
+// sections.
+func (r *Repo) SnapshotAgencyMetrics(snapshotID string, computedAt time.Time) ([]domain.AgencyMetricSnapshot, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM agency_metrics`); err != nil {
+		return nil, err
+	}
+	// One row per agency and title, then one across all of the agency's titles (title ''); the
+	// average is over sections either way
+	for _, scope := range []string{scopeDirect, scopeRollup} {
+		for _, rollup := range [][2]string{{"s.title", "po.agency_id, s.title"}, {"''", "po.agency_id"}} {
+			query := fmt.Sprintf(rollupAgencyMetrics, rollup[0], rollup[1], agencyParts[scope])
+			if _, err := tx.Exec(query, snapshotID, computedAt, scope); err != nil {
+				return nil, err
+			}
+		}
+	}
+	_, err = tx.Exec(`
+		INSERT INTO agency_metrics_history
+		(agency_id, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs, median_rscs, p90_rscs,
+			total_rscs_per_1k, restrictions, section_count, computed_at)
+		SELECT agency_id, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs, median_rscs, p90_rscs,
+			total_rscs_per_1k, restrictions, section_count, computed_at
+		FROM agency_metrics
+		WHERE title = '' AND scope = 'direct'
+		ON CONFLICT (agency_id, snapshot_id) DO UPDATE SET
+			total_words = EXCLUDED.total_words, total_rscs = EXCLUDED.total_rscs, avg_rscs = EXCLUDED.avg_rscs,
+			weighted_avg_rscs = EXCLUDED.weighted_avg_rscs, median_rscs = EXCLUDED.median_rscs,
+			p90_rscs = EXCLUDED.p90_rscs, total_rscs_per_1k = EXCLUDED.total_rscs_per_1k,
+			restrictions = EXCLUDED.restrictions, section_count = EXCLUDED.section_count,
+			computed_at = EXCLUDED.computed_at`)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT agency_id, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs, median_rscs, p90_rscs,
+			total_rscs_per_1k, restrictions, section_count, computed_at
+		FROM agency_metrics_history
+		WHERE snapshot_id = $1
+		ORDER BY agency_id COLLATE "C"`, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAgencyMetricSnapshots(rows)
+}
+
+// GetAgencyMetricsHistory retrieves the per-snapshot rollups for an agency, oldest first.
+// from/to are YYYY-MM-DD days compared against each snapshot's day; empty bounds are open.
+func (r *Repo) GetAgencyMetricsHistory(agencyID, from, to string) ([]domain.AgencyMetricSnapshot, error) {
+	rows, err := r.db.Query(`
+		SELECT agency_id, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs, median_rscs, p90_rscs,
+			total_rscs_per_1k, restrictions, section_count, computed_at
+		FROM agency_metrics_history
+		WHERE agency_id = $1
+			AND ($2::text = '' OR substr(snapshot_id, 1, 10) >= $2::text)
+			AND ($3::text = '' OR substr(snapshot_id, 1, 10) <= $3::text)
+		ORDER BY snapshot_id COLLATE "C" ASC`, agencyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAgencyMetricSnapshots(rows)
+}
+
+func scanAgencyMetricSnapshots(rows *sql.Rows) ([]domain.AgencyMetricSnapshot, error) {
+	var results []domain.AgencyMetricSnapshot
+	for rows.Next() {
+		var m domain.AgencyMetricSnapshot
+		var computedAt sql.NullTime
+		if err := rows.Scan(&m.AgencyID, &m.SnapshotID, &m.TotalWords, &m.TotalRSCS, &m.AvgRSCS,
+			&m.WeightedAvgRSCS, &m.MedianRSCS, &m.P90RSCS, &m.TotalRSCSPer1K,
+			&m.Restrictions, &m.SectionCount, &computedAt); err != nil {
+			return nil, err
+		}
+		if computedAt.Valid {
+			m.ComputedAt = computedAt.Time
+		}
+		results = append(results, m)
+	}
+	return results, rows.Err()
+}
+
+// InsertDiffs stores the changed sections of a snapshot. Unchanged sections are skipped.
+func (r *Repo) InsertDiffs(snapshotID string, diffs []domain.Diff) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`
+		INSERT INTO section_diffs
+		(snapshot_id, prev_snapshot_id, title, section_id, part, agency_id, status, words_before, words_after, restrictions_before, restrictions_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (snapshot_id, title, section_id) DO UPDATE SET
+			prev_snapshot_id = EXCLUDED.prev_snapshot_id, part = EXCLUDED.part, agency_id = EXCLUDED.agency_id,
+			status = EXCLUDED.status, words_before = EXCLUDED.words_before, words_after = EXCLUDED.words_after,
+			restrictions_before = EXCLUDED.restrictions_before, restrictions_after = EXCLUDED.restrictions_after`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, d := range diffs {
+		if !d.Changed {
+			continue
+		}
+		_, err = stmt.Exec(snapshotID, d.PrevSnapshotID, d.Title, d.SectionID, d.Part, d.AgencyID, d.Status,
+			d.WordsBefore, d.WordsAfter, d.RestrictionsBefore, d.RestrictionsAfter)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetScoreboard sums the words and restrictions each agency added and removed across
+// the snapshot diffs whose day falls in [from, to]. Empty bounds are open. Diffs against no previous
+// snapshot are ignored so the first snapshot does not count as everything being added.
+// Entries are returned unranked.
+func (r *Repo) GetScoreboard(from, to string) ([]domain.ScoreboardEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			a.id,
+			a.name,
+			COALESCE(SUM(CASE WHEN d.words_after < d.words_before THEN d.words_before - d.words_after ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.words_after > d.words_before THEN d.words_after - d.words_before ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.restrictions_after < d.restrictions_before THEN d.restrictions_before - d.restrictions_after ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.restrictions_after > d.restrictions_before THEN d.restrictions_after - d.restrictions_before ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.status = 'removed' THEN 1 ELSE 0 END), 0)
+		FROM section_diffs d
+		JOIN part_owners po
+			ON po.title = d.title
+			AND po.part = d.part
+		JOIN agencies a ON a.id = po.agency_id
+		WHERE COALESCE(d.prev_snapshot_id, '') <> ''
+			AND ($1::text = '' OR substr(d.snapshot_id, 1, 10) >= $1::text)
+			AND ($2::text = '' OR substr(d.snapshot_id, 1, 10) <= $2::text)
+		GROUP BY a.id, a.name`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.ScoreboardEntry
+	for rows.Next() {
+		var e domain.ScoreboardEntry
+		if err := rows.Scan(&e.AgencyID, &e.AgencyName, &e.WordsRemoved, &e.WordsAdded,
+			&e.RestrictionsRemoved, &e.RestrictionsAdded, &e.SectionsRemoved); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetLatestDiffSnapshot returns the most recent snapshot with stored diffs, or "" if there are none
+func (r *Repo) GetLatestDiffSnapshot() (string, error) {
+	var snapshotID sql.NullString
+	if err := r.db.QueryRow(`SELECT MAX(snapshot_id COLLATE "C") FROM section_diffs`).Scan(&snapshotID); err != nil {
+		return "", err
+	}
+	return snapshotID.String, nil
+}
+
+// GetSectionDiffs returns the changed sections stored for a snapshot, optionally limited to one title
+func (r *Repo) GetSectionDiffs(snapshotID, title string) ([]domain.Diff, error) {
+	rows, err := r.db.Query(`
+		SELECT section_id, title, COALESCE(part, ''), COALESCE(agency_id, ''), COALESCE(prev_snapshot_id, ''), status,
+			words_before, words_after, restrictions_before, restrictions_after
+		FROM section_diffs
+		WHERE snapshot_id = $1 AND ($2::text = '' OR title = $2::text)
+		ORDER BY CAST(title AS INTEGER), section_id COLLATE "C"`, snapshotID, title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var diffs []domain.Diff
+	for rows.Next() {
+		var d domain.Diff
+		if err := rows.Scan(&d.SectionID, &d.Title, &d.Part, &d.AgencyID, &d.PrevSnapshotID, &d.Status,
+			&d.WordsBefore, &d.WordsAfter, &d.RestrictionsBefore, &d.RestrictionsAfter); err != nil {
+			return nil, err
+		}
+		d.DeltaWordCount = d.WordsAfter - d.WordsBefore
+		d.Changed = true
+		diffs = append(diffs, d)
+	}
+	return diffs, rows.Err()
+}
+
+// InsertDiffAttributions stores the Federal Register documents linked to changed sections
+func (r *Repo) InsertDiffAttributions(attributions []domain.DiffAttribution) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`
+		INSERT INTO diff_attributions
+		(snapshot_id, title, section_id, document_number, document_title, publication_date, html_url, match_kind)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (snapshot_id, title, section_id, document_number) DO UPDATE SET
+			document_title = EXCLUDED.document_title, publication_date = EXCLUDED.publication_date,
+			html_url = EXCLUDED.html_url, match_kind = EXCLUDED.match_kind`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, a := range attributions {
+		_, err = stmt.Exec(a.SnapshotID, a.Title, a.SectionID, a.DocumentNumber, a.DocumentTitle,
+			a.PublicationDate, a.HTMLURL, a.MatchKind)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDiffAttributions returns the attributions for a snapshot, optionally limited to one title,
+// newest documents first
+func (r *Repo) GetDiffAttributions(snapshotID, title string) ([]domain.DiffAttribution, error) {
+	rows, err := r.db.Query(`
+		SELECT snapshot_id, title, section_id, document_number, COALESCE(document_title, ''),
+			publication_date, COALESCE(html_url, ''), COALESCE(match_kind, '')
+		FROM diff_attributions
+		WHERE snapshot_id = $1 AND ($2::text = '' OR title = $2::text)
+		ORDER BY publication_date DESC NULLS LAST, document_number COLLATE "C"`, snapshotID, title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.DiffAttribution
+	for rows.Next() {
+		var a domain.DiffAttribution
+		var published sql.NullTime
+		if err := rows.Scan(&a.SnapshotID, &a.Title, &a.SectionID, &a.DocumentNumber, &a.DocumentTitle,
+			&published, &a.HTMLURL, &a.MatchKind); err != nil {
+			return nil, err
+		}
+		if published.Valid {
+			a.PublicationDate = published.Time
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+// PublishSnapshot records which snapshot is published for a day, replacing any earlier pointer
+func (r *Repo) PublishSnapshot(p domain.PublishedSnapshot) error {
+	_, err := r.db.Exec(`
+		INSERT INTO published_snapshots (day, snapshot_id, published_at) VALUES ($1, $2, $3)
+		ON CONFLICT (day) DO UPDATE SET snapshot_id = EXCLUDED.snapshot_id, published_at = EXCLUDED.published_at`,
+		p.Day, p.SnapshotID, p.PublishedAt)
+	return err
+}
+
+// GetPublishedSnapshot returns the snapshot published for a day
+func (r *Repo) GetPublishedSnapshot(day string) (*domain.PublishedSnapshot, error) {
+	var p domain.PublishedSnapshot
+	err := r.db.QueryRow(`SELECT day, snapshot_id, published_at FROM published_snapshots WHERE day = $1`, day).
+		Scan(&p.Day, &p.SnapshotID, &p.PublishedAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Search is not implemented for PostgreSQL yet; full-text search needs the SQLite store.
+func (r *Repo) Search(q domain.SearchQuery) (*domain.SearchResults, error) {
+	return nil, fmt.Errorf("%w: full-text search needs the SQLite store", domain.ErrUnsupported)
+}
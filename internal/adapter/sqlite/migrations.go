@@ -0,0 +1,891 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// migration is one versioned step of the schema. up and down each run in their own
+// transaction, together with the schema_migrations bookkeeping.
+//
+// Append new migrations to the end of migrations; never edit or reorder ones that have shipped.
+// The early migrations are idempotent so that databases created before schema_migrations
+// existed adopt them without changes.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+	down    func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{1, "initial schema", migrateInitialUp, dropTables(
+		"diff_attributions", "section_diffs", "agency_metrics_history", "summaries",
+		"agency_lsa", "lsa_activity", "agency_cfr_references", "agencies", "sections",
+	)},
+	{2, "agency content checksum",
+		func(tx *sql.Tx) error { return addColumnIfMissing(tx, "agencies", "content_checksum", "TEXT") },
+		func(tx *sql.Tx) error { return dropColumnIfExists(tx, "agencies", "content_checksum") },
+	},
+	{3, "snapshot ids", migrateSnapshotIDsUp, func(tx *sql.Tx) error {
+		if err := dropTables("published_snapshots")(tx); err != nil {
+			return err
+		}
+		return dropColumnIfExists(tx, "sections", "snapshot_id")
+	}},
+	{4, "section texts", migrateSectionTextsUp, func(tx *sql.Tx) error {
+		if err := dropTables("section_texts")(tx); err != nil {
+			return err
+		}
+		return dropColumnIfExists(tx, "sections", "text_sha256")
+	}},
+	{5, "section versions", migrateSectionVersionsUp, migrateSectionVersionsDown},
+	{6, "section headings",
+		func(tx *sql.Tx) error { return addColumnIfMissing(tx, "section_versions", "heading", "TEXT") },
+		func(tx *sql.Tx) error { return dropColumnIfExists(tx, "section_versions", "heading") },
+	},
+	{7, "agency metrics", migrateAgencyMetricsUp, dropTables("agency_metrics")},
+	{8, "agency metric rollups", migrateAgencyRollupsUp, migrateAgencyRollupsDown},
+	{9, "agency RSCS aggregations", migrateRSCSAggregationsUp, func(tx *sql.Tx) error {
+		for _, column := range []string{"weighted_avg_rscs", "median_rscs", "p90_rscs", "total_rscs_per_1k"} {
+			if err := dropColumnIfExists(tx, "agency_metrics", column); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{10, "part ownership", migratePartOwnersUp, func(tx *sql.Tx) error {
+		if err := dropTables("part_owners", "cfr_parts")(tx); err != nil {
+			return err
+		}
+		for _, column := range []string{"subtitle", "subchapter", "part"} {
+			if err := dropColumnIfExists(tx, "agency_cfr_references", column); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{11, "agency changes", func(tx *sql.Tx) error {
+		return execAll(tx,
+			// What each agency sync added, renamed, re-parented and removed
+			`CREATE TABLE agency_changes (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				agency_id  TEXT NOT NULL,
+				kind       TEXT NOT NULL,
+				old_value  TEXT NOT NULL DEFAULT '',
+				new_value  TEXT NOT NULL DEFAULT '',
+				changed_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX idx_agency_changes_agency ON agency_changes(agency_id, changed_at)`,
+		)
+	}, dropTables("agency_changes")},
+	{12, "federal register agency map", func(tx *sql.Tx) error {
+		return execAll(tx,
+			// Which eCFR agency each Federal Register agency is; agency_id is '' when unmatched
+			`CREATE TABLE fr_agency_map (
+				fr_slug    TEXT PRIMARY KEY,
+				fr_name    TEXT NOT NULL DEFAULT '',
+				agency_id  TEXT NOT NULL,
+				match_kind TEXT NOT NULL
+			)`,
+			`CREATE INDEX idx_fr_agency_map_agency ON fr_agency_map(agency_id)`,
+			// LSA counts were joined to agencies by slug until the first reconciliation
+			`INSERT INTO fr_agency_map (fr_slug, fr_name, agency_id, match_kind)
+				SELECT l.agency_id, COALESCE(MAX(l.agency_name), ''), l.agency_id, 'slug'
+				FROM agency_lsa l
+				JOIN agencies a ON a.id = l.agency_id
+				GROUP BY l.agency_id`,
+		)
+	}, dropTables("fr_agency_map")},
+	{13, "titles", func(tx *sql.Tx) error {
+		return execAll(tx,
+			// Title metadata from the eCFR; dates are YYYY-MM-DD, '' when the eCFR gives none
+			`CREATE TABLE titles (
+				title             TEXT PRIMARY KEY,
+				name              TEXT NOT NULL,
+				latest_amended_on TEXT NOT NULL DEFAULT '',
+				latest_issue_date TEXT NOT NULL DEFAULT '',
+				up_to_date_as_of  TEXT NOT NULL DEFAULT '',
+				reserved          INTEGER NOT NULL DEFAULT 0
+			)`,
+			// Totals of each title's current sections, refreshed whenever a title is loaded
+			`CREATE TABLE title_metrics (
+				title         TEXT PRIMARY KEY,
+				snapshot_id   TEXT NOT NULL,
+				total_words   INTEGER NOT NULL,
+				total_rscs    INTEGER NOT NULL,
+				avg_rscs      REAL NOT NULL,
+				restrictions  INTEGER NOT NULL,
+				section_count INTEGER NOT NULL
+			)`,
+			`INSERT INTO title_metrics (title, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count)
+				SELECT title, MAX(snapshot_id), COALESCE(SUM(word_count), 0), COALESCE(SUM(rscs_raw), 0),
+					COALESCE(AVG(rscs_per_1k), 0), COALESCE(SUM(modal_count), 0), COUNT(*)
+				FROM current_sections
+				WHERE title IS NOT NULL
+				GROUP BY title`,
+		)
+	}, dropTables("title_metrics", "titles")},
+	{14, "agency metrics history aggregations", func(tx *sql.Tx) error {
+		for _, column := range historyAggregations {
+			if err := addColumnIfMissing(tx, "agency_metrics_history", column, "REAL DEFAULT 0"); err != nil {
+				return err
+			}
+		}
+		// Earlier snapshots' sections are gone; only the latest one can be filled in
+		_, err := tx.Exec(`
+			UPDATE agency_metrics_history SET (weighted_avg_rscs, median_rscs, p90_rscs, total_rscs_per_1k) = (
+				SELECT m.weighted_avg_rscs, m.median_rscs, m.p90_rscs, m.total_rscs_per_1k
+				FROM agency_metrics m
+				WHERE m.agency_id = agency_metrics_history.agency_id AND m.snapshot_id = agency_metrics_history.snapshot_id
+					AND m.title = '' AND m.scope = 'direct'
+			)
+			WHERE EXISTS (
+				SELECT 1 FROM agency_metrics m
+				WHERE m.agency_id = agency_metrics_history.agency_id AND m.snapshot_id = agency_metrics_history.snapshot_id
+					AND m.title = '' AND m.scope = 'direct'
+			)`)
+		return err
+	}, func(tx *sql.Tx) error {
+		for _, column := range historyAggregations {
+			if err := dropColumnIfExists(tx, "agency_metrics_history", column); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+}
+
+// historyAggregations are the agency RSCS aggregations kept for each snapshot besides avg_rscs
+var historyAggregations = []string{"weighted_avg_rscs", "median_rscs", "p90_rscs", "total_rscs_per_1k"}
+
+// MigrationStatus reports whether one migration has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+func migrateInitialUp(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS sections (
+			id TEXT PRIMARY KEY,
+			title TEXT,
+			part TEXT,
+			section TEXT,
+			agency_id TEXT,
+			path TEXT,
+			text TEXT,
+			rev_date DATETIME,
+			checksum_sha256 TEXT,
+			word_count INTEGER,
+			def_count INTEGER,
+			xref_count INTEGER,
+			modal_count INTEGER,
+			rscs_raw INTEGER,
+			rscs_per_1k REAL,
+			snapshot_date TEXT
+		)`,
+		// Indexes on sections for faster checksum queries
+		`CREATE INDEX IF NOT EXISTS idx_sections_title ON sections(title)`,
+		`CREATE INDEX IF NOT EXISTS idx_sections_agency_id ON sections(agency_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_sections_title_agency ON sections(title, agency_id)`,
+
+		`CREATE TABLE IF NOT EXISTS agencies (
+			id            TEXT PRIMARY KEY,
+			name          TEXT NOT NULL,
+			short_name    TEXT,
+			sortable_name TEXT,
+			parent_id     TEXT,
+			FOREIGN KEY(parent_id) REFERENCES agencies(id)
+		)`,
+
+		// Agency CFR references (N:N mapping - no PK)
+		`CREATE TABLE IF NOT EXISTS agency_cfr_references (
+			agency_id TEXT NOT NULL,
+			title     INTEGER NOT NULL,
+			chapter   TEXT NOT NULL,
+			FOREIGN KEY (agency_id) REFERENCES agencies(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_acr_title_chapter ON agency_cfr_references(title, chapter)`,
+		`CREATE INDEX IF NOT EXISTS idx_acr_agency ON agency_cfr_references(agency_id)`,
+
+		`CREATE TABLE IF NOT EXISTS lsa_activity (
+			id            INTEGER PRIMARY KEY AUTOINCREMENT,
+			title         TEXT NOT NULL,
+			snapshot_date TEXT NOT NULL,
+			proposals     INTEGER DEFAULT 0,
+			amendments    INTEGER DEFAULT 0,
+			finals        INTEGER DEFAULT 0,
+			captured_at   DATETIME,
+			source_hint   TEXT,
+			UNIQUE(title, snapshot_date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_lsa_title ON lsa_activity(title)`,
+
+		// Per-agency LSA data from Federal Register API
+		`CREATE TABLE IF NOT EXISTS agency_lsa (
+			id            INTEGER PRIMARY KEY AUTOINCREMENT,
+			agency_id     TEXT NOT NULL,
+			agency_name   TEXT NOT NULL,
+			proposed_rules INTEGER DEFAULT 0,
+			final_rules   INTEGER DEFAULT 0,
+			notices       INTEGER DEFAULT 0,
+			total_documents INTEGER DEFAULT 0,
+			snapshot_date TEXT NOT NULL,
+			captured_at   DATETIME,
+			source_hint   TEXT,
+			UNIQUE(agency_id, snapshot_date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_agency_lsa_agency ON agency_lsa(agency_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_agency_lsa_snapshot ON agency_lsa(snapshot_date)`,
+
+		// AI-generated summaries
+		`CREATE TABLE IF NOT EXISTS summaries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL DEFAULT 'title',
+			key TEXT NOT NULL,
+			text TEXT NOT NULL,
+			model TEXT DEFAULT 'gemini-2.5-pro',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(kind, key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_summaries_kind_key ON summaries(kind, key)`,
+
+		// Per-agency rollups captured at each snapshot
+		`CREATE TABLE IF NOT EXISTS agency_metrics_history (
+			agency_id     TEXT NOT NULL,
+			snapshot_id   TEXT NOT NULL,
+			total_words   INTEGER DEFAULT 0,
+			total_rscs    INTEGER DEFAULT 0,
+			avg_rscs      REAL DEFAULT 0,
+			restrictions  INTEGER DEFAULT 0,
+			section_count INTEGER DEFAULT 0,
+			computed_at   DATETIME,
+			PRIMARY KEY (agency_id, snapshot_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_amh_snapshot ON agency_metrics_history(snapshot_id)`,
+
+		// Changed sections found at each snapshot
+		`CREATE TABLE IF NOT EXISTS section_diffs (
+			snapshot_id         TEXT NOT NULL,
+			prev_snapshot_id    TEXT,
+			title               TEXT NOT NULL,
+			section_id          TEXT NOT NULL,
+			part                TEXT,
+			agency_id           TEXT,
+			status              TEXT NOT NULL,
+			words_before        INTEGER DEFAULT 0,
+			words_after         INTEGER DEFAULT 0,
+			restrictions_before INTEGER DEFAULT 0,
+			restrictions_after  INTEGER DEFAULT 0,
+			PRIMARY KEY (snapshot_id, title, section_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_section_diffs_title_agency ON section_diffs(title, agency_id)`,
+
+		// Changed sections linked to Federal Register documents
+		`CREATE TABLE IF NOT EXISTS diff_attributions (
+			snapshot_id      TEXT NOT NULL,
+			title            TEXT NOT NULL,
+			section_id       TEXT NOT NULL,
+			document_number  TEXT NOT NULL,
+			document_title   TEXT,
+			publication_date DATETIME,
+			html_url         TEXT,
+			match_kind       TEXT,
+			PRIMARY KEY (snapshot_id, title, section_id, document_number)
+		)`,
+	)
+}
+
+func migrateSnapshotIDsUp(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "sections", "snapshot_id", "TEXT"); err != nil {
+		return err
+	}
+	// Points each day at the snapshot readers should use
+	return execAll(tx, `CREATE TABLE IF NOT EXISTS published_snapshots (
+		day          TEXT PRIMARY KEY,
+		snapshot_id  TEXT NOT NULL,
+		published_at DATETIME
+	)`)
+}
+
+func migrateSectionTextsUp(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "sections", "text_sha256", "TEXT"); err != nil {
+		return err
+	}
+	// Each distinct section text once, keyed by its SHA-256
+	return execAll(tx, `CREATE TABLE IF NOT EXISTS section_texts (
+		text_sha256 TEXT PRIMARY KEY,
+		text        TEXT NOT NULL
+	)`)
+}
+
+// sectionVersionColumns are the columns section_versions shares with the sections table it
+// replaced.
+const sectionVersionColumns = `id, title, part, section, agency_id, path, rev_date, checksum_sha256, text_sha256,
+	word_count, def_count, xref_count, modal_count, rscs_raw, rscs_per_1k, snapshot_date`
+
+func migrateSectionVersionsUp(tx *sql.Tx) error {
+	// One row per section per snapshot, so later loads no longer overwrite earlier ones
+	err := execAll(tx,
+		`CREATE TABLE section_versions (
+			section_key     TEXT NOT NULL,
+			snapshot_id     TEXT NOT NULL,
+			id              TEXT,
+			title           TEXT,
+			part            TEXT,
+			section         TEXT,
+			agency_id       TEXT,
+			path            TEXT,
+			rev_date        DATETIME,
+			checksum_sha256 TEXT,
+			text_sha256     TEXT,
+			word_count      INTEGER,
+			def_count       INTEGER,
+			xref_count      INTEGER,
+			modal_count     INTEGER,
+			rscs_raw        INTEGER,
+			rscs_per_1k     REAL,
+			snapshot_date   TEXT,
+			PRIMARY KEY (section_key, snapshot_id)
+		)`,
+		`CREATE INDEX idx_section_versions_title_snapshot ON section_versions(title, snapshot_id)`,
+		`CREATE INDEX idx_section_versions_title_agency ON section_versions(title, agency_id)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Rows loaded before section_texts kept their text inline; move it there first
+	rows, err := tx.Query(`SELECT rowid, text FROM sections WHERE text IS NOT NULL AND text_sha256 IS NULL`)
+	if err != nil {
+		return err
+	}
+	type legacyText struct {
+		rowid int64
+		text  string
+	}
+	var legacy []legacyText
+	for rows.Next() {
+		var l legacyText
+		if err := rows.Scan(&l.rowid, &l.text); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy = append(legacy, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, l := range legacy {
+		hash := domain.TextHash(l.text)
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO section_texts (text_sha256, text) VALUES (?, ?)`, hash, l.text); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE sections SET text_sha256 = ? WHERE rowid = ?`, hash, l.rowid); err != nil {
+			return err
+		}
+	}
+
+	// The old table held one row per bare section number, so keys are derived row by row
+	rows, err = tx.Query(`SELECT rowid, title, COALESCE(section, id, '') FROM sections`)
+	if err != nil {
+		return err
+	}
+	keys := make(map[int64]string)
+	for rows.Next() {
+		var rowid int64
+		var title sql.NullString
+		var section string
+		if err := rows.Scan(&rowid, &title, &section); err != nil {
+			rows.Close()
+			return err
+		}
+		keys[rowid] = domain.SectionKey(title.String, section)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	copyStmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO section_versions (section_key, snapshot_id, ` + sectionVersionColumns + `)
+		SELECT ?, COALESCE(snapshot_id, snapshot_date, ''), ` + sectionVersionColumns + `
+		FROM sections WHERE rowid = ?`)
+	if err != nil {
+		return err
+	}
+	defer copyStmt.Close()
+	for rowid, key := range keys {
+		if _, err := copyStmt.Exec(key, rowid); err != nil {
+			return err
+		}
+	}
+
+	return execAll(tx,
+		`DROP TABLE sections`,
+		// Each title's sections as of the latest snapshot that loaded it. A load writes every
+		// section of the title, so sections removed since then drop out.
+		`CREATE VIEW current_sections AS
+			SELECT v.*
+			FROM section_versions v
+			WHERE v.snapshot_id = (SELECT MAX(snapshot_id) FROM section_versions WHERE title IS v.title)`,
+	)
+}
+
+func migrateSectionVersionsDown(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE sections (
+			id TEXT PRIMARY KEY,
+			title TEXT,
+			part TEXT,
+			section TEXT,
+			agency_id TEXT,
+			path TEXT,
+			text TEXT,
+			rev_date DATETIME,
+			checksum_sha256 TEXT,
+			word_count INTEGER,
+			def_count INTEGER,
+			xref_count INTEGER,
+			modal_count INTEGER,
+			rscs_raw INTEGER,
+			rscs_per_1k REAL,
+			snapshot_date TEXT,
+			snapshot_id TEXT,
+			text_sha256 TEXT
+		)`,
+		// The old table has room for one row per section number; later titles win as they did
+		`INSERT OR REPLACE INTO sections (`+sectionVersionColumns+`, snapshot_id)
+			SELECT `+sectionVersionColumns+`, snapshot_id FROM current_sections ORDER BY title, id`,
+		`CREATE INDEX idx_sections_title ON sections(title)`,
+		`CREATE INDEX idx_sections_agency_id ON sections(agency_id)`,
+		`CREATE INDEX idx_sections_title_agency ON sections(title, agency_id)`,
+		`DROP VIEW current_sections`,
+		`DROP TABLE section_versions`,
+	)
+}
+
+func migrateAgencyMetricsUp(tx *sql.Tx) error {
+	err := execAll(tx,
+		// Per-agency rollups as of the last snapshot, read by /api/agencies. title '' holds the
+		// agency across all of its titles.
+		`CREATE TABLE agency_metrics (
+			agency_id     TEXT NOT NULL,
+			title         TEXT NOT NULL,
+			snapshot_id   TEXT NOT NULL,
+			total_words   INTEGER DEFAULT 0,
+			total_rscs    INTEGER DEFAULT 0,
+			avg_rscs      REAL DEFAULT 0,
+			restrictions  INTEGER DEFAULT 0,
+			section_count INTEGER DEFAULT 0,
+			computed_at   DATETIME,
+			PRIMARY KEY (agency_id, title)
+		)`,
+		`CREATE INDEX idx_agency_metrics_title ON agency_metrics(title)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Fill it from the current sections so the API has totals before the next ETL run
+	for _, rollup := range [][2]string{{"s.title", "acr.agency_id, s.title"}, {"''", "acr.agency_id"}} {
+		_, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO agency_metrics
+			(agency_id, title, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count, computed_at)
+			SELECT
+				acr.agency_id,
+				%[1]s,
+				COALESCE((SELECT MAX(snapshot_id) FROM section_versions), ''),
+				COALESCE(SUM(s.word_count), 0),
+				COALESCE(SUM(s.rscs_raw), 0),
+				COALESCE(AVG(s.rscs_per_1k), 0),
+				COALESCE(SUM(s.modal_count), 0),
+				COUNT(*),
+				?
+			FROM (SELECT DISTINCT agency_id, title, chapter FROM agency_cfr_references) acr
+			JOIN current_sections s
+				ON s.title = CAST(acr.title AS TEXT)
+				AND s.agency_id = acr.chapter
+			GROUP BY %[2]s`, rollup[0], rollup[1]), time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateAgencyRollupsUp(tx *sql.Tx) error {
+	// scope joins the primary key, so the table is rebuilt; the existing rows are an agency's own
+	err := execAll(tx,
+		`CREATE TABLE agency_metrics_new (
+			agency_id     TEXT NOT NULL,
+			title         TEXT NOT NULL,
+			scope         TEXT NOT NULL DEFAULT 'direct',
+			snapshot_id   TEXT NOT NULL,
+			total_words   INTEGER DEFAULT 0,
+			total_rscs    INTEGER DEFAULT 0,
+			avg_rscs      REAL DEFAULT 0,
+			restrictions  INTEGER DEFAULT 0,
+			section_count INTEGER DEFAULT 0,
+			computed_at   DATETIME,
+			PRIMARY KEY (agency_id, title, scope)
+		)`,
+		`INSERT INTO agency_metrics_new
+			(agency_id, title, scope, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count, computed_at)
+			SELECT agency_id, title, 'direct', snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count, computed_at
+			FROM agency_metrics`,
+		`DROP TABLE agency_metrics`,
+		`ALTER TABLE agency_metrics_new RENAME TO agency_metrics`,
+		`CREATE INDEX idx_agency_metrics_title ON agency_metrics(title, scope)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Fill the subtree rollups from the current sections so the API has them before the next ETL run
+	for _, rollup := range [][2]string{{"s.title", "acr.agency_id, s.title"}, {"''", "acr.agency_id"}} {
+		_, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO agency_metrics
+			(agency_id, title, scope, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count, computed_at)
+			SELECT
+				acr.agency_id,
+				%[1]s,
+				'rollup',
+				COALESCE((SELECT MAX(snapshot_id) FROM section_versions), ''),
+				COALESCE(SUM(s.word_count), 0),
+				COALESCE(SUM(s.rscs_raw), 0),
+				COALESCE(AVG(s.rscs_per_1k), 0),
+				COALESCE(SUM(s.modal_count), 0),
+				COUNT(*),
+				?
+			FROM (
+				WITH RECURSIVE subtree(root, agency_id) AS (
+					SELECT id, id FROM agencies
+					UNION
+					SELECT subtree.root, a.id FROM agencies a JOIN subtree ON a.parent_id = subtree.agency_id
+				)
+				SELECT DISTINCT subtree.root AS agency_id, acr.title, acr.chapter
+				FROM subtree
+				JOIN agency_cfr_references acr ON acr.agency_id = subtree.agency_id
+			) acr
+			JOIN current_sections s
+				ON s.title = CAST(acr.title AS TEXT)
+				AND s.agency_id = acr.chapter
+			GROUP BY %[2]s`, rollup[0], rollup[1]), time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateAgencyRollupsDown(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE agency_metrics_old (
+			agency_id     TEXT NOT NULL,
+			title         TEXT NOT NULL,
+			snapshot_id   TEXT NOT NULL,
+			total_words   INTEGER DEFAULT 0,
+			total_rscs    INTEGER DEFAULT 0,
+			avg_rscs      REAL DEFAULT 0,
+			restrictions  INTEGER DEFAULT 0,
+			section_count INTEGER DEFAULT 0,
+			computed_at   DATETIME,
+			PRIMARY KEY (agency_id, title)
+		)`,
+		`INSERT INTO agency_metrics_old
+			(agency_id, title, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count, computed_at)
+			SELECT agency_id, title, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count, computed_at
+			FROM agency_metrics WHERE scope = 'direct'`,
+		`DROP TABLE agency_metrics`,
+		`ALTER TABLE agency_metrics_old RENAME TO agency_metrics`,
+		`CREATE INDEX idx_agency_metrics_title ON agency_metrics(title)`,
+	)
+}
+
+func migrateRSCSAggregationsUp(tx *sql.Tx) error {
+	for _, column := range []string{"weighted_avg_rscs", "median_rscs", "p90_rscs", "total_rscs_per_1k"} {
+		if err := addColumnIfMissing(tx, "agency_metrics", column, "REAL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+
+	// Fill them from the current sections so the API has them before the next ETL run
+	chapters := map[string]string{
+		"direct": `SELECT DISTINCT agency_id, title, chapter FROM agency_cfr_references`,
+		"rollup": `
+			WITH RECURSIVE subtree(root, agency_id) AS (
+				SELECT id, id FROM agencies
+				UNION
+				SELECT subtree.root, a.id FROM agencies a JOIN subtree ON a.parent_id = subtree.agency_id
+			)
+			SELECT DISTINCT subtree.root AS agency_id, acr.title, acr.chapter
+			FROM subtree
+			JOIN agency_cfr_references acr ON acr.agency_id = subtree.agency_id`,
+	}
+	for scope, acr := range chapters {
+		// Rows for one title, then the agency-wide rows (title '')
+		for _, rows := range [][2]string{{"agency_metrics.title <> ''", "s.title = agency_metrics.title"}, {"agency_metrics.title = ''", "1"}} {
+			_, err := tx.Exec(fmt.Sprintf(`
+				UPDATE agency_metrics SET (weighted_avg_rscs, total_rscs_per_1k) = (
+					SELECT
+						COALESCE(SUM(s.rscs_per_1k * s.word_count) / NULLIF(SUM(CASE WHEN s.rscs_per_1k IS NOT NULL THEN s.word_count END), 0), 0),
+						COALESCE(1000.0 * SUM(s.rscs_raw) / NULLIF(SUM(s.word_count), 0), 0)
+					FROM (%s) acr
+					JOIN current_sections s
+						ON s.title = CAST(acr.title AS TEXT)
+						AND s.agency_id = acr.chapter
+					WHERE acr.agency_id = agency_metrics.agency_id AND %s
+				)
+				WHERE scope = ? AND %s`, acr, rows[1], rows[0]), scope)
+			if err != nil {
+				return err
+			}
+		}
+
+		percentiles, err := rscsPercentiles(tx, `
+			SELECT acr.agency_id, s.title, s.rscs_per_1k
+			FROM (`+acr+`) acr
+			JOIN current_sections s
+				ON s.title = CAST(acr.title AS TEXT)
+				AND s.agency_id = acr.chapter`)
+		if err != nil {
+			return err
+		}
+		for key, p := range percentiles {
+			_, err := tx.Exec(`UPDATE agency_metrics SET median_rscs = ?, p90_rscs = ? WHERE agency_id = ? AND title = ? AND scope = ?`,
+				p[0], p[1], key[0], key[1], scope)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func migratePartOwnersUp(tx *sql.Tx) error {
+	// References narrower or wider than a chapter; '' where the reference does not say
+	for _, column := range []string{"subtitle", "subchapter", "part"} {
+		if err := addColumnIfMissing(tx, "agency_cfr_references", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	return execAll(tx,
+		// Where each part sits in its title, as last loaded
+		`CREATE TABLE cfr_parts (
+			title      TEXT NOT NULL,
+			part       TEXT NOT NULL,
+			subtitle   TEXT NOT NULL DEFAULT '',
+			chapter    TEXT NOT NULL DEFAULT '',
+			subchapter TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (title, part)
+		)`,
+		// The agencies owning each part, which every agency metric joins sections through
+		`CREATE TABLE part_owners (
+			title     TEXT NOT NULL,
+			part      TEXT NOT NULL,
+			agency_id TEXT NOT NULL,
+			source    TEXT NOT NULL,
+			PRIMARY KEY (title, part, agency_id)
+		)`,
+		`CREATE INDEX idx_part_owners_agency ON part_owners(agency_id)`,
+
+		// Until the next load records subtitles and subchapters, parts are owned by chapter, as
+		// before
+		`INSERT INTO cfr_parts (title, part, chapter)
+			SELECT title, part, COALESCE(MAX(agency_id), '')
+			FROM current_sections
+			WHERE COALESCE(part, '') <> ''
+			GROUP BY title, part`,
+		`INSERT OR IGNORE INTO part_owners (title, part, agency_id, source)
+			SELECT p.title, p.part, acr.agency_id, 'chapter'
+			FROM cfr_parts p
+			JOIN agency_cfr_references acr
+				ON CAST(acr.title AS TEXT) = p.title
+				AND acr.chapter = p.chapter`,
+	)
+}
+
+func execAll(tx *sql.Tx, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropTables(tables ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, table := range tables {
+			if _, err := tx.Exec(`DROP TABLE IF EXISTS ` + table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// addColumnIfMissing adds a column unless the table already has it. Unlike ignoring the error
+// from ALTER TABLE, any other failure is reported.
+func addColumnIfMissing(tx *sql.Tx, table, column, decl string) error {
+	ok, err := hasColumn(tx, table, column)
+	if err != nil || ok {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl))
+	return err
+}
+
+func dropColumnIfExists(tx *sql.Tx, table, column string) error {
+	ok, err := hasColumn(tx, table, column)
+	if err != nil || !ok {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, table, column))
+	return err
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`)
+	return err
+}
+
+func appliedMigrations(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus lists every known migration, oldest first, with when it was applied.
+func (r *Repo) MigrationStatus() ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(r.db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(r.db)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i] = MigrationStatus{Version: m.version, Name: m.name}
+		if at, ok := applied[m.version]; ok {
+			status[i].AppliedAt = &at
+		}
+	}
+	return status, nil
+}
+
+// MigrateUp applies every pending migration in order and returns how many ran. Each runs in
+// an immediate transaction, so instances starting together apply it once between them.
+func (r *Repo) MigrateUp() (int, error) {
+	if err := ensureMigrationsTable(r.db); err != nil {
+		return 0, err
+	}
+	ran := 0
+	for _, m := range migrations {
+		applied, err := r.runMigration(m, true)
+		if err != nil {
+			return ran, fmt.Errorf("%w: migration %d (%s): %v", domain.ErrPersistence, m.version, m.name, err)
+		}
+		if applied {
+			ran++
+		}
+	}
+	return ran, nil
+}
+
+// MigrateDown reverts the most recently applied migrations, up to steps of them, and returns
+// how many were reverted.
+func (r *Repo) MigrateDown(steps int) (int, error) {
+	if err := ensureMigrationsTable(r.db); err != nil {
+		return 0, err
+	}
+	ran := 0
+	for i := len(migrations) - 1; i >= 0 && ran < steps; i-- {
+		m := migrations[i]
+		reverted, err := r.runMigration(m, false)
+		if err != nil {
+			return ran, fmt.Errorf("%w: reverting migration %d (%s): %v", domain.ErrPersistence, m.version, m.name, err)
+		}
+		if reverted {
+			ran++
+		}
+	}
+	return ran, nil
+}
+
+// runMigration applies (up) or reverts a migration unless that has already happened, checking
+// inside the transaction so a concurrent migrator cannot run it twice.
+func (r *Repo) runMigration(m migration, up bool) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, m.version).Scan(&n); err != nil {
+		return false, err
+	}
+	if up == (n > 0) {
+		return false, nil
+	}
+
+	if up {
+		if err := m.up(tx); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, m.version, m.name, time.Now().UTC())
+	} else {
+		if err := m.down(tx); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
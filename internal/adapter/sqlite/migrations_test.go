@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	repo, err := NewRepo(path)
+	if err != nil {
+		t.Fatalf("NewRepo failed: %v", err)
+	}
+	defer repo.Close()
+
+	status, err := repo.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if len(status) != len(migrations) {
+		t.Fatalf("got %d migrations, want %d", len(status), len(migrations))
+	}
+	for _, m := range status {
+		if m.AppliedAt == nil {
+			t.Errorf("migration %d (%s) not applied", m.Version, m.Name)
+		}
+	}
+
+	// Reopening applies nothing
+	if n, err := repo.MigrateUp(); err != nil || n != 0 {
+		t.Fatalf("MigrateUp = %d, %v; want 0, nil", n, err)
+	}
+
+	// Revert everything after migration 2, which leaves sections without snapshot ids
+	steps := len(migrations) - 2
+	if n, err := repo.MigrateDown(steps); err != nil || n != steps {
+		t.Fatalf("MigrateDown = %d, %v; want %d, nil", n, err, steps)
+	}
+	tx, err := repo.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := hasColumn(tx, "sections", "snapshot_id")
+	tx.Rollback()
+	if err != nil || has {
+		t.Fatalf("sections.snapshot_id after down = %v, %v; want dropped", has, err)
+	}
+	if _, err := repo.db.Exec(`SELECT COUNT(*) FROM section_texts`); err == nil {
+		t.Error("section_texts still exists after down")
+	}
+
+	if n, err := repo.MigrateUp(); err != nil || n != steps {
+		t.Fatalf("MigrateUp = %d, %v; want %d, nil", n, err, steps)
+	}
+	if _, err := repo.db.Exec(`INSERT INTO section_texts (text_sha256, text) VALUES ('h', 't')`); err != nil {
+		t.Fatalf("section_texts after up: %v", err)
+	}
+}
+
+// Databases created before schema_migrations existed already have the schema; migrating them
+// records the migrations without failing on existing tables and columns.
+func TestMigrationsAdoptExistingSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	repo, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer repo.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE sections (id TEXT PRIMARY KEY, title TEXT, part TEXT, section TEXT, agency_id TEXT, path TEXT, text TEXT,
+			rev_date DATETIME, checksum_sha256 TEXT, word_count INTEGER, def_count INTEGER, xref_count INTEGER,
+			modal_count INTEGER, rscs_raw INTEGER, rscs_per_1k REAL, snapshot_date TEXT, snapshot_id TEXT)`,
+		`CREATE TABLE agencies (id TEXT PRIMARY KEY, name TEXT NOT NULL, short_name TEXT, sortable_name TEXT, parent_id TEXT, content_checksum TEXT)`,
+		`INSERT INTO sections (id, title, section, text, snapshot_id) VALUES ('§ 1.1', '1', '§ 1.1', 'legacy', 's1')`,
+	} {
+		if _, err := repo.db.Exec(stmt); err != nil {
+			t.Fatalf("seed failed: %v", err)
+		}
+	}
+
+	if n, err := repo.MigrateUp(); err != nil || n != len(migrations) {
+		t.Fatalf("MigrateUp = %d, %v; want %d, nil", n, err, len(migrations))
+	}
+	// The existing row becomes the first version of its section, with its text moved out
+	var snapshot, hash string
+	err = repo.db.QueryRow(`SELECT snapshot_id, text_sha256 FROM section_versions WHERE section_key = '1:1.1'`).Scan(&snapshot, &hash)
+	if err != nil || snapshot != "s1" {
+		t.Fatalf("existing row = %q, %v; want s1", snapshot, err)
+	}
+	if text, err := repo.GetSectionText(hash); err != nil || text != "legacy" {
+		t.Errorf("GetSectionText = %q, %v; want legacy", text, err)
+	}
+}
@@ -4,288 +4,237 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlquery"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
 type Repo struct {
-	Path string
-	db   *sql.DB
+	*sqlquery.Queries // Queries whose SQL PostgreSQL shares: titles and section details
+
+	Path   string
+	db     *sql.DB
+	search bool // section_search is available; see search.go
 }
 
+// NewRepo opens the database at path, creating it if needed, and applies any pending
+// migrations.
 func NewRepo(path string) (*Repo, error) {
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return nil, err
-	}
-	db, err := sql.Open("sqlite3", path)
+	r, err := Open(path)
 	if err != nil {
 		return nil, err
 	}
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS sections (
-			id TEXT PRIMARY KEY,
-			title TEXT,
-			part TEXT,
-			section TEXT,
-			agency_id TEXT,
-			path TEXT,
-			text TEXT,
-			rev_date DATETIME,
-			checksum_sha256 TEXT,
-			word_count INTEGER,
-			def_count INTEGER,
-			xref_count INTEGER,
-			modal_count INTEGER,
-			rscs_raw INTEGER,
-			rscs_per_1k REAL,
-			snapshot_date TEXT
-		)
-	`)
-	if err != nil {
-		db.Close()
-		return nil, err
-	}
-
-	// Create agencies table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS agencies (
-			id            TEXT PRIMARY KEY,
-			name          TEXT NOT NULL,
-			short_name    TEXT,
-			sortable_name TEXT,
-			parent_id     TEXT,
-			FOREIGN KEY(parent_id) REFERENCES agencies(id)
-		)
-	`)
-	if err != nil {
-		db.Close()
+	if _, err := r.MigrateUp(); err != nil {
+		r.db.Close()
 		return nil, err
 	}
-
-	// Create agency CFR references table (N:N mapping - no PK)
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS agency_cfr_references (
-			agency_id TEXT NOT NULL,
-			title     INTEGER NOT NULL,
-			chapter   TEXT NOT NULL,
-			FOREIGN KEY (agency_id) REFERENCES agencies(id)
-		)
-	`)
-	if err != nil {
-		db.Close()
+	if err := r.initSearch(); err != nil {
+		r.db.Close()
 		return nil, err
 	}
+	return r, nil
+}
 
-	// Create indexes for fast joins
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_acr_title_chapter ON agency_cfr_references(title, chapter)`)
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_acr_agency ON agency_cfr_references(agency_id)`)
-
-	// Create LSA activity table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS lsa_activity (
-			id            INTEGER PRIMARY KEY AUTOINCREMENT,
-			title         TEXT NOT NULL,
-			snapshot_date TEXT NOT NULL,
-			proposals     INTEGER DEFAULT 0,
-			amendments    INTEGER DEFAULT 0,
-			finals        INTEGER DEFAULT 0,
-			captured_at   DATETIME,
-			source_hint   TEXT,
-			UNIQUE(title, snapshot_date)
-		)
-	`)
-	if err != nil {
-		db.Close()
-		return nil, err
-	}
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_lsa_title ON lsa_activity(title)`)
-
-	// Create agency_lsa table for per-agency LSA data from Federal Register API
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS agency_lsa (
-			id            INTEGER PRIMARY KEY AUTOINCREMENT,
-			agency_id     TEXT NOT NULL,
-			agency_name   TEXT NOT NULL,
-			proposed_rules INTEGER DEFAULT 0,
-			final_rules   INTEGER DEFAULT 0,
-			notices       INTEGER DEFAULT 0,
-			total_documents INTEGER DEFAULT 0,
-			snapshot_date TEXT NOT NULL,
-			captured_at   DATETIME,
-			source_hint   TEXT,
-			UNIQUE(agency_id, snapshot_date)
-		)
-	`)
-	if err != nil {
-		db.Close()
+// Open opens the database at path without migrating it, for tools that manage migrations
+// themselves.
+func Open(path string) (*Repo, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return nil, err
 	}
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_agency_lsa_agency ON agency_lsa(agency_id)`)
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_agency_lsa_snapshot ON agency_lsa(snapshot_date)`)
-
-	// Create summaries table for AI-generated summaries
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS summaries (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			kind TEXT NOT NULL DEFAULT 'title',
-			key TEXT NOT NULL,
-			text TEXT NOT NULL,
-			model TEXT DEFAULT 'gemini-2.5-pro',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(kind, key)
-		)
-	`)
+	// Immediate transactions take the write lock up front, so concurrent migrators queue on the
+	// busy timeout instead of failing to upgrade a read lock
+	db, err := sql.Open("sqlite3", path+"?_txlock=immediate")
 	if err != nil {
-		db.Close()
 		return nil, err
 	}
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_summaries_kind_key ON summaries(kind, key)`)
-
-	// Create indexes on sections table for faster checksum queries
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_sections_title ON sections(title)`)
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_sections_agency_id ON sections(agency_id)`)
-	db.Exec(`CREATE INDEX IF NOT EXISTS idx_sections_title_agency ON sections(title, agency_id)`)
-
-	// Add content_checksum column to agencies table if it doesn't exist
-	db.Exec(`ALTER TABLE agencies ADD COLUMN content_checksum TEXT`)
+	return &Repo{Queries: sqlquery.NewSQLite(db), Path: path, db: db}, nil
+}
 
-	return &Repo{Path: path, db: db}, nil
+func (r *Repo) Close() error {
+	return r.db.Close()
 }
 
+// InsertSections stores one version of each section per snapshot in section_versions, keyed by
+// domain.SectionKey, with text kept once per text hash in section_texts. Loading a section again
+// under the same snapshot replaces that version; other snapshots' versions are kept. Each part's
+// place in the title's hierarchy is recorded in cfr_parts for ResolvePartOwners, and the loaded
+// titles' totals in title_metrics.
 func (r *Repo) InsertSections(sections []domain.Section) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO sections (id, title, part, section, agency_id, path, text, rev_date, checksum_sha256, word_count, def_count, xref_count, modal_count, rscs_raw, rscs_per_1k, snapshot_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	defer tx.Rollback()
+
+	textStmt, err := tx.Prepare(`INSERT OR IGNORE INTO section_texts (text_sha256, text) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer textStmt.Close()
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO section_versions (section_key, snapshot_id, id, title, part, section, heading, agency_id, path, rev_date, checksum_sha256, text_sha256, word_count, def_count, xref_count, modal_count, rscs_raw, rscs_per_1k, snapshot_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
+	partStmt, err := tx.Prepare(`INSERT OR REPLACE INTO cfr_parts (title, part, subtitle, chapter, subchapter) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer partStmt.Close()
+	titles := make(map[string]bool)
+	parts := make(map[[2]string]bool)
 	for _, s := range sections {
-		_, err = stmt.Exec(s.ID, s.Title, s.Part, s.Section, s.AgencyID, s.Path, s.Text, s.RevDate, s.ChecksumSHA256, s.WordCount, s.DefCount, s.XrefCount, s.ModalCount, s.RSCSRaw, s.RSCSPer1K, s.SnapshotDate)
+		titles[s.Title] = true
+		if key := [2]string{s.Title, s.Part}; s.Part != "" && !parts[key] {
+			parts[key] = true
+			if _, err := partStmt.Exec(s.Title, s.Part, s.Subtitle, s.AgencyID, s.Subchapter); err != nil {
+				return err
+			}
+		}
+		textHash := s.TextSHA256
+		if textHash == "" {
+			textHash = domain.TextHash(s.Text)
+		}
+		if _, err := textStmt.Exec(textHash, s.Text); err != nil {
+			return err
+		}
+		section := s.Section
+		if section == "" {
+			section = s.ID
+		}
+		snapshotID := s.SnapshotID
+		if snapshotID == "" {
+			snapshotID = s.SnapshotDate
+		}
+		_, err = stmt.Exec(domain.SectionKey(s.Title, section), snapshotID, s.ID, s.Title, s.Part, s.Section, s.Heading, s.AgencyID, s.Path, s.RevDate, s.ChecksumSHA256, textHash, s.WordCount, s.DefCount, s.XrefCount, s.ModalCount, s.RSCSRaw, s.RSCSPer1K, s.SnapshotDate)
 		if err != nil {
-			tx.Rollback()
+			return err
+		}
+	}
+	if err := r.RefreshTitleMetrics(tx, titles); err != nil {
+		return err
+	}
+	if r.search {
+		if err := syncSearch(tx, titles); err != nil {
 			return err
 		}
 	}
 	return tx.Commit()
 }
 
-func (r *Repo) GetAgencyTotals(titleFilter *string) ([]domain.AgencyMetric, error) {
-	// Build query with JOIN through agency_cfr_references
-	// LSA counts now come directly from agency_lsa table (per-agency from Federal Register API)
+// GetSectionText returns the section text with the given SHA-256 (domain.TextHash).
+func (r *Repo) GetSectionText(hash string) (string, error) {
+	var text string
+	err := r.db.QueryRow(`SELECT text FROM section_texts WHERE text_sha256 = ?`, hash).Scan(&text)
+	if err == sql.ErrNoRows {
+		return "", domain.ErrNotFound
+	}
+	return text, err
+}
+
+// GetAgencyTotals reads each agency's words and RSCS, aggregated as opts asks, from
+// agency_metrics as of the last SnapshotAgencyMetrics, optionally limited to one title. With
+// opts.Rollup, each agency's totals include all of its descendants. LSA counts are the agency's
+// own and never filtered by title.
+func (r *Repo) GetAgencyTotals(titleFilter *string, opts domain.AgencyMetricsOptions) ([]domain.AgencyMetric, error) {
+	column, aggregation, err := rscsColumn(opts.Aggregation)
+	if err != nil {
+		return nil, err
+	}
+	title := ""
+	if titleFilter != nil {
+		title = *titleFilter
+	}
 	query := `
-		WITH agency_title_words AS (
-			-- Get word count per agency per title
-			SELECT
-				acr.agency_id,
-				acr.title,
-				COALESCE(SUM(s.word_count), 0) as title_words
-			FROM agency_cfr_references acr
-			LEFT JOIN sections s
-				ON s.title = CAST(acr.title AS TEXT)
-				AND s.agency_id = acr.chapter
-			GROUP BY acr.agency_id, acr.title
-		),
-		agency_totals AS (
-			-- Get total words per agency
-			SELECT agency_id, SUM(title_words) as total_words
-			FROM agency_title_words
-			GROUP BY agency_id
-		),
-		latest_agency_lsa AS (
-			-- Get latest LSA data per agency from agency_lsa table
-			SELECT agency_id, total_documents
-			FROM agency_lsa
-			WHERE snapshot_date = (SELECT MAX(snapshot_date) FROM agency_lsa)
+		WITH latest_agency_lsa AS (
+			-- Latest LSA counts of the Federal Register agencies each agency was matched to
+			SELECT fm.agency_id, SUM(l.total_documents) AS total_documents
+			FROM agency_lsa l
+			JOIN fr_agency_map fm ON fm.fr_slug = l.agency_id
+			WHERE l.snapshot_date = (SELECT MAX(snapshot_date) FROM agency_lsa) AND fm.agency_id <> ''
+			GROUP BY fm.agency_id
 		)
 		SELECT
 			a.id,
 			a.name,
 			a.parent_id,
-			COALESCE(at.total_words, 0) as total_words,
-			COALESCE(rscs.avg_rscs, 0) as avg_rscs,
+			COALESCE(m.total_words, 0) as total_words,
+			COALESCE(m.` + column + `, 0) as avg_rscs,
 			COALESCE(lsa.total_documents, 0) as lsa_counts,
 			a.content_checksum
 		FROM agencies a
-		LEFT JOIN agency_totals at ON at.agency_id = a.id
-		LEFT JOIN latest_agency_lsa lsa ON lsa.agency_id = a.id
-		LEFT JOIN (
-			-- Compute avg RSCS per agency
-			SELECT
-				acr.agency_id,
-				AVG(s.rscs_per_1k) as avg_rscs
-			FROM agency_cfr_references acr
-			LEFT JOIN sections s
-				ON s.title = CAST(acr.title AS TEXT)
-				AND s.agency_id = acr.chapter
-			GROUP BY acr.agency_id
-		) rscs ON rscs.agency_id = a.id
-	`
+		LEFT JOIN agency_metrics m ON m.agency_id = a.id AND m.title = ? AND m.scope = ?
+		LEFT JOIN latest_agency_lsa lsa ON lsa.agency_id = a.id`
+	if title != "" {
+		query += " WHERE m.total_words > 0"
+	}
+	query += " ORDER BY total_words DESC"
 
-	args := []any{}
-	if titleFilter != nil && *titleFilter != "" {
-		// For title filter, we need to filter the CTEs
-		// Note: LSA counts are still per-agency (not filtered by title) since they come from Federal Register API
-		query = `
-		WITH agency_title_words AS (
-			SELECT
-				acr.agency_id,
-				acr.title,
-				COALESCE(SUM(s.word_count), 0) as title_words
-			FROM agency_cfr_references acr
-			LEFT JOIN sections s
-				ON s.title = CAST(acr.title AS TEXT)
-				AND s.agency_id = acr.chapter
-			WHERE acr.title = CAST(? AS INTEGER)
-			GROUP BY acr.agency_id, acr.title
-		),
-		agency_totals AS (
-			SELECT agency_id, SUM(title_words) as total_words
-			FROM agency_title_words
-			GROUP BY agency_id
+	rows, err := r.db.Query(query, title, metricScope(opts.Rollup))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []domain.AgencyMetric
+	for rows.Next() {
+		var m domain.AgencyMetric
+		var checksum sql.NullString
+		if err := rows.Scan(&m.ID, &m.Name, &m.ParentID, &m.TotalWords, &m.AvgRSCS, &m.LSACounts, &checksum); err != nil {
+			return nil, err
+		}
+		m.RSCSAggregation = aggregation
+		if checksum.Valid {
+			m.ContentChecksum = checksum.String
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// GetAgencySubtree returns an agency and all of its descendants with their agency-wide metrics,
+// parents before children and siblings by total words. With opts.Rollup, each node's totals
+// include its own descendants. It returns domain.ErrNotFound for an unknown agency.
+func (r *Repo) GetAgencySubtree(agencyID string, opts domain.AgencyMetricsOptions) ([]domain.AgencyMetric, error) {
+	column, aggregation, err := rscsColumn(opts.Aggregation)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(`
+		WITH RECURSIVE subtree(id, depth) AS (
+			SELECT id, 0 FROM agencies WHERE id = ?
+			UNION
+			SELECT a.id, subtree.depth + 1 FROM agencies a JOIN subtree ON a.parent_id = subtree.id
 		),
 		latest_agency_lsa AS (
-			SELECT agency_id, total_documents
-			FROM agency_lsa
-			WHERE snapshot_date = (SELECT MAX(snapshot_date) FROM agency_lsa)
+			-- Latest LSA counts of the Federal Register agencies each agency was matched to
+			SELECT fm.agency_id, SUM(l.total_documents) AS total_documents
+			FROM agency_lsa l
+			JOIN fr_agency_map fm ON fm.fr_slug = l.agency_id
+			WHERE l.snapshot_date = (SELECT MAX(snapshot_date) FROM agency_lsa) AND fm.agency_id <> ''
+			GROUP BY fm.agency_id
 		)
 		SELECT
 			a.id,
 			a.name,
 			a.parent_id,
-			COALESCE(at.total_words, 0) as total_words,
-			COALESCE(rscs.avg_rscs, 0) as avg_rscs,
+			COALESCE(m.total_words, 0) as total_words,
+			COALESCE(m.`+column+`, 0) as avg_rscs,
 			COALESCE(lsa.total_documents, 0) as lsa_counts,
 			a.content_checksum
-		FROM agencies a
-		LEFT JOIN agency_totals at ON at.agency_id = a.id
+		FROM subtree
+		JOIN agencies a ON a.id = subtree.id
+		LEFT JOIN agency_metrics m ON m.agency_id = a.id AND m.title = '' AND m.scope = ?
 		LEFT JOIN latest_agency_lsa lsa ON lsa.agency_id = a.id
-		LEFT JOIN (
-			SELECT
-				acr.agency_id,
-				AVG(s.rscs_per_1k) as avg_rscs
-			FROM agency_cfr_references acr
-			LEFT JOIN sections s
-				ON s.title = CAST(acr.title AS TEXT)
-				AND s.agency_id = acr.chapter
-			WHERE acr.title = CAST(? AS INTEGER)
-			GROUP BY acr.agency_id
-		) rscs ON rscs.agency_id = a.id
-		WHERE at.total_words > 0
-		`
-		args = append(args, *titleFilter, *titleFilter)
-	}
-
-	query += " ORDER BY total_words DESC"
-
-	rows, err := r.db.Query(query, args...)
+		ORDER BY subtree.depth, total_words DESC, a.id`, agencyID, metricScope(opts.Rollup))
 	if err != nil {
 		return nil, err
 	}
@@ -298,14 +247,48 @@ func (r *Repo) GetAgencyTotals(titleFilter *string) ([]domain.AgencyMetric, erro
 		if err := rows.Scan(&m.ID, &m.Name, &m.ParentID, &m.TotalWords, &m.AvgRSCS, &m.LSACounts, &checksum); err != nil {
 			return nil, err
 		}
-		if checksum.Valid {
-			m.ContentChecksum = checksum.String
-		}
+		m.RSCSAggregation = aggregation
+		m.ContentChecksum = checksum.String
 		metrics = append(metrics, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("%w: agency %q", domain.ErrNotFound, agencyID)
+	}
 	return metrics, nil
 }
 
+func metricScope(rollup bool) string {
+	if rollup {
+		return scopeRollup
+	}
+	return scopeDirect
+}
+
+// The agency_metrics column holding each RSCS aggregation
+var rscsColumns = map[string]string{
+	domain.RSCSAggregationMean:         "avg_rscs",
+	domain.RSCSAggregationWeightedMean: "weighted_avg_rscs",
+	domain.RSCSAggregationMedian:       "median_rscs",
+	domain.RSCSAggregationP90:          "p90_rscs",
+	domain.RSCSAggregationTotalPer1K:   "total_rscs_per_1k",
+}
+
+// rscsColumn returns the column for an aggregation method and the method's name, with the mean
+// for an empty method.
+func rscsColumn(aggregation string) (string, string, error) {
+	if aggregation == "" {
+		aggregation = domain.RSCSAggregationMean
+	}
+	column, ok := rscsColumns[aggregation]
+	if !ok {
+		return "", "", fmt.Errorf("%w: unknown RSCS aggregation %q", domain.ErrInvalidData, aggregation)
+	}
+	return column, aggregation, nil
+}
+
 // InsertAgencyLSA inserts or updates LSA activity data for an agency
 func (r *Repo) InsertAgencyLSA(lsa domain.AgencyLSA) error {
 	_, err := r.db.Exec(`
@@ -343,14 +326,16 @@ func (r *Repo) InsertAgencyLSABatch(lsaRecords []domain.AgencyLSA) error {
 	return tx.Commit()
 }
 
-// GetAgencyLSA retrieves the latest LSA data for a specific agency
+// GetAgencyLSA retrieves the latest LSA data for an eCFR agency, through the Federal Register
+// agencies matched to it. When several are, it returns the one with the most documents.
 func (r *Repo) GetAgencyLSA(agencyID string) (*domain.AgencyLSA, error) {
 	var lsa domain.AgencyLSA
 	err := r.db.QueryRow(`
-		SELECT agency_id, agency_name, proposed_rules, final_rules, notices, total_documents, snapshot_date, captured_at, source_hint
-		FROM agency_lsa
-		WHERE agency_id = ?
-		ORDER BY snapshot_date DESC
+		SELECT l.agency_id, l.agency_name, l.proposed_rules, l.final_rules, l.notices, l.total_documents, l.snapshot_date, l.captured_at, l.source_hint
+		FROM agency_lsa l
+		JOIN fr_agency_map fm ON fm.fr_slug = l.agency_id
+		WHERE fm.agency_id = ?
+		ORDER BY l.snapshot_date DESC, l.total_documents DESC
 		LIMIT 1`, agencyID).Scan(
 		&lsa.AgencyID, &lsa.AgencyName, &lsa.ProposedRules, &lsa.FinalRules,
 		&lsa.Notices, &lsa.TotalDocuments, &lsa.SnapshotDate, &lsa.CapturedAt, &lsa.SourceHint)
@@ -398,13 +383,14 @@ func (r *Repo) HasRecentAgencyLSA(withinDays int) (bool, error) {
 // GetAgencyChecksum computes a SHA256 hash of all section content for an agency
 func (r *Repo) GetAgencyChecksum(agencyID string) (string, error) {
 	query := `
-		SELECT s.text
-		FROM sections s
-		JOIN agency_cfr_references acr
-			ON s.title = CAST(acr.title AS TEXT)
-			AND s.agency_id = acr.chapter
-		WHERE acr.agency_id = ?
-		ORDER BY s.id
+		SELECT COALESCE(st.text, '')
+		FROM current_sections s
+		LEFT JOIN section_texts st ON st.text_sha256 = s.text_sha256
+		JOIN part_owners po
+			ON po.title = s.title
+			AND po.part = s.part
+		WHERE po.agency_id = ?
+		ORDER BY s.id, s.title
 	`
 	rows, err := r.db.Query(query, agencyID)
 	if err != nil {
@@ -524,3 +510,432 @@ func (r *Repo) GetSummaryByKey(kind, key string) (*domain.Summary, error) {
 	}
 	return &s, nil
 }
+
+// Agency metric scopes: an agency's own parts, or those of its whole subtree
+const (
+	scopeDirect = "direct"
+	scopeRollup = "rollup"
+)
+
+// The (agency_id, title, part) rows each scope rolls up, from part_owners. Parts are distinct per
+// agency, so a parent and child owning the same part do not double count.
+var agencyParts = map[string]string{
+	scopeDirect: `SELECT agency_id, title, part FROM part_owners`,
+	scopeRollup: `
+		WITH RECURSIVE subtree(root, agency_id) AS (
+			SELECT id, id FROM agencies
+			UNION
+			SELECT subtree.root, a.id FROM agencies a JOIN subtree ON a.parent_id = subtree.agency_id
+		)
+		SELECT DISTINCT subtree.root AS agency_id, po.title, po.part
+		FROM subtree
+		JOIN part_owners po ON po.agency_id = subtree.agency_id`,
+}
+
+// rollupAgencyMetrics fills agency_metrics from the current sections, with the title expression,
+// GROUP BY list and agencyParts query substituted in. SQLite has no percentile aggregates, so
+// median_rscs and p90_rscs are left to fillRSCSPercentiles.
+const rollupAgencyMetrics = `
+	INSERT INTO agency_metrics
+	(agency_id, title, scope, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs,
+		total_rscs_per_1k, restrictions, section_count, computed_at)
+	SELECT
+		po.agency_id,
+		%[1]s,
+		?,
+		?,
+		COALESCE(SUM(s.word_count), 0),
+		COALESCE(SUM(s.rscs_raw), 0),
+		COALESCE(AVG(s.rscs_per_1k), 0),
+		COALESCE(SUM(s.rscs_per_1k * s.word_count) / NULLIF(SUM(CASE WHEN s.rscs_per_1k IS NOT NULL THEN s.word_count END), 0), 0),
+		COALESCE(1000.0 * SUM(s.rscs_raw) / NULLIF(SUM(s.word_count), 0), 0),
+		COALESCE(SUM(s.modal_count), 0),
+		COUNT(*),
+		?
+	FROM (%[3]s) po
+	JOIN current_sections s
+		ON s.title = po.title
+		AND s.part = po.part
+	GROUP BY %[2]s`
+
+// SnapshotAgencyMetrics rolls up the current sections into per-agency and per-(agency, title)
+// metrics, both for each agency's own parts and for its whole subtree, replacing
+// agency_metrics. It records the agency-wide rows of each agency's own parts in
+// agency_metrics_history under snapshotID and returns them. The ETL runs it once per snapshot, so API reads never aggregate
+// sections.
+func (r *Repo) SnapshotAgencyMetrics(snapshotID string, computedAt time.Time) ([]domain.AgencyMetricSnapshot, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM agency_metrics`); err != nil {
+		return nil, err
+	}
+	// One row per agency and title, then one across all of the agency's titles (title ''); the
+	// average is over sections either way
+	for _, scope := range []string{scopeDirect, scopeRollup} {
+		for _, rollup := range [][2]string{{"s.title", "po.agency_id, s.title"}, {"''", "po.agency_id"}} {
+			query := fmt.Sprintf(rollupAgencyMetrics, rollup[0], rollup[1], agencyParts[scope])
+			if _, err := tx.Exec(query, scope, snapshotID, computedAt); err != nil {
+				return nil, err
+			}
+		}
+		if err := fillRSCSPercentiles(tx, scope, agencyParts[scope]); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO agency_metrics_history
+		(agency_id, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs, median_rscs, p90_rscs,
+			total_rscs_per_1k, restrictions, section_count, computed_at)
+		SELECT agency_id, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs, median_rscs, p90_rscs,
+			total_rscs_per_1k, restrictions, section_count, computed_at
+		FROM agency_metrics
+		WHERE title = '' AND scope = 'direct'`)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT agency_id, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs, median_rscs, p90_rscs,
+			total_rscs_per_1k, restrictions, section_count, computed_at
+		FROM agency_metrics_history
+		WHERE snapshot_id = ?
+		ORDER BY agency_id`, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAgencyMetricSnapshots(rows)
+}
+
+// fillRSCSPercentiles sets median_rscs and p90_rscs on the agency_metrics rows of scope from the
+// current sections of each agency's parts, as listed by the parts query.
+func fillRSCSPercentiles(tx *sql.Tx, scope, parts string) error {
+	percentiles, err := rscsPercentiles(tx, `
+		SELECT po.agency_id, s.title, s.rscs_per_1k
+		FROM (`+parts+`) po
+		JOIN current_sections s
+			ON s.title = po.title
+			AND s.part = po.part`)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`UPDATE agency_metrics SET median_rscs = ?, p90_rscs = ? WHERE agency_id = ? AND title = ? AND scope = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for key, p := range percentiles {
+		if _, err := stmt.Exec(p[0], p[1], key[0], key[1], scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rscsPercentiles returns the median and 90th percentile of the RSCS per 1k words values query
+// selects, keyed by (agency, title) and by (agency, "") for the agency across all of its titles.
+// query selects (agency_id, title, rscs_per_1k) rows; NULL scores are skipped.
+func rscsPercentiles(tx *sql.Tx, query string) (map[[2]string][2]float64, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	values := map[[2]string][]float64{}
+	for rows.Next() {
+		var agencyID, title string
+		var v sql.NullFloat64
+		if err := rows.Scan(&agencyID, &title, &v); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !v.Valid {
+			continue
+		}
+		values[[2]string{agencyID, title}] = append(values[[2]string{agencyID, title}], v.Float64)
+		values[[2]string{agencyID, ""}] = append(values[[2]string{agencyID, ""}], v.Float64)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	percentiles := make(map[[2]string][2]float64, len(values))
+	for key, vs := range values {
+		sort.Float64s(vs)
+		percentiles[key] = [2]float64{percentile(vs, 0.5), percentile(vs, 0.9)}
+	}
+	return percentiles, nil
+}
+
+// percentile interpolates linearly between the closest ranks of sorted, as PostgreSQL's
+// percentile_cont does.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p * float64(len(sorted)-1)
+	lo := int(pos)
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[lo+1]-sorted[lo])*(pos-float64(lo))
+}
+
+// GetAgencyMetricsHistory retrieves the per-snapshot rollups for an agency, oldest first.
+// from/to are YYYY-MM-DD days compared against each snapshot's day; empty bounds are open.
+func (r *Repo) GetAgencyMetricsHistory(agencyID, from, to string) ([]domain.AgencyMetricSnapshot, error) {
+	query := `
+		SELECT agency_id, snapshot_id, total_words, total_rscs, avg_rscs, weighted_avg_rscs, median_rscs, p90_rscs,
+			total_rscs_per_1k, restrictions, section_count, computed_at
+		FROM agency_metrics_history
+		WHERE agency_id = ?`
+	args := []any{agencyID}
+	if from != "" {
+		query += " AND substr(snapshot_id, 1, 10) >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		query += " AND substr(snapshot_id, 1, 10) <= ?"
+		args = append(args, to)
+	}
+	query += " ORDER BY snapshot_id ASC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAgencyMetricSnapshots(rows)
+}
+
+func scanAgencyMetricSnapshots(rows *sql.Rows) ([]domain.AgencyMetricSnapshot, error) {
+	var results []domain.AgencyMetricSnapshot
+	for rows.Next() {
+		var m domain.AgencyMetricSnapshot
+		var computedAt sql.NullTime
+		if err := rows.Scan(&m.AgencyID, &m.SnapshotID, &m.TotalWords, &m.TotalRSCS, &m.AvgRSCS,
+			&m.WeightedAvgRSCS, &m.MedianRSCS, &m.P90RSCS, &m.TotalRSCSPer1K,
+			&m.Restrictions, &m.SectionCount, &computedAt); err != nil {
+			return nil, err
+		}
+		if computedAt.Valid {
+			m.ComputedAt = computedAt.Time
+		}
+		results = append(results, m)
+	}
+	return results, rows.Err()
+}
+
+// InsertDiffs stores the changed sections of a snapshot. Unchanged sections are skipped.
+func (r *Repo) InsertDiffs(snapshotID string, diffs []domain.Diff) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO section_diffs
+		(snapshot_id, prev_snapshot_id, title, section_id, part, agency_id, status, words_before, words_after, restrictions_before, restrictions_after)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, d := range diffs {
+		if !d.Changed {
+			continue
+		}
+		_, err = stmt.Exec(snapshotID, d.PrevSnapshotID, d.Title, d.SectionID, d.Part, d.AgencyID, d.Status,
+			d.WordsBefore, d.WordsAfter, d.RestrictionsBefore, d.RestrictionsAfter)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetScoreboard sums the words and restrictions each agency added and removed across
+// the snapshot diffs whose day falls in [from, to]. Empty bounds are open. Diffs against no previous
+// snapshot are ignored so the first snapshot does not count as everything being added.
+// Entries are returned unranked.
+func (r *Repo) GetScoreboard(from, to string) ([]domain.ScoreboardEntry, error) {
+	query := `
+		SELECT
+			a.id,
+			a.name,
+			COALESCE(SUM(CASE WHEN d.words_after < d.words_before THEN d.words_before - d.words_after ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.words_after > d.words_before THEN d.words_after - d.words_before ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.restrictions_after < d.restrictions_before THEN d.restrictions_before - d.restrictions_after ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.restrictions_after > d.restrictions_before THEN d.restrictions_after - d.restrictions_before ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN d.status = 'removed' THEN 1 ELSE 0 END), 0)
+		FROM section_diffs d
+		JOIN part_owners po
+			ON po.title = d.title
+			AND po.part = d.part
+		JOIN agencies a ON a.id = po.agency_id
+		WHERE COALESCE(d.prev_snapshot_id, '') != ''`
+	args := []any{}
+	if from != "" {
+		query += " AND substr(d.snapshot_id, 1, 10) >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		query += " AND substr(d.snapshot_id, 1, 10) <= ?"
+		args = append(args, to)
+	}
+	query += " GROUP BY a.id, a.name"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.ScoreboardEntry
+	for rows.Next() {
+		var e domain.ScoreboardEntry
+		if err := rows.Scan(&e.AgencyID, &e.AgencyName, &e.WordsRemoved, &e.WordsAdded,
+			&e.RestrictionsRemoved, &e.RestrictionsAdded, &e.SectionsRemoved); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetLatestDiffSnapshot returns the most recent snapshot with stored diffs, or "" if there are none
+func (r *Repo) GetLatestDiffSnapshot() (string, error) {
+	var snapshotID sql.NullString
+	if err := r.db.QueryRow(`SELECT MAX(snapshot_id) FROM section_diffs`).Scan(&snapshotID); err != nil {
+		return "", err
+	}
+	return snapshotID.String, nil
+}
+
+// GetSectionDiffs returns the changed sections stored for a snapshot, optionally limited to one title
+func (r *Repo) GetSectionDiffs(snapshotID, title string) ([]domain.Diff, error) {
+	query := `
+		SELECT section_id, title, COALESCE(part, ''), COALESCE(agency_id, ''), COALESCE(prev_snapshot_id, ''), status,
+			words_before, words_after, restrictions_before, restrictions_after
+		FROM section_diffs
+		WHERE snapshot_id = ?`
+	args := []any{snapshotID}
+	if title != "" {
+		query += " AND title = ?"
+		args = append(args, title)
+	}
+	query += " ORDER BY CAST(title AS INTEGER), section_id"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var diffs []domain.Diff
+	for rows.Next() {
+		var d domain.Diff
+		if err := rows.Scan(&d.SectionID, &d.Title, &d.Part, &d.AgencyID, &d.PrevSnapshotID, &d.Status,
+			&d.WordsBefore, &d.WordsAfter, &d.RestrictionsBefore, &d.RestrictionsAfter); err != nil {
+			return nil, err
+		}
+		d.DeltaWordCount = d.WordsAfter - d.WordsBefore
+		d.Changed = true
+		diffs = append(diffs, d)
+	}
+	return diffs, rows.Err()
+}
+
+// InsertDiffAttributions stores the Federal Register documents linked to changed sections
+func (r *Repo) InsertDiffAttributions(attributions []domain.DiffAttribution) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO diff_attributions
+		(snapshot_id, title, section_id, document_number, document_title, publication_date, html_url, match_kind)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, a := range attributions {
+		_, err = stmt.Exec(a.SnapshotID, a.Title, a.SectionID, a.DocumentNumber, a.DocumentTitle,
+			a.PublicationDate, a.HTMLURL, a.MatchKind)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDiffAttributions returns the attributions for a snapshot, optionally limited to one title,
+// newest documents first
+func (r *Repo) GetDiffAttributions(snapshotID, title string) ([]domain.DiffAttribution, error) {
+	query := `
+		SELECT snapshot_id, title, section_id, document_number, COALESCE(document_title, ''),
+			publication_date, COALESCE(html_url, ''), COALESCE(match_kind, '')
+		FROM diff_attributions
+		WHERE snapshot_id = ?`
+	args := []any{snapshotID}
+	if title != "" {
+		query += " AND title = ?"
+		args = append(args, title)
+	}
+	query += " ORDER BY publication_date DESC, document_number"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.DiffAttribution
+	for rows.Next() {
+		var a domain.DiffAttribution
+		var published sql.NullTime
+		if err := rows.Scan(&a.SnapshotID, &a.Title, &a.SectionID, &a.DocumentNumber, &a.DocumentTitle,
+			&published, &a.HTMLURL, &a.MatchKind); err != nil {
+			return nil, err
+		}
+		if published.Valid {
+			a.PublicationDate = published.Time
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+// PublishSnapshot records which snapshot is published for a day, replacing any earlier pointer
+func (r *Repo) PublishSnapshot(p domain.PublishedSnapshot) error {
+	_, err := r.db.Exec(`INSERT OR REPLACE INTO published_snapshots (day, snapshot_id, published_at) VALUES (?, ?, ?)`,
+		p.Day, p.SnapshotID, p.PublishedAt)
+	return err
+}
+
+// GetPublishedSnapshot returns the snapshot published for a day
+func (r *Repo) GetPublishedSnapshot(day string) (*domain.PublishedSnapshot, error) {
+	var p domain.PublishedSnapshot
+	err := r.db.QueryRow(`SELECT day, snapshot_id, published_at FROM published_snapshots WHERE day = ?`, day).
+		Scan(&p.Day, &p.SnapshotID, &p.PublishedAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,871 @@
+package sqlite
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/ecfr"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/usecase"
+)
+
+var _ usecase.Store = (*Repo)(nil)
+
+func newTestRepo(t *testing.T) *Repo {
+	t.Helper()
+	repo, err := NewRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewRepo failed: %v", err)
+	}
+	t.Cleanup(func() { repo.db.Close() })
+
+	// Two agencies sharing title 40: chapter I belongs to epa, chapter IV to doi.
+	// The duplicate reference must not double count.
+	for _, stmt := range []string{
+		`INSERT INTO agencies (id, name) VALUES ('epa', 'Environmental Protection Agency'), ('doi', 'Department of the Interior')`,
+		`INSERT INTO agency_cfr_references (agency_id, title, chapter) VALUES ('epa', 40, 'I'), ('epa', 40, 'I'), ('doi', 40, 'IV')`,
+	} {
+		if _, err := repo.db.Exec(stmt); err != nil {
+			t.Fatalf("seed failed: %v", err)
+		}
+	}
+	return repo
+}
+
+func TestSnapshotAgencyMetrics(t *testing.T) {
+	repo := newTestRepo(t)
+
+	sections := []domain.Section{
+		{ID: "60.1", Title: "40", Part: "60", AgencyID: "I", WordCount: 100, RSCSRaw: 300, RSCSPer1K: 3000, ModalCount: 2},
+		{ID: "60.2", Title: "40", Part: "60", AgencyID: "I", WordCount: 50, RSCSRaw: 50, RSCSPer1K: 1000, ModalCount: 0},
+		{ID: "400.1", Title: "40", Part: "400", AgencyID: "IV", WordCount: 10, RSCSRaw: 110, RSCSPer1K: 11000, ModalCount: 1},
+	}
+	if err := repo.InsertSections(sections); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+
+	got, err := repo.SnapshotAgencyMetrics("2025-01-01", time.Now())
+	if err != nil {
+		t.Fatalf("SnapshotAgencyMetrics failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 agency rollups, got %d", len(got))
+	}
+
+	epa := got[1]
+	if epa.AgencyID != "epa" {
+		t.Fatalf("expected epa second, got %q", epa.AgencyID)
+	}
+	if epa.TotalWords != 150 || epa.TotalRSCS != 350 || epa.Restrictions != 2 || epa.SectionCount != 2 {
+		t.Errorf("unexpected epa rollup: %+v", epa)
+	}
+	if epa.AvgRSCS != 2000 {
+		t.Errorf("expected epa avg_rscs 2000, got %v", epa.AvgRSCS)
+	}
+}
+
+func TestAgencyTotalsMaterialized(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "60.1", Title: "40", Part: "60", AgencyID: "I", WordCount: 100, RSCSPer1K: 3000, SnapshotID: "s1"},
+		{ID: "60.2", Title: "40", Part: "60", AgencyID: "I", WordCount: 50, RSCSPer1K: 1000, SnapshotID: "s1"},
+		{ID: "400.1", Title: "40", Part: "400", AgencyID: "IV", WordCount: 10, RSCSPer1K: 11000, SnapshotID: "s1"},
+		{ID: "1.1", Title: "7", Part: "1", AgencyID: "I", WordCount: 1000, RSCSPer1K: 500, SnapshotID: "s1"},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if _, err := repo.db.Exec(`INSERT INTO agency_cfr_references (agency_id, title, chapter) VALUES ('epa', 7, 'I')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+
+	// Nothing is rolled up until the snapshot is taken
+	totals, err := repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{})
+	if err != nil || len(totals) != 2 || totals[0].TotalWords != 0 {
+		t.Fatalf("totals before snapshot = %+v, %v", totals, err)
+	}
+	if _, err := repo.SnapshotAgencyMetrics("s1", time.Now()); err != nil {
+		t.Fatalf("SnapshotAgencyMetrics failed: %v", err)
+	}
+
+	totals, err = repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{})
+	if err != nil || len(totals) != 2 {
+		t.Fatalf("GetAgencyTotals = %+v, %v", totals, err)
+	}
+	if totals[0].ID != "epa" || totals[0].TotalWords != 1150 || totals[0].AvgRSCS != 1500 {
+		t.Errorf("epa across titles = %+v", totals[0])
+	}
+	if totals[1].ID != "doi" || totals[1].TotalWords != 10 {
+		t.Errorf("doi across titles = %+v", totals[1])
+	}
+
+	title := "40"
+	totals, err = repo.GetAgencyTotals(&title, domain.AgencyMetricsOptions{})
+	if err != nil || len(totals) != 2 || totals[0].ID != "epa" || totals[0].TotalWords != 150 || totals[0].AvgRSCS != 2000 {
+		t.Fatalf("title 40 totals = %+v, %v", totals, err)
+	}
+	title = "7"
+	totals, err = repo.GetAgencyTotals(&title, domain.AgencyMetricsOptions{})
+	if err != nil || len(totals) != 1 || totals[0].ID != "epa" || totals[0].TotalWords != 1000 {
+		t.Fatalf("title 7 totals = %+v, %v", totals, err)
+	}
+
+	// The migration fills the table from the current sections
+	tx, err := repo.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dropTables("agency_metrics")(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateAgencyMetricsUp(tx); err != nil {
+		t.Fatalf("migrateAgencyMetricsUp failed: %v", err)
+	}
+	if err := migrateAgencyRollupsUp(tx); err != nil {
+		t.Fatalf("migrateAgencyRollupsUp failed: %v", err)
+	}
+	if err := migrateRSCSAggregationsUp(tx); err != nil {
+		t.Fatalf("migrateRSCSAggregationsUp failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	totals, err = repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{})
+	if err != nil || totals[0].TotalWords != 1150 {
+		t.Fatalf("totals after backfill = %+v, %v", totals, err)
+	}
+	totals, err = repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{Aggregation: domain.RSCSAggregationP90})
+	if err != nil || math.Abs(totals[0].AvgRSCS-2600) > 1e-9 {
+		t.Fatalf("p90 after backfill = %+v, %v", totals, err)
+	}
+}
+
+func TestAgencyRSCSAggregations(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "60.1", Title: "40", Part: "60", AgencyID: "I", WordCount: 100, RSCSRaw: 300, RSCSPer1K: 3000, SnapshotID: "s1"},
+		{ID: "60.2", Title: "40", Part: "60", AgencyID: "I", WordCount: 50, RSCSRaw: 50, RSCSPer1K: 1000, SnapshotID: "s1"},
+		{ID: "60.3", Title: "40", Part: "60", AgencyID: "I", WordCount: 1000, RSCSRaw: 500, RSCSPer1K: 500, SnapshotID: "s1"},
+		{ID: "60.4", Title: "40", Part: "60", AgencyID: "I", WordCount: 850, RSCSRaw: 850, RSCSPer1K: 1000, SnapshotID: "s1"},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	if _, err := repo.SnapshotAgencyMetrics("s1", time.Now()); err != nil {
+		t.Fatalf("SnapshotAgencyMetrics failed: %v", err)
+	}
+
+	// epa's sections score 500, 1000, 1000 and 3000 over 2,000 words with 1,700 RSCS
+	history, err := repo.GetAgencyMetricsHistory("epa", "", "")
+	if err != nil || len(history) != 1 {
+		t.Fatalf("GetAgencyMetricsHistory = %+v, %v; want 1 point", history, err)
+	}
+	if h := history[0]; h.AvgRSCS != 1375 || h.WeightedAvgRSCS != 850 || h.MedianRSCS != 1000 ||
+		math.Abs(h.P90RSCS-2400) > 1e-9 || h.TotalRSCSPer1K != 850 {
+		t.Errorf("unexpected epa history: %+v", h)
+	}
+
+	for aggregation, want := range map[string]float64{
+		"":                                 1375,
+		domain.RSCSAggregationMean:         1375,
+		domain.RSCSAggregationWeightedMean: 850,
+		domain.RSCSAggregationMedian:       1000,
+		domain.RSCSAggregationP90:          2400,
+		domain.RSCSAggregationTotalPer1K:   850,
+	} {
+		totals, err := repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{Aggregation: aggregation})
+		if err != nil {
+			t.Fatalf("GetAgencyTotals(%q) failed: %v", aggregation, err)
+		}
+		epa := totals[0]
+		if epa.ID != "epa" || math.Abs(epa.AvgRSCS-want) > 1e-9 {
+			t.Errorf("%q: epa = %+v, want avg_rscs %v", aggregation, epa, want)
+		}
+		if method := epa.RSCSAggregation; method != aggregation && !(aggregation == "" && method == domain.RSCSAggregationMean) {
+			t.Errorf("%q: rscs_aggregation = %q", aggregation, epa.RSCSAggregation)
+		}
+	}
+
+	title := "40"
+	totals, err := repo.GetAgencyTotals(&title, domain.AgencyMetricsOptions{Aggregation: domain.RSCSAggregationMedian})
+	if err != nil || totals[0].AvgRSCS != 1000 {
+		t.Fatalf("title 40 median = %+v, %v", totals, err)
+	}
+	subtree, err := repo.GetAgencySubtree("epa", domain.AgencyMetricsOptions{Aggregation: domain.RSCSAggregationP90})
+	if err != nil || math.Abs(subtree[0].AvgRSCS-2400) > 1e-9 || subtree[0].RSCSAggregation != domain.RSCSAggregationP90 {
+		t.Fatalf("subtree p90 = %+v, %v", subtree, err)
+	}
+	if _, err := repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{Aggregation: "mode"}); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("unknown aggregation error = %v, want ErrInvalidData", err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	for _, tc := range []struct {
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{nil, 0.5, 0},
+		{[]float64{7}, 0.9, 7},
+		{[]float64{1, 2, 3, 4}, 0.5, 2.5},
+		{[]float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}, 0.9, 91},
+		{[]float64{1, 2, 3}, 1, 3},
+	} {
+		if got := percentile(tc.values, tc.p); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tc.values, tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestAgencyRollups(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// usda has no chapters of its own; fs and aphis are its children, and fs/ranger a grandchild.
+	// ranger shares fs's chapter, which the rollup must count once.
+	for _, stmt := range []string{
+		`INSERT INTO agencies (id, name) VALUES ('usda', 'Department of Agriculture')`,
+		`INSERT INTO agencies (id, name, parent_id) VALUES ('fs', 'Forest Service', 'usda'), ('aphis', 'Animal and Plant Health Inspection Service', 'usda')`,
+		`INSERT INTO agencies (id, name, parent_id) VALUES ('ranger', 'Ranger Office', 'fs')`,
+		`INSERT INTO agency_cfr_references (agency_id, title, chapter) VALUES ('fs', 36, 'II'), ('aphis', 7, 'III'), ('ranger', 36, 'II'), ('ranger', 36, 'IX')`,
+	} {
+		if _, err := repo.db.Exec(stmt); err != nil {
+			t.Fatalf("seed failed: %v", err)
+		}
+	}
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "200.1", Title: "36", Part: "200", AgencyID: "II", WordCount: 100, RSCSPer1K: 1000, SnapshotID: "s1"},
+		{ID: "900.1", Title: "36", Part: "900", AgencyID: "IX", WordCount: 5, RSCSPer1K: 4000, SnapshotID: "s1"},
+		{ID: "300.1", Title: "7", Part: "300", AgencyID: "III", WordCount: 40, RSCSPer1K: 1000, SnapshotID: "s1"},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	if _, err := repo.SnapshotAgencyMetrics("s1", time.Now()); err != nil {
+		t.Fatalf("SnapshotAgencyMetrics failed: %v", err)
+	}
+
+	words := func(totals []domain.AgencyMetric) map[string]int {
+		m := make(map[string]int)
+		for _, a := range totals {
+			m[a.ID] = a.TotalWords
+		}
+		return m
+	}
+	direct, err := repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{})
+	if err != nil {
+		t.Fatalf("GetAgencyTotals failed: %v", err)
+	}
+	if got := words(direct); got["usda"] != 0 || got["fs"] != 100 || got["ranger"] != 105 {
+		t.Errorf("direct totals = %v", got)
+	}
+	rolled, err := repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{Rollup: true})
+	if err != nil {
+		t.Fatalf("GetAgencyTotals failed: %v", err)
+	}
+	if got := words(rolled); got["usda"] != 145 || got["fs"] != 105 || got["aphis"] != 40 || got["ranger"] != 105 {
+		t.Errorf("rollup totals = %v", got)
+	}
+	if rolled[0].ID != "usda" || rolled[0].AvgRSCS != 2000 {
+		t.Errorf("usda rollup = %+v", rolled[0])
+	}
+
+	title := "7"
+	rolled, err = repo.GetAgencyTotals(&title, domain.AgencyMetricsOptions{Rollup: true})
+	if got := words(rolled); err != nil || len(got) != 2 || got["usda"] != 40 || got["aphis"] != 40 {
+		t.Errorf("title 7 rollup = %v, %v", got, err)
+	}
+
+	subtree, err := repo.GetAgencySubtree("usda", domain.AgencyMetricsOptions{Rollup: true})
+	if err != nil {
+		t.Fatalf("GetAgencySubtree failed: %v", err)
+	}
+	var ids []string
+	for _, a := range subtree {
+		ids = append(ids, a.ID)
+	}
+	if strings.Join(ids, ",") != "usda,fs,aphis,ranger" {
+		t.Errorf("subtree order = %v", ids)
+	}
+	if _, err := repo.GetAgencySubtree("nope", domain.AgencyMetricsOptions{}); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown agency error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestResolvePartOwners(t *testing.T) {
+	repo := newTestRepo(t)
+
+	for _, stmt := range []string{
+		`INSERT INTO agencies (id, name) VALUES ('oar', 'Office of Air and Radiation'), ('rus', 'Rural Utilities Service'),
+			('rhs', 'Rural Housing Service'), ('flra', 'Federal Labor Relations Authority'),
+			('flra-gc', 'FLRA General Counsel'), ('usda-os', 'Office of the Secretary of Agriculture')`,
+		// Part 60 of epa's chapter is delegated to oar
+		`INSERT INTO agency_cfr_references (agency_id, title, chapter, part) VALUES ('oar', 40, 'I', '60')`,
+		`INSERT INTO agency_cfr_references (agency_id, title, chapter) VALUES ('rus', 7, 'XVIII'), ('rhs', 7, 'XVIII'), ('flra', 5, 'XIV')`,
+		`INSERT INTO agency_cfr_references (agency_id, title, chapter, subchapter) VALUES ('flra-gc', 5, 'XIV', 'B')`,
+		`INSERT INTO agency_cfr_references (agency_id, title, subtitle, chapter) VALUES ('usda-os', 7, 'A', '')`,
+	} {
+		if _, err := repo.db.Exec(stmt); err != nil {
+			t.Fatalf("seed failed: %v", err)
+		}
+	}
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "60.1", Title: "40", Part: "60", AgencyID: "I", WordCount: 100, SnapshotID: "s1"},
+		{ID: "61.1", Title: "40", Part: "61", AgencyID: "I", WordCount: 10, SnapshotID: "s1"},
+		{ID: "1.1", Title: "7", Part: "1", Subtitle: "A", SnapshotID: "s1"},
+		{ID: "1980.1", Title: "7", Part: "1980", Subtitle: "B", AgencyID: "XVIII", SnapshotID: "s1"},
+		{ID: "2411.1", Title: "5", Part: "2411", AgencyID: "XIV", Subchapter: "A", SnapshotID: "s1"},
+		{ID: "2423.1", Title: "5", Part: "2423", AgencyID: "XIV", Subchapter: "B", SnapshotID: "s1"},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+
+	owners := func() map[string]string {
+		rows, err := repo.db.Query(`SELECT title || '/' || part, group_concat(agency_id || ':' || source, ',')
+			FROM (SELECT * FROM part_owners ORDER BY agency_id) GROUP BY title, part`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		got := make(map[string]string)
+		for rows.Next() {
+			var part, agencies string
+			if err := rows.Scan(&part, &agencies); err != nil {
+				t.Fatal(err)
+			}
+			got[part] = agencies
+		}
+		return got
+	}
+
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	want := map[string]string{
+		"40/60":  "oar:part",
+		"40/61":  "epa:chapter",
+		"7/1":    "usda-os:subtitle",
+		"7/1980": "rhs:chapter,rus:chapter",
+		"5/2411": "flra:chapter",
+		"5/2423": "flra-gc:subchapter",
+	}
+	if got := owners(); !reflect.DeepEqual(got, want) {
+		t.Errorf("owners = %v, want %v", got, want)
+	}
+
+	// Overrides replace the resolved owners of their parts
+	overrides := []domain.PartOwnerOverride{{Title: "7", Part: "1980", AgencyID: "rhs"}}
+	if err := repo.ResolvePartOwners(overrides); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	want["7/1980"] = "rhs:override"
+	if got := owners(); !reflect.DeepEqual(got, want) {
+		t.Errorf("owners with override = %v, want %v", got, want)
+	}
+	bad := append(overrides, domain.PartOwnerOverride{Title: "40", Part: "61", AgencyID: "nope"})
+	if err := repo.ResolvePartOwners(bad); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("unknown agency override error = %v, want ErrInvalidData", err)
+	}
+	if got := owners(); !reflect.DeepEqual(got, want) {
+		t.Errorf("owners after failed resolve = %v, want them kept", got)
+	}
+
+	// Metrics follow the parts: epa no longer counts oar's part 60
+	if _, err := repo.SnapshotAgencyMetrics("s1", time.Now()); err != nil {
+		t.Fatalf("SnapshotAgencyMetrics failed: %v", err)
+	}
+	totals, err := repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{})
+	if err != nil {
+		t.Fatalf("GetAgencyTotals failed: %v", err)
+	}
+	words := make(map[string]int)
+	for _, a := range totals {
+		words[a.ID] = a.TotalWords
+	}
+	if words["oar"] != 100 || words["epa"] != 10 {
+		t.Errorf("totals = %v", words)
+	}
+}
+
+func TestSyncAgencies(t *testing.T) {
+	repo := newTestRepo(t)
+	if _, err := repo.db.Exec(`INSERT INTO agencies (id, name) VALUES ('old', 'Abolished Office')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateAgencyChecksum("epa", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	// epa is renamed, doi moves under the new usda, oar is added under epa and old is gone
+	registry := []ecfr.Agency{
+		{Slug: "epa", Name: "Environmental Protection Agency", DisplayName: "EPA", Children: []ecfr.Agency{
+			{Slug: "oar", Name: "Office of Air and Radiation", CFRRefs: []ecfr.AgencyRef{{Title: 40, Chapter: "I", Part: "60"}}},
+		}, CFRRefs: []ecfr.AgencyRef{{Title: 40, Chapter: "I"}}},
+		{Slug: "usda", Name: "Department of Agriculture", Children: []ecfr.Agency{
+			{Slug: "doi", Name: "Department of the Interior"},
+		}},
+	}
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	changes, err := repo.SyncAgencies(registry, at)
+	if err != nil {
+		t.Fatalf("SyncAgencies failed: %v", err)
+	}
+	want := []domain.AgencyChange{
+		{AgencyID: "epa", Kind: domain.AgencyRenamed, OldValue: "Environmental Protection Agency", NewValue: "EPA", ChangedAt: at},
+		{AgencyID: "oar", Kind: domain.AgencyAdded, NewValue: "Office of Air and Radiation", ChangedAt: at},
+		{AgencyID: "usda", Kind: domain.AgencyAdded, NewValue: "Department of Agriculture", ChangedAt: at},
+		{AgencyID: "doi", Kind: domain.AgencyReparented, OldValue: "", NewValue: "usda", ChangedAt: at},
+		{AgencyID: "old", Kind: domain.AgencyRemoved, OldValue: "Abolished Office", ChangedAt: at},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+
+	var agencies, refs int
+	var parent, checksum string
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM agencies`).Scan(&agencies); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM agency_cfr_references`).Scan(&refs); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.db.QueryRow(`SELECT parent_id FROM agencies WHERE id = 'doi'`).Scan(&parent); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.db.QueryRow(`SELECT content_checksum FROM agencies WHERE id = 'epa'`).Scan(&checksum); err != nil {
+		t.Fatal(err)
+	}
+	if agencies != 4 || refs != 2 || parent != "usda" || checksum != "abc" {
+		t.Errorf("agencies = %d, refs = %d, doi parent = %q, epa checksum = %q", agencies, refs, parent, checksum)
+	}
+
+	// The log keeps removed agencies, and a sync that changes nothing adds nothing
+	if got, err := repo.GetAgencyChanges("old"); err != nil || len(got) != 1 || !got[0].ChangedAt.Equal(at) {
+		t.Errorf("GetAgencyChanges(old) = %+v, %v", got, err)
+	}
+	if _, err := repo.GetAgencyChanges("nope"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetAgencyChanges(nope) = %v, want ErrNotFound", err)
+	}
+	if changes, err := repo.SyncAgencies(registry, at.Add(time.Hour)); err != nil || len(changes) != 0 {
+		t.Errorf("second SyncAgencies = %+v, %v; want no changes", changes, err)
+	}
+
+	// An empty registry would remove every agency
+	if _, err := repo.SyncAgencies(nil, at); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("expected ErrInvalidData for an empty registry, got %v", err)
+	}
+}
+
+func TestFRAgencyMap(t *testing.T) {
+	repo := newTestRepo(t)
+
+	day := time.Now().UTC().Format("2006-01-02")
+	records := []domain.AgencyLSA{
+		{AgencyID: "environmental-protection-agency", TotalDocuments: 5, SnapshotDate: day},
+		{AgencyID: "air-and-radiation-office", TotalDocuments: 2, SnapshotDate: day},
+		{AgencyID: "census-bureau", TotalDocuments: 7, SnapshotDate: day},
+	}
+	if err := repo.InsertAgencyLSABatch(records); err != nil {
+		t.Fatalf("InsertAgencyLSABatch failed: %v", err)
+	}
+	matches := []domain.FRAgencyMatch{
+		{FRSlug: "environmental-protection-agency", AgencyID: "epa", MatchKind: domain.FRMatchName},
+		{FRSlug: "air-and-radiation-office", AgencyID: "epa", MatchKind: domain.FRMatchOverride},
+		{FRSlug: "census-bureau", MatchKind: domain.FRMatchUnmatched},
+	}
+	if err := repo.ReplaceFRAgencyMap(matches); err != nil {
+		t.Fatalf("ReplaceFRAgencyMap failed: %v", err)
+	}
+
+	totals, err := repo.GetAgencyTotals(nil, domain.AgencyMetricsOptions{})
+	if err != nil {
+		t.Fatalf("GetAgencyTotals failed: %v", err)
+	}
+	lsa := make(map[string]int)
+	for _, a := range totals {
+		lsa[a.ID] = a.LSACounts
+	}
+	if lsa["epa"] != 7 || lsa["doi"] != 0 {
+		t.Errorf("lsa_counts = %v, want epa 7 and doi 0", lsa)
+	}
+
+	got, err := repo.GetAgencyLSA("epa")
+	if err != nil || got == nil || got.AgencyID != "environmental-protection-agency" {
+		t.Errorf("GetAgencyLSA(epa) = %+v, %v", got, err)
+	}
+	if got, err := repo.GetAgencyLSA("doi"); err != nil || got != nil {
+		t.Errorf("GetAgencyLSA(doi) = %+v, %v; want nil", got, err)
+	}
+
+	agencies, err := repo.GetAgencies()
+	if err != nil || len(agencies) != 2 || agencies[0].ID != "doi" || agencies[0].ParentID != nil {
+		t.Errorf("GetAgencies = %+v, %v", agencies, err)
+	}
+}
+
+func TestTitles(t *testing.T) {
+	repo := newTestRepo(t)
+
+	amended := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+	if err := repo.UpsertTitles([]domain.Title{
+		{Title: "40", Name: "Protection of Environment", LatestAmendedOn: amended},
+		{Title: "35", Name: "[Reserved]", Reserved: true},
+	}); err != nil {
+		t.Fatalf("UpsertTitles failed: %v", err)
+	}
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "60.1", Title: "40", Part: "60", AgencyID: "I", WordCount: 100, RSCSRaw: 10, RSCSPer1K: 10, ModalCount: 2, SnapshotID: "s1"},
+		{ID: "60.2", Title: "40", Part: "60", AgencyID: "I", WordCount: 50, RSCSRaw: 20, RSCSPer1K: 40, SnapshotID: "s1"},
+		{ID: "1000.1", Title: "40", Part: "1000", AgencyID: "IV", WordCount: 300, RSCSRaw: 5, RSCSPer1K: 1, SnapshotID: "s1"},
+		{ID: "1.1", Title: "7", Part: "1", WordCount: 7, SnapshotID: "s1"},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	if _, err := repo.db.Exec(`INSERT INTO lsa_activity (title, snapshot_date, proposals, amendments, finals)
+		VALUES ('40', '2025-01-01', 1, 1, 1), ('40', '2025-02-01', 3, 2, 1)`); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+
+	titles, err := repo.GetTitleMetrics()
+	if err != nil {
+		t.Fatalf("GetTitleMetrics failed: %v", err)
+	}
+	var ids []string
+	for _, tm := range titles {
+		ids = append(ids, tm.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"7", "35", "40"}) {
+		t.Fatalf("titles = %v, want numeric order of catalog and loaded titles", ids)
+	}
+	want := domain.TitleMetric{ID: "40", Title: "Protection of Environment", LatestAmendedOn: "2025-03-04", SnapshotID: "s1",
+		TotalWords: 450, TotalRSCS: 35, AvgRSCS: 17, Restrictions: 2, SectionCount: 3}
+	if titles[2] != want {
+		t.Errorf("title 40 = %+v, want %+v", titles[2], want)
+	}
+	if !titles[1].Reserved || titles[1].SectionCount != 0 || titles[0].Title != "" || titles[0].SectionCount != 1 {
+		t.Errorf("titles 7 and 35 = %+v, %+v", titles[0], titles[1])
+	}
+
+	// Reloading a title refreshes its totals
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "1.1", Title: "7", Part: "1", WordCount: 9, SnapshotID: "s2"},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	detail, err := repo.GetTitleDetail("7")
+	if err != nil || detail.TotalWords != 9 || detail.SnapshotID != "s2" {
+		t.Errorf("GetTitleDetail(7) = %+v, %v; want the s2 totals", detail, err)
+	}
+
+	detail, err = repo.GetTitleDetail("40")
+	if err != nil {
+		t.Fatalf("GetTitleDetail failed: %v", err)
+	}
+	wantParts := []domain.PartMetric{
+		{Part: "60", TotalWords: 150, TotalRSCS: 30, AvgRSCS: 25, SectionCount: 2},
+		{Part: "1000", TotalWords: 300, TotalRSCS: 5, AvgRSCS: 1, SectionCount: 1},
+	}
+	if !reflect.DeepEqual(detail.TopParts, wantParts) {
+		t.Errorf("top parts = %+v, want %+v", detail.TopParts, wantParts)
+	}
+	wantAgencies := []domain.TitleAgency{
+		{ID: "doi", Name: "Department of the Interior", Parts: 1, TotalWords: 300},
+		{ID: "epa", Name: "Environmental Protection Agency", Parts: 1, TotalWords: 150},
+	}
+	if !reflect.DeepEqual(detail.Agencies, wantAgencies) {
+		t.Errorf("agencies = %+v, want %+v", detail.Agencies, wantAgencies)
+	}
+	if detail.Proposals != 3 || detail.Amendments != 2 || detail.Finals != 1 {
+		t.Errorf("LSA counts = %d/%d/%d, want the latest 3/2/1", detail.Proposals, detail.Amendments, detail.Finals)
+	}
+
+	if _, err := repo.GetTitleDetail("41"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown title error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetAgencyMetricsHistory_Range(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "60.1", Title: "40", Part: "60", AgencyID: "I", WordCount: 100},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	// A legacy date-only ID followed by run IDs; day bounds must include every run of that day
+	for _, snap := range []string{"2025-01-01", "2025-02-01T060000Z", "2025-03-01T120000Z", "2025-03-02T000000Z"} {
+		if _, err := repo.SnapshotAgencyMetrics(snap, time.Now()); err != nil {
+			t.Fatalf("SnapshotAgencyMetrics(%s) failed: %v", snap, err)
+		}
+	}
+
+	got, err := repo.GetAgencyMetricsHistory("epa", "2025-02-01", "2025-03-01")
+	if err != nil {
+		t.Fatalf("GetAgencyMetricsHistory failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(got))
+	}
+	if got[0].SnapshotID != "2025-02-01T060000Z" || got[1].SnapshotID != "2025-03-01T120000Z" {
+		t.Errorf("unexpected order: %s, %s", got[0].SnapshotID, got[1].SnapshotID)
+	}
+}
+
+func TestSectionTexts(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// The same text in two sections and two loads is stored once
+	for _, text := range []string{"shared", "shared"} {
+		sections := []domain.Section{
+			{ID: "60.1", Title: "40", Part: "60", AgencyID: "I", Text: text},
+			{ID: "60.2", Title: "40", Part: "60", AgencyID: "I", Text: text},
+		}
+		if err := repo.InsertSections(sections); err != nil {
+			t.Fatalf("InsertSections failed: %v", err)
+		}
+	}
+	var count int
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM section_texts`).Scan(&count); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 stored text, got %d", count)
+	}
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM current_sections WHERE text_sha256 = ?`, domain.TextHash("shared")).Scan(&count); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected both sections to reference the text by hash, got %d", count)
+	}
+
+	text, err := repo.GetSectionText(domain.TextHash("shared"))
+	if err != nil || text != "shared" {
+		t.Errorf("GetSectionText = %q, %v", text, err)
+	}
+	if _, err := repo.GetSectionText("missing"); err != domain.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Agency checksums still cover the text
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	sum, err := repo.GetAgencyChecksum("epa")
+	if err != nil {
+		t.Fatalf("GetAgencyChecksum failed: %v", err)
+	}
+	if sum == "" {
+		t.Errorf("Expected a checksum over the agency's text")
+	}
+}
+
+func TestSectionVersions(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Section 1.1 exists in two titles, and title 40 is loaded twice; nothing overwrites
+	loads := [][]domain.Section{
+		{
+			{ID: "§ 1.1", Section: "§ 1.1", Title: "40", Part: "1", AgencyID: "I", WordCount: 10, SnapshotID: "2025-01-01T000000Z"},
+			{ID: "§ 1.2", Section: "§ 1.2", Title: "40", Part: "1", AgencyID: "I", WordCount: 5, SnapshotID: "2025-01-01T000000Z"},
+			{ID: "§ 1.1", Section: "§ 1.1", Title: "7", Part: "1", AgencyID: "I", WordCount: 20, SnapshotID: "2025-01-01T000000Z"},
+		},
+		{
+			{ID: "§ 1.1", Section: "§ 1.1", Title: "40", Part: "1", AgencyID: "I", WordCount: 30, SnapshotID: "2025-02-01T000000Z"},
+		},
+	}
+	for _, sections := range loads {
+		if err := repo.InsertSections(sections); err != nil {
+			t.Fatalf("InsertSections failed: %v", err)
+		}
+	}
+
+	var versions int
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM section_versions`).Scan(&versions); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if versions != 4 {
+		t.Errorf("Expected 4 section versions, got %d", versions)
+	}
+
+	// The current state is each title's latest load: 40:1.2 was dropped by the second load
+	rows, err := repo.db.Query(`SELECT section_key, word_count FROM current_sections ORDER BY section_key`)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+	got := map[string]int{}
+	for rows.Next() {
+		var key string
+		var words int
+		if err := rows.Scan(&key, &words); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		got[key] = words
+	}
+	if len(got) != 2 || got["40:1.1"] != 30 || got["7:1.1"] != 20 {
+		t.Errorf("unexpected current sections: %v", got)
+	}
+}
+
+func TestSectionDetail(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.UpsertTitles([]domain.Title{{Title: "40", Name: "Protection of Environment"}}); err != nil {
+		t.Fatalf("UpsertTitles failed: %v", err)
+	}
+	revDate := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "§ 60.5", Section: "§ 60.5", Title: "40", Part: "60", AgencyID: "I", Subchapter: "C", Heading: "§ 60.5 Determination of construction.",
+			Text: "The Administrator shall determine.", RevDate: revDate, WordCount: 4, ModalCount: 1, RSCSRaw: 104, RSCSPer1K: 26000, SnapshotID: "s2"},
+		{ID: "§ 1.1", Section: "§ 1.1", Title: "40", Part: "1", AgencyID: "I", SnapshotID: "s2"},
+		{ID: "§ 1.1", Section: "§ 1.1", Title: "7", Part: "1", SnapshotID: "s2"},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	if err := repo.InsertDiffs("s1", []domain.Diff{{SectionID: "§ 60.5", Title: "40", Status: domain.DiffAdded, WordsAfter: 3, Changed: true}}); err != nil {
+		t.Fatalf("InsertDiffs failed: %v", err)
+	}
+	if err := repo.InsertDiffs("s2", []domain.Diff{{SectionID: "§ 60.5", Title: "40", PrevSnapshotID: "s1", Status: domain.DiffModified, WordsBefore: 3, WordsAfter: 4, RestrictionsAfter: 1, Changed: true}}); err != nil {
+		t.Fatalf("InsertDiffs failed: %v", err)
+	}
+
+	d, err := repo.GetSectionDetail("40:60.5")
+	if err != nil {
+		t.Fatalf("GetSectionDetail failed: %v", err)
+	}
+	if d.ID != "§ 60.5" || d.TitleName != "Protection of Environment" || d.Chapter != "I" || d.Subchapter != "C" || d.Part != "60" ||
+		d.Text != "The Administrator shall determine." || d.RevDate != "2024-07-01" || d.SnapshotID != "s2" || d.RSCSRaw != 104 || d.ModalCount != 1 {
+		t.Errorf("GetSectionDetail = %+v", d)
+	}
+	if want := []domain.AgencyRef{{ID: "epa", Name: "Environmental Protection Agency"}}; !reflect.DeepEqual(d.Agencies, want) {
+		t.Errorf("agencies = %+v, want %+v", d.Agencies, want)
+	}
+	if len(d.Changes) != 2 || d.Changes[0].Status != domain.DiffAdded || d.Changes[1].PrevSnapshotID != "s1" || d.Changes[1].WordsAfter != 4 {
+		t.Errorf("changes = %+v", d.Changes)
+	}
+
+	keys, err := repo.FindSectionKeys("1.1")
+	if err != nil || !reflect.DeepEqual(keys, []string{"7:1.1", "40:1.1"}) {
+		t.Errorf("FindSectionKeys(1.1) = %v, %v", keys, err)
+	}
+	if _, err := repo.GetSectionDetail("40:99.9"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown section error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAgencyDetail(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// fs has a child, ranger, which shares fs's chapter II and alone holds chapter IX
+	for _, stmt := range []string{
+		`INSERT INTO agencies (id, name, short_name) VALUES ('usda', 'Department of Agriculture', 'USDA')`,
+		`INSERT INTO agencies (id, name, parent_id) VALUES ('fs', 'Forest Service', 'usda'), ('ranger', 'Ranger Office', 'fs')`,
+		`INSERT INTO agency_cfr_references (agency_id, title, chapter) VALUES ('fs', 36, 'II'), ('fs', 36, 'II'), ('ranger', 36, 'II'), ('ranger', 36, 'IX')`,
+	} {
+		if _, err := repo.db.Exec(stmt); err != nil {
+			t.Fatalf("seed failed: %v", err)
+		}
+	}
+	if err := repo.UpsertTitles([]domain.Title{{Title: "36", Name: "Parks, Forests, and Public Property"}}); err != nil {
+		t.Fatalf("UpsertTitles failed: %v", err)
+	}
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "200.1", Title: "36", Part: "200", AgencyID: "II", WordCount: 100, RSCSRaw: 100, RSCSPer1K: 1000, SnapshotID: "s1"},
+		{ID: "200.2", Title: "36", Part: "200", AgencyID: "II", WordCount: 50, RSCSRaw: 150, RSCSPer1K: 3000, SnapshotID: "s1"},
+		{ID: "900.1", Title: "36", Part: "900", AgencyID: "IX", WordCount: 5, RSCSRaw: 20, RSCSPer1K: 4000, SnapshotID: "s1"},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+	if _, err := repo.SnapshotAgencyMetrics("s1", time.Now()); err != nil {
+		t.Fatalf("SnapshotAgencyMetrics failed: %v", err)
+	}
+
+	d, err := repo.GetAgencyDetail("fs", domain.AgencyMetricsOptions{})
+	if err != nil {
+		t.Fatalf("GetAgencyDetail failed: %v", err)
+	}
+	if d.ID != "fs" || d.TotalWords != 150 || d.Parent == nil || *d.Parent != (domain.AgencyRef{ID: "usda", Name: "Department of Agriculture"}) {
+		t.Errorf("fs = %+v, parent %+v", d.AgencyMetric, d.Parent)
+	}
+	if len(d.Children) != 1 || d.Children[0].ID != "ranger" || d.Children[0].TotalWords != 155 {
+		t.Errorf("children = %+v", d.Children)
+	}
+	if want := []domain.AgencyCFRReference{{Title: "36", Chapter: "II"}}; !reflect.DeepEqual(d.CFRReferences, want) {
+		t.Errorf("cfr references = %+v, want %+v", d.CFRReferences, want)
+	}
+	wantTitles := []domain.AgencyTitleMetric{{Title: "36", TitleName: "Parks, Forests, and Public Property", TotalWords: 150, TotalRSCS: 250, AvgRSCS: 2000, SectionCount: 2}}
+	if !reflect.DeepEqual(d.Titles, wantTitles) {
+		t.Errorf("titles = %+v, want %+v", d.Titles, wantTitles)
+	}
+	wantParts := []domain.AgencyPartMetric{{Title: "36", PartMetric: domain.PartMetric{Part: "200", TotalWords: 150, TotalRSCS: 250, AvgRSCS: 2000, SectionCount: 2}}}
+	if !reflect.DeepEqual(d.Parts, wantParts) {
+		t.Errorf("parts = %+v, want %+v", d.Parts, wantParts)
+	}
+
+	// Parts use the requested aggregation, as the per-title totals do
+	for _, tc := range []struct {
+		aggregation string
+		want        float64
+	}{
+		{domain.RSCSAggregationWeightedMean, 250000.0 / 150},
+		{domain.RSCSAggregationMedian, 2000},
+		{domain.RSCSAggregationP90, 2800},
+		{domain.RSCSAggregationTotalPer1K, 1000.0 * 250 / 150},
+	} {
+		d, err := repo.GetAgencyDetail("fs", domain.AgencyMetricsOptions{Aggregation: tc.aggregation})
+		if err != nil {
+			t.Fatalf("GetAgencyDetail(%s) failed: %v", tc.aggregation, err)
+		}
+		if len(d.Parts) != 1 || math.Abs(d.Parts[0].AvgRSCS-tc.want) > 1e-9 || math.Abs(d.Titles[0].AvgRSCS-tc.want) > 1e-9 {
+			t.Errorf("%s: parts %+v, titles %+v, want avg %v", tc.aggregation, d.Parts, d.Titles, tc.want)
+		}
+	}
+
+	// With the rollup, ranger's chapter IX counts and the shared part 200 counts once
+	d, err = repo.GetAgencyDetail("fs", domain.AgencyMetricsOptions{Rollup: true})
+	if err != nil {
+		t.Fatalf("GetAgencyDetail failed: %v", err)
+	}
+	if d.TotalWords != 155 || len(d.Parts) != 2 || d.Parts[0].Part != "200" || d.Parts[1].Part != "900" || d.Titles[0].TotalWords != 155 {
+		t.Errorf("fs rollup = %+v, titles %+v, parts %+v", d.AgencyMetric, d.Titles, d.Parts)
+	}
+
+	usda, err := repo.GetAgencyDetail("usda", domain.AgencyMetricsOptions{})
+	if err != nil || usda.ShortName != "USDA" || usda.Parent != nil || len(usda.Parts) != 0 {
+		t.Errorf("usda = %+v, %v", usda, err)
+	}
+	if _, err := repo.GetAgencyDetail("nope", domain.AgencyMetricsOptions{}); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown agency error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetAgencyDetail("fs", domain.AgencyMetricsOptions{Aggregation: "mode"}); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("unknown aggregation error = %v, want ErrInvalidData", err)
+	}
+}
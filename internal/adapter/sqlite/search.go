@@ -0,0 +1,223 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// section_search is an FTS5 index over the heading and text of current_sections. FTS5 is only
+// compiled into go-sqlite3 under the sqlite_fts5 build tag, so the index is not a migration: it
+// is created when a build that has FTS5 opens the database, and search reports
+// domain.ErrUnsupported otherwise. The API and ETL must both be built with the tag, or loads by
+// a build without it leave the index stale.
+const createSearchIndex = `CREATE VIRTUAL TABLE section_search USING fts5(
+	section_key UNINDEXED,
+	title UNINDEXED,
+	part UNINDEXED,
+	section UNINDEXED,
+	agency_id UNINDEXED,
+	heading,
+	text,
+	tokenize = 'porter unicode61'
+)`
+
+// bm25 weights, one per section_search column; a match in the heading counts ten times one in
+// the text
+const searchWeights = `0, 0, 0, 0, 0, 10.0, 1.0`
+
+// Matched terms are marked with control characters that cannot occur in section text, then
+// turned into <mark> elements once the rest has been escaped.
+const (
+	markOpen  = "\x02"
+	markClose = "\x03"
+)
+
+// initSearch creates and fills section_search if this build has FTS5 and the database does not
+// have the index yet.
+func (r *Repo) initSearch() error {
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'section_search'`).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		// Check the existing index is usable by this build
+		_, err := r.db.Exec(`SELECT 1 FROM section_search LIMIT 1`)
+		r.search = err == nil
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(createSearchIndex); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return nil
+		}
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO section_search (section_key, title, part, section, agency_id, heading, text)
+		SELECT s.section_key, s.title, s.part, s.section, s.agency_id, COALESCE(s.heading, ''), COALESCE(st.text, '')
+		FROM current_sections s
+		LEFT JOIN section_texts st ON st.text_sha256 = s.text_sha256`)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.search = true
+	return nil
+}
+
+// syncSearch replaces the indexed sections of each title with its current sections.
+func syncSearch(tx *sql.Tx, titles map[string]bool) error {
+	for title := range titles {
+		if _, err := tx.Exec(`DELETE FROM section_search WHERE title = ?`, title); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO section_search (section_key, title, part, section, agency_id, heading, text)
+			SELECT s.section_key, s.title, s.part, s.section, s.agency_id, COALESCE(s.heading, ''), COALESCE(st.text, '')
+			FROM current_sections s
+			LEFT JOIN section_texts st ON st.text_sha256 = s.text_sha256
+			WHERE s.title = ?`, title)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search runs a full-text query over the current sections. See domain.SearchQuery for the
+// query syntax.
+func (r *Repo) Search(q domain.SearchQuery) (*domain.SearchResults, error) {
+	if !r.search {
+		return nil, fmt.Errorf("%w: full-text search needs a build with the sqlite_fts5 tag", domain.ErrUnsupported)
+	}
+	match := ftsQuery(q.Query)
+	if match == "" {
+		return nil, fmt.Errorf("%w: query %q has no terms", domain.ErrInvalidData, q.Query)
+	}
+
+	const agencyFilter = `(? = '' OR EXISTS (
+		SELECT 1 FROM part_owners po
+		WHERE po.agency_id = ? AND po.title = section_search.title AND po.part = section_search.part))`
+	const titleFilter = `(? = '' OR section_search.title = ?)`
+
+	res := &domain.SearchResults{Query: q.Query, Limit: q.Limit, Offset: q.Offset, Hits: []domain.SearchHit{}}
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM section_search WHERE section_search MATCH ? AND `+agencyFilter+` AND `+titleFilter,
+		match, q.AgencyID, q.AgencyID, q.Title, q.Title).Scan(&res.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT section_key, title, part, section, agency_id,
+			highlight(section_search, 5, ?, ?),
+			snippet(section_search, 6, ?, ?, '…', 32),
+			-bm25(section_search, `+searchWeights+`) AS score
+		FROM section_search
+		WHERE section_search MATCH ? AND `+agencyFilter+` AND `+titleFilter+`
+		ORDER BY score DESC, title, section_key
+		LIMIT ? OFFSET ?`,
+		markOpen, markClose, markOpen, markClose, match, q.AgencyID, q.AgencyID, q.Title, q.Title, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h domain.SearchHit
+		var part, section, agencyID sql.NullString
+		if err := rows.Scan(&h.SectionKey, &h.Title, &part, &section, &agencyID, &h.Heading, &h.Snippet, &h.Score); err != nil {
+			return nil, err
+		}
+		h.Part, h.Section, h.AgencyID = part.String, section.String, agencyID.String
+		h.Heading = markHTML(h.Heading)
+		h.Snippet = markHTML(h.Snippet)
+		res.Hits = append(res.Hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	res.Agencies, err = r.facet(`
+		SELECT po.agency_id, a.name, COUNT(*) AS n
+		FROM section_search
+		JOIN part_owners po
+			ON po.title = section_search.title AND po.part = section_search.part
+		JOIN agencies a ON a.id = po.agency_id
+		WHERE section_search MATCH ? AND `+titleFilter+`
+		GROUP BY po.agency_id
+		ORDER BY n DESC, po.agency_id`, match, q.Title, q.Title)
+	if err != nil {
+		return nil, err
+	}
+	res.Titles, err = r.facet(`
+		SELECT title, '', COUNT(*) AS n
+		FROM section_search
+		WHERE section_search MATCH ? AND `+agencyFilter+`
+		GROUP BY title
+		ORDER BY n DESC, CAST(title AS INTEGER)`, match, q.AgencyID, q.AgencyID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repo) facet(query string, args ...interface{}) ([]domain.FacetCount, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	facets := []domain.FacetCount{}
+	for rows.Next() {
+		var f domain.FacetCount
+		if err := rows.Scan(&f.Value, &f.Label, &f.Count); err != nil {
+			return nil, err
+		}
+		facets = append(facets, f)
+	}
+	return facets, rows.Err()
+}
+
+// ftsQuery turns a search box query into an FTS5 expression: each "quoted phrase" and each bare
+// term becomes a quoted FTS5 string, so operators and punctuation in the input cannot make the
+// expression invalid. FTS5 requires all of them to match.
+func ftsQuery(q string) string {
+	var parts []string
+	for i, segment := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			// Inside quotes: one phrase
+			if phrase := strings.Join(strings.Fields(segment), " "); strings.IndexFunc(phrase, isTokenRune) >= 0 {
+				parts = append(parts, `"`+phrase+`"`)
+			}
+			continue
+		}
+		for _, term := range strings.Fields(segment) {
+			if strings.IndexFunc(term, isTokenRune) >= 0 {
+				parts = append(parts, `"`+term+`"`)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// isTokenRune reports whether r can be part of a token under the unicode61 tokenizer, which
+// treats punctuation and symbols as separators. A term without any such rune matches nothing.
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// markHTML escapes highlighted text and turns the match markers into <mark> elements.
+func markHTML(s string) string {
+	s = html.EscapeString(s)
+	return strings.NewReplacer(markOpen, "<mark>", markClose, "</mark>").Replace(s)
+}
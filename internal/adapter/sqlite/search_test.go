@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+func TestFTSQuery(t *testing.T) {
+	for q, want := range map[string]string{
+		`emission standards`:          `"emission" "standards"`,
+		`"new source" performance`:    `"new source" "performance"`,
+		`  "  new   source " `:        `"new source"`,
+		`OR NOT AND`:                  `"OR" "NOT" "AND"`,
+		`§ 60.1 -- *`:                 `"60.1"`,
+		`"unterminated phrase`:        `"unterminated phrase"`,
+		`"" - ?`:                      ``,
+		`nitrogen "oxides of" sulfur`: `"nitrogen" "oxides of" "sulfur"`,
+	} {
+		if got := ftsQuery(q); got != want {
+			t.Errorf("ftsQuery(%q) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	repo := newTestRepo(t)
+	if !repo.search {
+		t.Skip("FTS5 not compiled in; run with -tags sqlite_fts5")
+	}
+
+	sections := []domain.Section{
+		{ID: "60.1", Section: "60.1", Title: "40", Part: "60", AgencyID: "I", SnapshotID: "s1", Heading: "§ 60.1 Applicability.", Text: "The provisions apply to each new source of air pollution."},
+		{ID: "60.2", Section: "60.2", Title: "40", Part: "60", AgencyID: "I", SnapshotID: "s1", Heading: "§ 60.2 Definitions.", Text: "Source means any building that emits pollution <regulated>."},
+		{ID: "3.1", Section: "3.1", Title: "40", Part: "3", AgencyID: "IV", SnapshotID: "s1", Heading: "§ 3.1 Scope.", Text: "Mineral leases on public lands."},
+	}
+	if err := repo.InsertSections(sections); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "1.1", Section: "1.1", Title: "43", Part: "1", AgencyID: "II", SnapshotID: "s1", Heading: "§ 1.1 Purpose.", Text: "Pollution from new sources on public lands."},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	if err := repo.ResolvePartOwners(nil); err != nil {
+		t.Fatalf("ResolvePartOwners failed: %v", err)
+	}
+
+	res, err := repo.Search(domain.SearchQuery{Query: "pollution", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if res.Total != 3 || len(res.Hits) != 3 {
+		t.Fatalf("pollution: total %d, %d hits; want 3", res.Total, len(res.Hits))
+	}
+	// Only title 40 maps to agencies, and both of its pollution hits are epa's
+	if len(res.Agencies) != 1 || res.Agencies[0] != (domain.FacetCount{Value: "epa", Label: "Environmental Protection Agency", Count: 2}) {
+		t.Errorf("agency facets = %+v", res.Agencies)
+	}
+	if len(res.Titles) != 2 || res.Titles[0] != (domain.FacetCount{Value: "40", Count: 2}) || res.Titles[1] != (domain.FacetCount{Value: "43", Count: 1}) {
+		t.Errorf("title facets = %+v", res.Titles)
+	}
+	for _, h := range res.Hits {
+		if h.SectionKey == "40:60.2" && h.Snippet != "Source means any building that emits <mark>pollution</mark> &lt;regulated&gt;." {
+			t.Errorf("snippet = %q", h.Snippet)
+		}
+	}
+
+	// Stemming matches "sources" too
+	res, err = repo.Search(domain.SearchQuery{Query: "source", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if res.Total != 3 {
+		t.Fatalf("source: total %d, want 3", res.Total)
+	}
+	res, err = repo.Search(domain.SearchQuery{Query: "definitions", Limit: 10})
+	if err != nil || len(res.Hits) != 1 || res.Hits[0].Heading != "§ 60.2 <mark>Definitions</mark>." {
+		t.Fatalf("definitions = %+v, %v", res, err)
+	}
+
+	// Phrases match only as written
+	res, err = repo.Search(domain.SearchQuery{Query: `"public lands" mineral`, Limit: 10})
+	if err != nil || res.Total != 1 || res.Hits[0].SectionKey != "40:3.1" {
+		t.Fatalf("phrase = %+v, %v", res, err)
+	}
+	res, err = repo.Search(domain.SearchQuery{Query: `"lands public"`, Limit: 10})
+	if err != nil || res.Total != 0 {
+		t.Fatalf("reversed phrase = %+v, %v", res, err)
+	}
+
+	// Filters narrow the hits; each facet ignores its own filter
+	res, err = repo.Search(domain.SearchQuery{Query: "pollution", AgencyID: "epa", Limit: 10})
+	if err != nil || res.Total != 2 || len(res.Agencies) != 1 || len(res.Titles) != 1 {
+		t.Fatalf("agency filter = %+v, %v", res, err)
+	}
+	res, err = repo.Search(domain.SearchQuery{Query: "pollution", Title: "43", Limit: 10})
+	if err != nil || res.Total != 1 || len(res.Titles) != 2 || len(res.Agencies) != 0 {
+		t.Fatalf("title filter = %+v, %v", res, err)
+	}
+
+	// A later snapshot of a title replaces its indexed sections
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "1.1", Section: "1.1", Title: "43", Part: "1", AgencyID: "II", SnapshotID: "s2", Heading: "§ 1.1 Purpose.", Text: "Grazing on public lands."},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+	res, err = repo.Search(domain.SearchQuery{Query: "pollution", Limit: 10})
+	if err != nil || res.Total != 2 {
+		t.Fatalf("after reload: %+v, %v", res, err)
+	}
+
+	if _, err := repo.Search(domain.SearchQuery{Query: `"" ?`, Limit: 10}); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("empty query error = %v, want ErrInvalidData", err)
+	}
+}
+
+func TestSearchIndexBackfill(t *testing.T) {
+	repo := newTestRepo(t)
+	if !repo.search {
+		t.Skip("FTS5 not compiled in; run with -tags sqlite_fts5")
+	}
+	if err := repo.InsertSections([]domain.Section{
+		{ID: "60.1", Section: "60.1", Title: "40", Part: "60", AgencyID: "I", SnapshotID: "s1", Text: "Opacity standards."},
+	}); err != nil {
+		t.Fatalf("InsertSections failed: %v", err)
+	}
+
+	// A database first opened by a build without FTS5 gets its index on the next open
+	if _, err := repo.db.Exec(`DROP TABLE section_search`); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewRepo(repo.Path)
+	if err != nil {
+		t.Fatalf("NewRepo failed: %v", err)
+	}
+	defer reopened.Close()
+	res, err := reopened.Search(domain.SearchQuery{Query: "opacity", Limit: 10})
+	if err != nil || res.Total != 1 || !strings.Contains(res.Hits[0].Snippet, "<mark>Opacity</mark>") {
+		t.Fatalf("after backfill: %+v, %v", res, err)
+	}
+}
+
+func TestSearchUnavailable(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.search = false
+	if _, err := repo.Search(domain.SearchQuery{Query: "pollution"}); !errors.Is(err, domain.ErrUnsupported) {
+		t.Errorf("Search error = %v, want ErrUnsupported", err)
+	}
+}
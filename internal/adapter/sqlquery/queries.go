@@ -0,0 +1,47 @@
+// Package sqlquery runs the store queries whose SQL is the same for SQLite and PostgreSQL, so
+// both stores answer them from one copy. The queries are written with ? placeholders, which
+// Rebind rewrites for PostgreSQL.
+package sqlquery
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Queries runs the shared queries against one database.
+type Queries struct {
+	db     *sql.DB
+	rebind func(string) string
+}
+
+// NewSQLite returns the shared queries for a SQLite database, which takes ? placeholders as
+// written.
+func NewSQLite(db *sql.DB) *Queries {
+	return &Queries{db: db, rebind: func(query string) string { return query }}
+}
+
+// NewPostgres returns the shared queries for a PostgreSQL database.
+func NewPostgres(db *sql.DB) *Queries {
+	return &Queries{db: db, rebind: Rebind}
+}
+
+// Rebind numbers the ? placeholders of query as PostgreSQL's $1, $2 and so on. Every ? is taken
+// for a placeholder, so the shared queries keep none in string literals.
+func Rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for {
+		i := strings.IndexByte(query, '?')
+		if i < 0 {
+			b.WriteString(query)
+			return b.String()
+		}
+		n++
+		b.WriteString(query[:i])
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+		query = query[i+1:]
+	}
+}
@@ -0,0 +1,17 @@
+package sqlquery
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	for _, tc := range []struct{ query, want string }{
+		{`SELECT 1`, `SELECT 1`},
+		{`SELECT * FROM t WHERE a = ?`, `SELECT * FROM t WHERE a = $1`},
+		{`VALUES (?, ?, ?) LIMIT ?`, `VALUES ($1, $2, $3) LIMIT $4`},
+		{`(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, `($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`},
+		{`?`, `$1`},
+	} {
+		if got := Rebind(tc.query); got != tc.want {
+			t.Errorf("Rebind(%q) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
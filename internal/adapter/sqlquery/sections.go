@@ -0,0 +1,108 @@
+package sqlquery
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// FindSectionKeys lists the keys of the current sections numbered section (without "§") in any
+// title, in numeric title order.
+func (q *Queries) FindSectionKeys(section string) ([]string, error) {
+	rows, err := q.db.Query(q.rebind(`
+		SELECT section_key
+		FROM current_sections
+		WHERE section_key = title || ':' || ?
+		GROUP BY title, section_key
+		ORDER BY LENGTH(title), title, section_key`), section)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetSectionDetail returns the current version of a section with its text, metrics, place in
+// its title, the agencies owning its part and the snapshots it changed in. It returns
+// domain.ErrNotFound for a key with no current section. The breadcrumb, citation and summary
+// are left empty.
+func (q *Queries) GetSectionDetail(key string) (*domain.SectionDetail, error) {
+	var d domain.SectionDetail
+	var revDate sql.NullTime
+	err := q.db.QueryRow(q.rebind(`
+		SELECT s.section_key, COALESCE(s.id, ''), COALESCE(s.title, ''), COALESCE(t.name, ''), COALESCE(p.subtitle, ''),
+			COALESCE(s.agency_id, ''), COALESCE(p.subchapter, ''), COALESCE(s.part, ''), COALESCE(s.section, ''),
+			COALESCE(s.heading, ''), COALESCE(st.text, ''), s.rev_date, s.snapshot_id, COALESCE(s.checksum_sha256, ''),
+			COALESCE(s.word_count, 0), COALESCE(s.def_count, 0), COALESCE(s.xref_count, 0), COALESCE(s.modal_count, 0),
+			COALESCE(s.rscs_raw, 0), COALESCE(s.rscs_per_1k, 0)
+		FROM current_sections s
+		LEFT JOIN section_texts st ON st.text_sha256 = s.text_sha256
+		LEFT JOIN titles t ON t.title = s.title
+		LEFT JOIN cfr_parts p ON p.title = s.title AND p.part = s.part
+		WHERE s.section_key = ?`), key).Scan(
+		&d.Key, &d.ID, &d.Title, &d.TitleName, &d.Subtitle, &d.Chapter, &d.Subchapter, &d.Part, &d.Section,
+		&d.Heading, &d.Text, &revDate, &d.SnapshotID, &d.ChecksumSHA256,
+		&d.WordCount, &d.DefCount, &d.XrefCount, &d.ModalCount, &d.RSCSRaw, &d.RSCSPer1K)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: section %q", domain.ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Revision dates are stored as UTC midnight; PostgreSQL hands them back in the local zone
+	if revDate.Valid {
+		d.RevDate = domain.FormatDay(revDate.Time.UTC())
+	}
+
+	d.Agencies = []domain.AgencyRef{}
+	rows, err := q.db.Query(q.rebind(`
+		SELECT a.id, a.name
+		FROM part_owners po
+		JOIN agencies a ON a.id = po.agency_id
+		WHERE po.title = ? AND po.part = ?
+		ORDER BY a.id`), d.Title, d.Part)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a domain.AgencyRef
+		if err := rows.Scan(&a.ID, &a.Name); err != nil {
+			return nil, err
+		}
+		d.Agencies = append(d.Agencies, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	d.Changes = []domain.SectionChange{}
+	rows, err = q.db.Query(q.rebind(`
+		SELECT snapshot_id, COALESCE(prev_snapshot_id, ''), status, words_before, words_after,
+			restrictions_before, restrictions_after
+		FROM section_diffs
+		WHERE title = ? AND section_id = ?
+		ORDER BY snapshot_id`), d.Title, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c domain.SectionChange
+		if err := rows.Scan(&c.SnapshotID, &c.PrevSnapshotID, &c.Status, &c.WordsBefore, &c.WordsAfter,
+			&c.RestrictionsBefore, &c.RestrictionsAfter); err != nil {
+			return nil, err
+		}
+		d.Changes = append(d.Changes, c)
+	}
+	return &d, rows.Err()
+}
@@ -0,0 +1,165 @@
+package sqlquery
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// UpsertTitles stores the eCFR title catalog, replacing the metadata of titles already stored.
+func (q *Queries) UpsertTitles(titles []domain.Title) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(q.rebind(`
+		INSERT INTO titles (title, name, latest_amended_on, latest_issue_date, up_to_date_as_of, reserved)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(title) DO UPDATE SET
+			name = excluded.name,
+			latest_amended_on = excluded.latest_amended_on,
+			latest_issue_date = excluded.latest_issue_date,
+			up_to_date_as_of = excluded.up_to_date_as_of,
+			reserved = excluded.reserved`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, t := range titles {
+		_, err := stmt.Exec(t.Title, t.Name, domain.FormatDay(t.LatestAmendedOn), domain.FormatDay(t.LatestIssueDate), domain.FormatDay(t.UpToDateAsOf), t.Reserved)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RefreshTitleMetrics recomputes the totals of each title from its current sections, in the
+// transaction that loaded them.
+func (q *Queries) RefreshTitleMetrics(tx *sql.Tx, titles map[string]bool) error {
+	for title := range titles {
+		if _, err := tx.Exec(q.rebind(`DELETE FROM title_metrics WHERE title = ?`), title); err != nil {
+			return err
+		}
+		_, err := tx.Exec(q.rebind(`
+			INSERT INTO title_metrics (title, snapshot_id, total_words, total_rscs, avg_rscs, restrictions, section_count)
+			SELECT title, MAX(snapshot_id), COALESCE(SUM(word_count), 0), COALESCE(SUM(rscs_raw), 0),
+				COALESCE(AVG(rscs_per_1k), 0), COALESCE(SUM(modal_count), 0), COUNT(*)
+			FROM current_sections
+			WHERE title = ?
+			GROUP BY title`), title)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// titleMetricsQuery lists every title with metadata, totals or both
+const titleMetricsQuery = `
+	SELECT k.title, COALESCE(t.name, ''), COALESCE(t.latest_amended_on, ''), COALESCE(t.latest_issue_date, ''),
+		COALESCE(t.up_to_date_as_of, ''), t.reserved IS TRUE, COALESCE(m.snapshot_id, ''),
+		COALESCE(m.total_words, 0), COALESCE(m.total_rscs, 0), COALESCE(m.avg_rscs, 0),
+		COALESCE(m.restrictions, 0), COALESCE(m.section_count, 0)
+	FROM (SELECT title FROM titles UNION SELECT title FROM title_metrics) k
+	LEFT JOIN titles t ON t.title = k.title
+	LEFT JOIN title_metrics m ON m.title = k.title`
+
+func scanTitleMetric(row interface{ Scan(...interface{}) error }) (domain.TitleMetric, error) {
+	var t domain.TitleMetric
+	err := row.Scan(&t.ID, &t.Title, &t.LatestAmendedOn, &t.LatestIssueDate, &t.UpToDateAsOf, &t.Reserved,
+		&t.SnapshotID, &t.TotalWords, &t.TotalRSCS, &t.AvgRSCS, &t.Restrictions, &t.SectionCount)
+	return t, err
+}
+
+// GetTitleMetrics lists every title known from the eCFR catalog or from loaded sections, in
+// numeric order.
+func (q *Queries) GetTitleMetrics() ([]domain.TitleMetric, error) {
+	rows, err := q.db.Query(titleMetricsQuery + ` ORDER BY LENGTH(k.title), k.title`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var titles []domain.TitleMetric
+	for rows.Next() {
+		t, err := scanTitleMetric(rows)
+		if err != nil {
+			return nil, err
+		}
+		titles = append(titles, t)
+	}
+	return titles, rows.Err()
+}
+
+// GetTitleDetail returns a title's metadata and totals with its heaviest parts, the agencies
+// owning its parts and its latest LSA counts, which are zero when none were recorded. It returns
+// domain.ErrNotFound for a title neither in the catalog nor loaded. The summary is left empty.
+func (q *Queries) GetTitleDetail(title string) (*domain.TitleDetail, error) {
+	metric, err := scanTitleMetric(q.db.QueryRow(q.rebind(titleMetricsQuery+` WHERE k.title = ?`), title))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: title %q", domain.ErrNotFound, title)
+	}
+	if err != nil {
+		return nil, err
+	}
+	detail := &domain.TitleDetail{TitleMetric: metric, TopParts: []domain.PartMetric{}, Agencies: []domain.TitleAgency{}}
+
+	rows, err := q.db.Query(q.rebind(`
+		SELECT part, COALESCE(SUM(word_count), 0), COALESCE(SUM(rscs_raw), 0), COALESCE(AVG(rscs_per_1k), 0), COUNT(*)
+		FROM current_sections
+		WHERE title = ? AND COALESCE(part, '') <> ''
+		GROUP BY part
+		ORDER BY COALESCE(SUM(rscs_raw), 0) DESC, part
+		LIMIT ?`), title, domain.TopPartsLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p domain.PartMetric
+		if err := rows.Scan(&p.Part, &p.TotalWords, &p.TotalRSCS, &p.AvgRSCS, &p.SectionCount); err != nil {
+			return nil, err
+		}
+		detail.TopParts = append(detail.TopParts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.db.Query(q.rebind(`
+		SELECT a.id, a.name, COUNT(DISTINCT po.part), COALESCE(SUM(s.word_count), 0)
+		FROM part_owners po
+		JOIN agencies a ON a.id = po.agency_id
+		LEFT JOIN current_sections s ON s.title = po.title AND s.part = po.part
+		WHERE po.title = ?
+		GROUP BY a.id, a.name
+		ORDER BY COALESCE(SUM(s.word_count), 0) DESC, a.id`), title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a domain.TitleAgency
+		if err := rows.Scan(&a.ID, &a.Name, &a.Parts, &a.TotalWords); err != nil {
+			return nil, err
+		}
+		detail.Agencies = append(detail.Agencies, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = q.db.QueryRow(q.rebind(`
+		SELECT COALESCE(proposals, 0), COALESCE(amendments, 0), COALESCE(finals, 0)
+		FROM lsa_activity
+		WHERE title = ?
+		ORDER BY snapshot_date DESC
+		LIMIT 1`), title).Scan(&detail.Proposals, &detail.Amendments, &detail.Finals)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return detail, nil
+}
@@ -0,0 +1,33 @@
+// Package store opens the relational store the configuration selects.
+package store
+
+import (
+	"path/filepath"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/postgres"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/platform"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/usecase"
+)
+
+// Open connects to PostgreSQL when DATABASE_URL is set and otherwise opens the SQLite database
+// under the data directory, applying pending migrations either way.
+func Open(config platform.Config) (usecase.Store, error) {
+	if config.DatabaseURL != "" {
+		repo, err := postgres.NewRepo(config.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	}
+	repo, err := sqlite.NewRepo(SQLitePath(config))
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// SQLitePath is where the SQLite store lives when PostgreSQL is not configured.
+func SQLitePath(config platform.Config) string {
+	return filepath.Join(config.DataDir, "ecfr.db")
+}
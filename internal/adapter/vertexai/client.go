@@ -13,20 +13,21 @@ import (
 	aiplatform "cloud.google.com/go/aiplatform/apiv1"
 	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
 	"cloud.google.com/go/storage"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
-	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	"google.golang.org/genai"
 )
 
 type Client struct {
-	projectID     string
-	location      string
-	modelID       string
-	gcsBucket     string
-	client        *genai.Client
-	jobClient     *aiplatform.JobClient
-	storageClient *storage.Client
+	projectID string
+	location  string
+	modelID   string
+	gcsBucket string
+	client    *genai.Client
+	jobClient *aiplatform.JobClient
+	// buckets opens the blob store for a bucket; batch output can land outside gcsBucket
+	buckets func(bucket string) blob.Store
 }
 
 func NewClient(ctx context.Context, projectID, location, modelID, gcsBucket string) (*Client, error) {
@@ -52,13 +53,15 @@ func NewClient(ctx context.Context, projectID, location, modelID, gcsBucket stri
 	}
 
 	return &Client{
-		projectID:     projectID,
-		location:      location,
-		modelID:       modelID,
-		gcsBucket:     gcsBucket,
-		client:        genaiClient,
-		jobClient:     jobClient,
-		storageClient: storageClient,
+		projectID: projectID,
+		location:  location,
+		modelID:   modelID,
+		gcsBucket: gcsBucket,
+		client:    genaiClient,
+		jobClient: jobClient,
+		buckets: func(bucket string) blob.Store {
+			return blob.NewGCS(storageClient, bucket)
+		},
 	}, nil
 }
 
@@ -190,8 +193,11 @@ type batchGoogleSearch struct {
 }
 
 func (c *Client) uploadBatchInput(ctx context.Context, prompts []string, fileName string) error {
-	wc := c.storageClient.Bucket(c.gcsBucket).Object(fileName).NewWriter(ctx)
-	defer wc.Close()
+	wc, err := c.buckets(c.gcsBucket).Create(ctx, fileName)
+	if err != nil {
+		return err
+	}
+	defer wc.Abort()
 
 	encoder := json.NewEncoder(wc)
 
@@ -233,7 +239,7 @@ func (c *Client) uploadBatchInput(ctx context.Context, prompts []string, fileNam
 			return err
 		}
 	}
-	return nil
+	return wc.Close()
 }
 
 func (c *Client) submitBatchJob(ctx context.Context, inputURI, outputPrefix, uniqueID string) (string, error) {
@@ -314,26 +320,22 @@ func (c *Client) downloadBatchResults(ctx context.Context, outputURI string, exp
 
 	// fmt.Printf("Listing objects in bucket: %s, prefix: %s\n", bucketName, prefix)
 
-	it := c.storageClient.Bucket(bucketName).Objects(ctx, &storage.Query{Prefix: prefix})
+	store := c.buckets(bucketName)
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
 
 	var results []string
 
-	for {
-		attrs, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		// fmt.Printf("Found object: %s\n", attrs.Name)
+	for _, key := range keys {
+		// fmt.Printf("Found object: %s\n", key)
 
-		if !strings.HasSuffix(attrs.Name, ".jsonl") {
+		if !strings.HasSuffix(key, ".jsonl") {
 			continue
 		}
 
-		rc, err := c.storageClient.Bucket(bucketName).Object(attrs.Name).NewReader(ctx)
+		rc, err := store.Open(ctx, key)
 		if err != nil {
 			return nil, err
 		}
@@ -407,26 +409,22 @@ func (c *Client) FetchSummariesFromBucket(ctx context.Context, bucketName string
 		}, nil
 	}
 
-	it := c.storageClient.Bucket(bucketName).Objects(ctx, &storage.Query{})
+	store := c.buckets(bucketName)
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
 	var summaries []string
 
-	for {
-		attrs, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to list objects: %w", err)
-		}
-
-		if !strings.HasSuffix(attrs.Name, ".jsonl") {
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".jsonl") {
 			continue
 		}
 
-		rc, err := c.storageClient.Bucket(bucketName).Object(attrs.Name).NewReader(ctx)
+		rc, err := store.Open(ctx, key)
 		if err != nil {
 			// Log error but continue with other files? For now, we'll return error to be safe.
-			return nil, fmt.Errorf("failed to read object %s: %w", attrs.Name, err)
+			return nil, fmt.Errorf("failed to read object %s: %w", key, err)
 		}
 		defer rc.Close()
 
@@ -1,5 +1,7 @@
 package http
 
+import "github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+
 type AgencyDTO struct {
 	ID          string  `json:"id"`
 	Name        string  `json:"name"`
@@ -8,3 +10,50 @@ type AgencyDTO struct {
 	LSACounts   int     `json:"lsa_counts"`
 	LastUpdated string  `json:"last_updated"`
 }
+
+type TimeSeriesDTO struct {
+	AgencyID string                   `json:"agency_id"`
+	Metric   string                   `json:"metric"`
+	Points   []domain.TimeSeriesPoint `json:"points"`
+}
+
+type DiffDTO struct {
+	SectionID          string                   `json:"section_id"`
+	Title              string                   `json:"title"`
+	Part               string                   `json:"part"`
+	AgencyID           string                   `json:"agency_id"`
+	Status             string                   `json:"status"`
+	PrevSnapshotID     string                   `json:"prev_snapshot_id"`
+	WordsBefore        int                      `json:"words_before"`
+	WordsAfter         int                      `json:"words_after"`
+	DeltaWordCount     int                      `json:"delta_word_count"`
+	RestrictionsBefore int                      `json:"restrictions_before"`
+	RestrictionsAfter  int                      `json:"restrictions_after"`
+	Attributions       []domain.DiffAttribution `json:"attributions"`
+}
+
+type SnapshotDiffDTO struct {
+	SnapshotID string    `json:"snapshot_id"`
+	Diffs      []DiffDTO `json:"diffs"`
+}
+
+func toDiffDTO(d domain.AttributedDiff) DiffDTO {
+	attributions := d.Attributions
+	if attributions == nil {
+		attributions = []domain.DiffAttribution{}
+	}
+	return DiffDTO{
+		SectionID:          d.SectionID,
+		Title:              d.Title,
+		Part:               d.Part,
+		AgencyID:           d.AgencyID,
+		Status:             d.Status,
+		PrevSnapshotID:     d.PrevSnapshotID,
+		WordsBefore:        d.WordsBefore,
+		WordsAfter:         d.WordsAfter,
+		DeltaWordCount:     d.DeltaWordCount,
+		RestrictionsBefore: d.RestrictionsBefore,
+		RestrictionsAfter:  d.RestrictionsAfter,
+		Attributions:       attributions,
+	}
+}
@@ -2,19 +2,28 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
 
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/usecase"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
 type Usecases struct {
-	Ingest    *usecase.Ingest
-	Snapshot  *usecase.Snapshot
-	Metrics   *usecase.Metrics
-	Summaries *usecase.Summaries
+	Ingest     *usecase.Ingest
+	Snapshot   *usecase.Snapshot
+	Metrics    *usecase.Metrics
+	Agencies   *usecase.Agencies
+	Summaries  *usecase.Summaries
+	Scoreboard *usecase.Scoreboard
+	Search     *usecase.Search
+	Titles     *usecase.Titles
+	Sections   *usecase.Sections
 }
 
 func SetupHandlers(r chi.Router, usecases Usecases, logger *zap.Logger) {
@@ -28,9 +37,18 @@ func SetupHandlers(r chi.Router, usecases Usecases, logger *zap.Logger) {
 
 		// Check if checksums are requested
 		includeChecksum := req.URL.Query().Get("include_checksum") == "true"
+		opts := domain.AgencyMetricsOptions{
+			// Roll child agencies up into their parents
+			Rollup:      req.URL.Query().Get("rollup") == "true",
+			Aggregation: req.URL.Query().Get("aggregation"),
+		}
 
-		agencies, err := usecases.Metrics.GetAgencyTotals(tf)
+		agencies, err := usecases.Metrics.GetAgencyTotals(tf, opts)
 		if err != nil {
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 			logger.Error("Get agencies failed", zap.Error(err))
 			http.Error(w, "Internal error", http.StatusInternalServerError)
 			return
@@ -83,32 +101,302 @@ func SetupHandlers(r chi.Router, usecases Usecases, logger *zap.Logger) {
 		}
 	})
 
+	r.Get("/agencies/{id}", func(w http.ResponseWriter, req *http.Request) {
+		agencyID := chi.URLParam(req, "id")
+		opts := domain.AgencyMetricsOptions{
+			Rollup:      req.URL.Query().Get("rollup") == "true",
+			Aggregation: req.URL.Query().Get("aggregation"),
+		}
+
+		agency, err := usecases.Agencies.GetAgency(agencyID, opts)
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				http.Error(w, "Agency not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Error("Get agency failed", zap.String("agency_id", agencyID), zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(agency); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/agencies/{id}/children", func(w http.ResponseWriter, req *http.Request) {
+		agencyID := chi.URLParam(req, "id")
+		opts := domain.AgencyMetricsOptions{
+			Rollup:      req.URL.Query().Get("rollup") == "true",
+			Aggregation: req.URL.Query().Get("aggregation"),
+		}
+
+		tree, err := usecases.Metrics.GetAgencyTree(agencyID, opts)
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				http.Error(w, "Agency not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Error("Get agency tree failed", zap.String("agency_id", agencyID), zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tree); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/agencies/{id}/timeseries", func(w http.ResponseWriter, req *http.Request) {
+		agencyID := chi.URLParam(req, "id")
+		q := req.URL.Query()
+		metric := q.Get("metric")
+		if metric == "" {
+			metric = usecase.MetricWords
+		}
+
+		points, err := usecases.Metrics.GetAgencyTimeSeries(agencyID, q.Get("from"), q.Get("to"), metric)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Error("Get agency time series failed", zap.String("agency_id", agencyID), zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := TimeSeriesDTO{AgencyID: agencyID, Metric: metric, Points: points}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/agencies/{id}/changes", func(w http.ResponseWriter, req *http.Request) {
+		agencyID := chi.URLParam(req, "id")
+		changes, err := usecases.Metrics.GetAgencyChanges(agencyID)
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				http.Error(w, "Agency not found", http.StatusNotFound)
+				return
+			}
+			logger.Error("Get agency changes failed", zap.String("agency_id", agencyID), zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(changes); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/scoreboard", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		entries, err := usecases.Scoreboard.Compute(q.Get("from"), q.Get("to"))
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Error("Scoreboard computation failed", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if entries == nil {
+			entries = []domain.ScoreboardEntry{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(entries); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/search", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		query := domain.SearchQuery{Query: q.Get("q"), AgencyID: q.Get("agency"), Title: q.Get("title")}
+		for _, p := range []struct {
+			name string
+			dst  *int
+		}{{"limit", &query.Limit}, {"offset", &query.Offset}} {
+			if v := q.Get(p.name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					http.Error(w, p.name+" must be an integer", http.StatusBadRequest)
+					return
+				}
+				*p.dst = n
+			}
+		}
+
+		results, err := usecases.Search.Search(query)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, domain.ErrUnsupported) {
+				http.Error(w, "Search is not available", http.StatusNotImplemented)
+				return
+			}
+			logger.Error("Search failed", zap.String("q", query.Query), zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/snapshots", func(w http.ResponseWriter, req *http.Request) {
+		manifests, err := usecases.Snapshot.ListSnapshots(req.Context())
+		if err != nil {
+			logger.Error("List snapshots failed", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(manifests); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/snapshots/published/{day}", func(w http.ResponseWriter, req *http.Request) {
+		published, err := usecases.Snapshot.GetPublished(req.Context(), chi.URLParam(req, "day"))
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, domain.ErrNotFound) {
+				http.Error(w, "No snapshot published for that day", http.StatusNotFound)
+				return
+			}
+			logger.Error("Get published snapshot failed", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(published); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/snapshots/diff", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		snapshotID, diffs, err := usecases.Snapshot.GetDiffs(req.Context(), q.Get("snapshot"), q.Get("title"))
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Error("Get snapshot diffs failed", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := SnapshotDiffDTO{SnapshotID: snapshotID, Diffs: make([]DiffDTO, len(diffs))}
+		for i, d := range diffs {
+			resp.Diffs[i] = toDiffDTO(d)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
+	r.Get("/titles", func(w http.ResponseWriter, req *http.Request) {
+		titles, err := usecases.Titles.ListTitles()
+		if err != nil {
+			logger.Error("List titles failed", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(titles); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+	})
+
 	r.Get("/titles/{id}", func(w http.ResponseWriter, req *http.Request) {
 		titleID := chi.URLParam(req, "id")
-		// Dummy data for E2E testing
-		dummyTitle := `{
-			"id": "` + titleID + `",
-			"title": "Title ` + titleID + `",
-			"total_words": 50000,
-			"avg_rscs": 15.5,
-			"summary": "This is a summary for Title ` + titleID + `. It contains regulatory information about various topics."
-		}`
+		title, err := usecases.Titles.GetTitle(titleID)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, domain.ErrNotFound) {
+				http.Error(w, "Title not found", http.StatusNotFound)
+				return
+			}
+			logger.Error("Get title failed", zap.String("title", titleID), zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(dummyTitle))
+		if err := json.NewEncoder(w).Encode(title); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
 	})
 
 	r.Get("/sections/{id}", func(w http.ResponseWriter, req *http.Request) {
-		sectionID := chi.URLParam(req, "id")
-		// Dummy data for E2E testing
-		dummySection := `{
-			"id": "` + sectionID + `",
-			"section": "§ ` + sectionID + `",
-			"text": "This is the full text of section ` + sectionID + `. It contains detailed regulatory requirements and compliance information that would typically be much longer in a real application.",
-			"rscs_per_1k": 12.3,
-			"summary": "Summary of section ` + sectionID + ` discussing key regulatory requirements."
-		}`
+		// chi routes on the escaped path when a client escapes more than it must (":" as %3A)
+		ref, err := url.PathUnescape(chi.URLParam(req, "id"))
+		if err != nil {
+			http.Error(w, "Invalid section", http.StatusBadRequest)
+			return
+		}
+		section, err := usecases.Sections.GetSection(ref)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidData) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, domain.ErrNotFound) {
+				http.Error(w, "Section not found", http.StatusNotFound)
+				return
+			}
+			logger.Error("Get section failed", zap.String("section", ref), zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(dummySection))
+		if err := json.NewEncoder(w).Encode(section); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
 	})
 
 	r.Get("/summaries", func(w http.ResponseWriter, req *http.Request) {
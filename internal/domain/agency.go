@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+// AgencyDetail is an agency with its place in the hierarchy, CFR references, totals per title
+// and per part, latest LSA record and summary
+type AgencyDetail struct {
+	AgencyMetric
+	ShortName     string               `json:"short_name,omitempty"`
+	SortableName  string               `json:"sortable_name,omitempty"`
+	Parent        *AgencyRef           `json:"parent"`
+	Children      []AgencyMetric       `json:"children"` // Direct children, by total words
+	CFRReferences []AgencyCFRReference `json:"cfr_references"`
+	Titles        []AgencyTitleMetric  `json:"titles"` // By total RSCS
+	Parts         []AgencyPartMetric   `json:"parts"`  // By total RSCS
+	LSA           *AgencyLSA           `json:"lsa"`    // Latest record of the matched Federal Register agencies; nil when none
+	// Latest agency summary, empty when none has been generated
+	Summary          string     `json:"summary"`
+	SummaryCreatedAt *time.Time `json:"summary_created_at,omitempty"`
+}
+
+// AgencyCFRReference is one CFR reference from the agency registry; unused levels are empty
+type AgencyCFRReference struct {
+	Title      string `json:"title"`
+	Subtitle   string `json:"subtitle,omitempty"`
+	Chapter    string `json:"chapter,omitempty"`
+	Subchapter string `json:"subchapter,omitempty"`
+	Part       string `json:"part,omitempty"`
+}
+
+// AgencyTitleMetric is an agency's totals within one title
+type AgencyTitleMetric struct {
+	Title        string  `json:"title"`
+	TitleName    string  `json:"title_name,omitempty"`
+	TotalWords   int     `json:"total_words"`
+	TotalRSCS    int     `json:"total_rscs"`
+	AvgRSCS      float64 `json:"avg_rscs"` // Aggregated as the agency's RSCSAggregation
+	Restrictions int     `json:"restrictions"`
+	SectionCount int     `json:"section_count"`
+}
+
+// AgencyPartMetric is the totals of one part an agency owns
+type AgencyPartMetric struct {
+	Title string `json:"title"`
+	PartMetric
+}
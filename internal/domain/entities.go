@@ -3,8 +3,11 @@ package domain
 import "time"
 
 type Agency struct {
-	ID   string
-	Name string
+	ID           string
+	Name         string
+	ShortName    string
+	SortableName string
+	ParentID     *string
 }
 
 type AgencyMetric struct {
@@ -12,17 +15,79 @@ type AgencyMetric struct {
 	Name            string  `json:"name"`
 	ParentID        *string `json:"parent_id"`
 	TotalWords      int     `json:"total_words"`
-	AvgRSCS         float64 `json:"avg_rscs"`
+	AvgRSCS         float64 `json:"avg_rscs"`         // Aggregated as RSCSAggregation says
+	RSCSAggregation string  `json:"rscs_aggregation"` // One of the RSCSAggregation* methods
 	LSACounts       int     `json:"lsa_counts"`
 	ContentChecksum string  `json:"content_checksum,omitempty"`
 }
 
+// Ways of aggregating section RSCS per 1k words into an agency's avg_rscs
+const (
+	RSCSAggregationMean         = "mean"          // Every section counts the same
+	RSCSAggregationWeightedMean = "weighted_mean" // Sections weighted by word count
+	RSCSAggregationMedian       = "median"
+	RSCSAggregationP90          = "p90"
+	RSCSAggregationTotalPer1K   = "total_per_1k" // Total RSCS per 1,000 total words
+)
+
+// AgencyMetricsOptions selects how agency totals are computed.
+type AgencyMetricsOptions struct {
+	Rollup      bool   // Include each agency's descendants
+	Aggregation string // RSCSAggregation* method for AvgRSCS; empty means mean
+}
+
+// AgencyNode is an agency with its metrics and its child agencies, for hierarchy views
+type AgencyNode struct {
+	AgencyMetric
+	Children []*AgencyNode `json:"children"`
+}
+
+// Kinds of agency registry change recorded by the agency sync
+const (
+	AgencyAdded      = "added"
+	AgencyRenamed    = "renamed"
+	AgencyReparented = "reparented"
+	AgencyRemoved    = "removed"
+)
+
+// AgencyChange is one change an agency sync applied to the agencies table
+type AgencyChange struct {
+	AgencyID  string    `json:"agency_id"`
+	Kind      string    `json:"kind"`      // One of the Agency* change kinds
+	OldValue  string    `json:"old_value"` // Name for renames and removals, parent ID for re-parenting ('' at the top level)
+	NewValue  string    `json:"new_value"` // Name for additions and renames, parent ID for re-parenting
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// AgencyMetricSnapshot is a per-agency rollup of section metrics captured for one snapshot
+type AgencyMetricSnapshot struct {
+	AgencyID        string    `json:"agency_id"`
+	SnapshotID      string    `json:"snapshot_id"`
+	TotalWords      int       `json:"total_words"`
+	TotalRSCS       int       `json:"total_rscs"`
+	AvgRSCS         float64   `json:"avg_rscs"`
+	WeightedAvgRSCS float64   `json:"weighted_avg_rscs"`
+	MedianRSCS      float64   `json:"median_rscs"`
+	P90RSCS         float64   `json:"p90_rscs"`
+	TotalRSCSPer1K  float64   `json:"total_rscs_per_1k"`
+	Restrictions    int       `json:"restrictions"` // Sum of modal counts (shall, must, may not, must not)
+	SectionCount    int       `json:"section_count"`
+	ComputedAt      time.Time `json:"computed_at"`
+}
+
+// TimeSeriesPoint is a single value of a metric at a snapshot
+type TimeSeriesPoint struct {
+	SnapshotID string  `json:"snapshot_id"`
+	Value      float64 `json:"value"`
+}
+
 type Title struct {
 	Title           string
 	Name            string
-	LatestAmendedOn time.Time
+	LatestAmendedOn time.Time // Zero when the eCFR gives no date, as for reserved titles
 	LatestIssueDate time.Time
 	UpToDateAsOf    time.Time
+	Reserved        bool // Title number kept with no regulations in it
 }
 
 type Section struct {
@@ -30,18 +95,31 @@ type Section struct {
 	Title          string
 	Part           string
 	Section        string
-	AgencyID       string
+	Heading        string // The section's own heading, e.g. "§ 60.1 Applicability."
+	AgencyID       string // CFR chapter
+	Subtitle       string // Subtitle and subchapter the part sits in, if any; kept per part, in cfr_parts
+	Subchapter     string
 	Path           string
 	Text           string
 	RevDate        time.Time
-	ChecksumSHA256 string
+	ChecksumSHA256 string // SHA-256 of the normalized text, used to detect changes
+	TextSHA256     string // SHA-256 of Text as stored, which addresses it in the section text stores
 	WordCount      int
 	DefCount       int
 	XrefCount      int
 	ModalCount     int
 	RSCSRaw        int
 	RSCSPer1K      float64
-	SnapshotDate   string
+	SnapshotDate   string // UTC day of the snapshot (YYYY-MM-DD)
+	SnapshotID     string
+}
+
+// PartOwnerOverride assigns a CFR part to an agency, replacing the owners resolved from the
+// agencies' CFR references. Several overrides for one part make the agencies joint owners.
+type PartOwnerOverride struct {
+	Title    string `json:"title"`
+	Part     string `json:"part"`
+	AgencyID string `json:"agency_id"`
 }
 
 type RawSection struct {
@@ -75,19 +153,162 @@ type LSAActivity struct {
 
 // AgencyLSA tracks regulatory activity (proposed rules, final rules, notices) per agency
 type AgencyLSA struct {
-	AgencyID       string    // Agency slug/ID (e.g., "environmental-protection-agency")
-	AgencyName     string    // Human-readable agency name
-	ProposedRules  int       // Count of proposed rules
-	FinalRules     int       // Count of final rules
-	Notices        int       // Count of notices
-	TotalDocuments int       // Total document count
-	SnapshotDate   string    // Date of data collection (YYYY-MM-DD)
-	CapturedAt     time.Time // Timestamp when data was fetched
-	SourceHint     string    // Data source identifier (e.g., "federalregister-api")
+	AgencyID       string    `json:"fr_slug"`         // Federal Register agency slug (e.g., "environmental-protection-agency"); see FRAgencyMatch
+	AgencyName     string    `json:"fr_name"`         // Human-readable agency name
+	ProposedRules  int       `json:"proposed_rules"`  // Count of proposed rules
+	FinalRules     int       `json:"final_rules"`     // Count of final rules
+	Notices        int       `json:"notices"`         // Count of notices
+	TotalDocuments int       `json:"total_documents"` // Total document count
+	SnapshotDate   string    `json:"snapshot_date"`   // Date of data collection (YYYY-MM-DD)
+	CapturedAt     time.Time `json:"captured_at"`     // Timestamp when data was fetched
+	SourceHint     string    `json:"source_hint"`     // Data source identifier (e.g., "federalregister-api")
+}
+
+// How a Federal Register agency was matched to an eCFR agency
+const (
+	FRMatchOverride  = "override"  // Listed in the override file
+	FRMatchSlug      = "slug"      // Same slug
+	FRMatchName      = "name"      // Same normalized name, held by one eCFR agency
+	FRMatchParent    = "parent"    // Same normalized name, told apart by the parents' match
+	FRMatchUnmatched = "unmatched" // No eCFR agency found
+)
+
+// FRAgencyMatch records which eCFR agency a Federal Register agency is. AgencyID is empty when
+// no agency matched.
+type FRAgencyMatch struct {
+	FRSlug    string
+	FRName    string
+	AgencyID  string
+	MatchKind string // One of the FRMatch* kinds
+}
+
+// FRAgencyOverride pins a Federal Register agency to an eCFR agency. An empty AgencyID keeps it
+// unmatched.
+type FRAgencyOverride struct {
+	FRSlug   string `json:"fr_slug"`
+	AgencyID string `json:"agency_id"`
 }
 
+// Diff statuses
+const (
+	DiffAdded     = "added"
+	DiffModified  = "modified"
+	DiffRemoved   = "removed"
+	DiffUnchanged = "unchanged"
+)
+
 type Diff struct {
-	SectionID      string
-	DeltaWordCount int
-	Changed        bool
+	SectionID          string
+	Title              string
+	Part               string
+	AgencyID           string // CFR chapter, as in Section.AgencyID
+	PrevSnapshotID     string // Empty when there was no earlier snapshot to compare against
+	Status             string // added|modified|removed|unchanged
+	WordsBefore        int
+	WordsAfter         int
+	RestrictionsBefore int
+	RestrictionsAfter  int
+	DeltaWordCount     int
+	Changed            bool
+}
+
+// CFRReference is a title/part citation attached to a Federal Register document
+type CFRReference struct {
+	Title string `json:"title"`
+	Part  string `json:"part"`
+}
+
+// FRDocument is a Federal Register document considered as the source of a section change
+type FRDocument struct {
+	DocumentNumber  string
+	Type            string
+	Title           string
+	PublicationDate time.Time
+	HTMLURL         string
+	CFRReferences   []CFRReference
+}
+
+// DiffAttribution links a changed section to a Federal Register document that likely caused it
+type DiffAttribution struct {
+	SnapshotID      string    `json:"-"`
+	Title           string    `json:"-"`
+	SectionID       string    `json:"-"`
+	DocumentNumber  string    `json:"document_number"`
+	DocumentTitle   string    `json:"document_title"`
+	PublicationDate time.Time `json:"publication_date"`
+	HTMLURL         string    `json:"html_url"`
+	MatchKind       string    `json:"match_kind"` // How the document was matched, e.g. "cfr_part"
+}
+
+// AttributedDiff is a diff together with the documents it was attributed to
+type AttributedDiff struct {
+	Diff
+	Attributions []DiffAttribution
+}
+
+// ScoreboardEntry reports how much regulatory text an agency added and removed over a window
+type ScoreboardEntry struct {
+	Rank                int    `json:"rank"`
+	AgencyID            string `json:"agency_id"`
+	AgencyName          string `json:"agency_name"`
+	WordsRemoved        int    `json:"words_removed"`
+	WordsAdded          int    `json:"words_added"`
+	NetWords            int    `json:"net_words"`
+	RestrictionsRemoved int    `json:"restrictions_removed"`
+	RestrictionsAdded   int    `json:"restrictions_added"`
+	NetRestrictions     int    `json:"net_restrictions"`
+	SectionsRemoved     int    `json:"sections_removed"`
+}
+
+// Snapshot manifest statuses. A snapshot is only readable once its manifest is complete.
+const (
+	SnapshotComplete   = "complete"
+	SnapshotIncomplete = "incomplete"
+)
+
+// Per-title outcomes recorded in a snapshot manifest
+const (
+	TitleSucceeded = "succeeded"
+	TitleFailed    = "failed"
+	TitleSkipped   = "skipped"
+)
+
+// ManifestFile describes one file written into a snapshot
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Rows   int    `json:"rows"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestTitle records how one title fared in an ETL run
+type ManifestTitle struct {
+	Title           string         `json:"title"`
+	Status          string         `json:"status"` // succeeded|failed|skipped
+	Rows            int            `json:"rows"`
+	SourceXMLSHA256 string         `json:"source_xml_sha256,omitempty"`
+	Files           []ManifestFile `json:"files,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// RawXMLFetch records one download of a title's XML and the archived document it resolved to
+type RawXMLFetch struct {
+	Title        int       `json:"title"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	SHA256       string    `json:"sha256"` // Of the uncompressed XML; addresses the archived copy
+	Bytes        int64     `json:"bytes"`
+	SourceURL    string    `json:"source_url,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"` // As sent by GovInfo, for conditional requests
+}
+
+// SnapshotManifest is written as manifest.json into every snapshot by the ETL
+type SnapshotManifest struct {
+	SnapshotID     string          `json:"snapshot_id"`
+	Status         string          `json:"status"`
+	ScoringVersion string          `json:"scoring_version"`
+	StartedAt      time.Time       `json:"started_at"`
+	CompletedAt    time.Time       `json:"completed_at,omitempty"`
+	DurationMS     int64           `json:"duration_ms"`
+	Titles         []ManifestTitle `json:"titles"`
+	Files          []ManifestFile  `json:"files,omitempty"` // Snapshot-wide files such as agency_lsa.parquet
 }
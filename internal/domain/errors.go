@@ -7,4 +7,8 @@ var (
 	ErrInvalidData = errors.New("invalid data")
 	ErrAPI         = errors.New("API error")
 	ErrPersistence = errors.New("persistence error")
+	ErrUnsupported = errors.New("not supported by this store")
+
+	ErrIncompleteSnapshot = errors.New("incomplete snapshot")
+	ErrChecksumMismatch   = errors.New("checksum mismatch")
 )
@@ -0,0 +1,43 @@
+package domain
+
+// SearchQuery is a full-text search over the current text of every section. Query holds bare
+// terms, all of which must match, and "quoted phrases", which must match as written.
+type SearchQuery struct {
+	Query    string
+	AgencyID string // Agency slug; empty for all agencies
+	Title    string // CFR title number; empty for all titles
+	Limit    int
+	Offset   int
+}
+
+// SearchHit is one matching section. Heading and Snippet are HTML-escaped, with the matched
+// terms wrapped in <mark> elements.
+type SearchHit struct {
+	SectionKey string  `json:"section_key"`
+	Title      string  `json:"title"`
+	Part       string  `json:"part"`
+	Section    string  `json:"section"`
+	AgencyID   string  `json:"agency_id"` // CFR chapter, as in Section.AgencyID
+	Heading    string  `json:"heading"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"` // Higher is more relevant
+}
+
+// FacetCount is how many matching sections share one value of a facet.
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+// SearchResults is one page of hits, ranked most relevant first, with facet counts over every
+// match. Each facet ignores its own filter, so the counts show where else the query matches.
+type SearchResults struct {
+	Query    string       `json:"query"`
+	Total    int          `json:"total"`
+	Limit    int          `json:"limit"`
+	Offset   int          `json:"offset"`
+	Hits     []SearchHit  `json:"hits"`
+	Agencies []FacetCount `json:"agencies"`
+	Titles   []FacetCount `json:"titles"`
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SectionKey returns the canonical identity of a section across snapshots: its title and
+// section number, e.g. "40:60.1". Section numbers repeat across titles, so the number alone
+// is not unique. A leading "§" and surrounding whitespace are dropped.
+func SectionKey(title, section string) string {
+	return title + ":" + strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(section), "§"))
+}
+
+// citationPattern matches "40 CFR 60.5", "40 C.F.R. § 60.5" and the like
+var citationPattern = regexp.MustCompile(`^(?i)(\d+)\s*C\.?\s*F\.?\s*R\.?\s*(?:§+\s*)?(\S+)$`)
+
+// ParseSectionRef reads a reference to a section: a citation such as "40 CFR 60.5", a section
+// key such as "40:60.5", or a bare section number such as "§ 60.5" or "60.5", for which title is
+// "". The section number is returned without "§". A title outside 1-50 or an empty section
+// number is ErrInvalidData.
+func ParseSectionRef(ref string) (title, section string, err error) {
+	ref = strings.TrimSpace(ref)
+	if m := citationPattern.FindStringSubmatch(ref); m != nil {
+		title, section = m[1], m[2]
+	} else if t, s, ok := strings.Cut(ref, ":"); ok {
+		title, section = strings.TrimSpace(t), s
+	} else {
+		section = ref
+	}
+	section = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(section), "§"))
+	if section == "" {
+		return "", "", fmt.Errorf("%w: section %q", ErrInvalidData, ref)
+	}
+	if title != "" {
+		n, err := strconv.Atoi(title)
+		if err != nil || n < 1 || n > 50 {
+			return "", "", fmt.Errorf("%w: title %q must be a CFR title number", ErrInvalidData, title)
+		}
+		title = strconv.Itoa(n)
+	}
+	return title, section, nil
+}
+
+// SectionDetail is a current section with its place in the CFR, owners, history and summary
+type SectionDetail struct {
+	Key            string           `json:"key"`      // Section key, e.g. "40:60.5"
+	ID             string           `json:"id"`       // Section ID as parsed, e.g. "§ 60.5"
+	Citation       string           `json:"citation"` // e.g. "40 CFR 60.5"
+	Title          string           `json:"title"`    // Title number
+	TitleName      string           `json:"title_name,omitempty"`
+	Subtitle       string           `json:"subtitle,omitempty"`
+	Chapter        string           `json:"chapter,omitempty"`
+	Subchapter     string           `json:"subchapter,omitempty"`
+	Part           string           `json:"part"`
+	Section        string           `json:"section"`
+	Heading        string           `json:"heading"`
+	Breadcrumb     []HierarchyLevel `json:"breadcrumb"` // Title down to the section, skipping levels the section is not under
+	Text           string           `json:"text"`
+	RevDate        string           `json:"rev_date,omitempty"` // YYYY-MM-DD
+	SnapshotID     string           `json:"snapshot_id"`
+	ChecksumSHA256 string           `json:"checksum_sha256"`
+	WordCount      int              `json:"word_count"`
+	DefCount       int              `json:"def_count"`
+	XrefCount      int              `json:"xref_count"`
+	ModalCount     int              `json:"modal_count"`
+	RSCSRaw        int              `json:"rscs_raw"`
+	RSCSPer1K      float64          `json:"rscs_per_1k"`
+	Agencies       []AgencyRef      `json:"agencies"` // Owners of the section's part; several when owned jointly
+	Changes        []SectionChange  `json:"changes"`  // Oldest first
+	// Latest section summary, empty when none has been generated
+	Summary          string     `json:"summary"`
+	SummaryCreatedAt *time.Time `json:"summary_created_at,omitempty"`
+}
+
+// HierarchyLevel is one step of a section's breadcrumb
+type HierarchyLevel struct {
+	Level string `json:"level"` // title|subtitle|chapter|subchapter|part|section
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+}
+
+// AgencyRef names an agency
+type AgencyRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SectionChange is one snapshot in which a section was added, modified or removed
+type SectionChange struct {
+	SnapshotID         string `json:"snapshot_id"`
+	PrevSnapshotID     string `json:"prev_snapshot_id"`
+	Status             string `json:"status"` // added|modified|removed
+	WordsBefore        int    `json:"words_before"`
+	WordsAfter         int    `json:"words_after"`
+	RestrictionsBefore int    `json:"restrictions_before"`
+	RestrictionsAfter  int    `json:"restrictions_after"`
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Snapshot ID layouts. New snapshots are identified by the UTC time the ETL run started, so
+// several runs on the same day get distinct, sortable IDs. Snapshots written before that were
+// named by their local date only; those legacy IDs still parse and sort before any run of the
+// same day.
+const (
+	SnapshotIDLayout  = "2006-01-02T150405Z"
+	SnapshotDayLayout = "2006-01-02"
+)
+
+// NewSnapshotID returns the snapshot ID for a run started at t.
+func NewSnapshotID(t time.Time) string {
+	return t.UTC().Format(SnapshotIDLayout)
+}
+
+// ParseSnapshotID returns the UTC start time encoded in a snapshot ID. Legacy date-only IDs
+// parse to midnight of that day.
+func ParseSnapshotID(id string) (time.Time, error) {
+	if t, err := time.Parse(SnapshotIDLayout, id); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(SnapshotDayLayout, id); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: snapshot id %q", ErrInvalidData, id)
+}
+
+// SnapshotDay returns the YYYY-MM-DD day a snapshot belongs to.
+func SnapshotDay(id string) (string, error) {
+	t, err := ParseSnapshotID(id)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(SnapshotDayLayout), nil
+}
+
+// PublishedSnapshot points a day at the snapshot readers should use for it. Reruns on the same
+// day write new snapshots and move the pointer; earlier runs stay on disk for auditing.
+type PublishedSnapshot struct {
+	Day         string    `json:"day"`
+	SnapshotID  string    `json:"snapshot_id"`
+	PublishedAt time.Time `json:"published_at"`
+}
@@ -0,0 +1,12 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// TextHash returns the hex SHA-256 of text, the key section text is stored under.
+func TextHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+// TitleMetric is a CFR title's eCFR metadata with the totals of its current sections. Titles
+// loaded before their metadata was synced have an empty name and dates.
+type TitleMetric struct {
+	ID              string  `json:"id"`                          // Title number, e.g. "40"
+	Title           string  `json:"title"`                       // Title name, e.g. "Protection of Environment"
+	LatestAmendedOn string  `json:"latest_amended_on,omitempty"` // YYYY-MM-DD
+	LatestIssueDate string  `json:"latest_issue_date,omitempty"` // YYYY-MM-DD
+	UpToDateAsOf    string  `json:"up_to_date_as_of,omitempty"`  // YYYY-MM-DD
+	Reserved        bool    `json:"reserved"`
+	SnapshotID      string  `json:"snapshot_id,omitempty"` // Snapshot the totals come from; empty before the title is loaded
+	TotalWords      int     `json:"total_words"`
+	TotalRSCS       int     `json:"total_rscs"`
+	AvgRSCS         float64 `json:"avg_rscs"`     // Unweighted mean of the sections' RSCS per 1k words
+	Restrictions    int     `json:"restrictions"` // Sum of modal counts
+	SectionCount    int     `json:"section_count"`
+}
+
+// PartMetric is the totals of one CFR part's current sections
+type PartMetric struct {
+	Part         string  `json:"part"`
+	TotalWords   int     `json:"total_words"`
+	TotalRSCS    int     `json:"total_rscs"`
+	AvgRSCS      float64 `json:"avg_rscs"`
+	SectionCount int     `json:"section_count"`
+}
+
+// TitleAgency is an agency owning parts of a title, with the words in those parts
+type TitleAgency struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Parts      int    `json:"parts"`
+	TotalWords int    `json:"total_words"`
+}
+
+// TitleDetail is a title with its heaviest parts, owning agencies, latest LSA counts and summary
+type TitleDetail struct {
+	TitleMetric
+	TopParts   []PartMetric  `json:"top_parts"` // By total RSCS, at most TopPartsLimit
+	Agencies   []TitleAgency `json:"agencies"`  // By total words
+	Proposals  int           `json:"proposals"`
+	Amendments int           `json:"amendments"`
+	Finals     int           `json:"finals"`
+	// Latest title summary, empty when none has been generated
+	Summary          string     `json:"summary"`
+	SummaryCreatedAt *time.Time `json:"summary_created_at,omitempty"`
+}
+
+// TopPartsLimit is how many parts TitleDetail lists
+const TopPartsLimit = 10
+
+// FormatDay writes a title date as stored, YYYY-MM-DD, or "" for the zero time
+func FormatDay(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(SnapshotDayLayout)
+}
@@ -13,13 +13,39 @@ type Config struct {
 	GCSBucket       string
 	DuckDBUI        bool
 
+	// PostgreSQL connection string. When set, the relational store is PostgreSQL instead of
+	// SQLite at DataDir/ecfr.db, so API replicas can share it.
+	DatabaseURL string
+
+	// Local copy of the eCFR agency hierarchy to sync agencies from. When empty, the ETL fetches
+	// it from the eCFR admin API.
+	AgenciesFile string
+
 	// New GCS config
 	ParquetBucket string
 	ParquetPrefix string
 	RawXMLBucket  string
 	RawXMLPrefix  string
+
+	// Object storage for Parquet snapshots and raw XML: local, gcs or s3.
+	// Defaults to local for ENV=local/dev and gcs otherwise.
+	StorageBackend string
+
+	// S3-compatible storage (AWS S3, MinIO, R2), used when StorageBackend is s3
+	S3Endpoint        string
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3UseSSL          bool
 }
 
+// Storage backends accepted by STORAGE_BACKEND
+const (
+	StorageLocal = "local"
+	StorageGCS   = "gcs"
+	StorageS3    = "s3"
+)
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok && value != "" {
 		return value
@@ -28,18 +54,34 @@ func getEnv(key, fallback string) string {
 }
 
 func LoadConfig() Config {
+	env := getEnv("ENV", "local")
+	defaultBackend := StorageGCS
+	if env == "local" || env == "dev" {
+		defaultBackend = StorageLocal
+	}
+
 	return Config{
-		Env:             getEnv("ENV", "local"),
+		Env:             env,
 		DataDir:         getEnv("DATA_DIR", "data"),
 		VertexProjectID: os.Getenv("VERTEX_PROJECT_ID"),
 		VertexLocation:  getEnv("VERTEX_LOCATION", "us-central1"),
 		VertexModelID:   getEnv("VERTEX_MODEL_ID", "gemini-2.5-pro"),
 		GCSBucket:       getEnv("GCS_BUCKET", "ecfr-dereg-dash-batch"),
 		DuckDBUI:        os.Getenv("DUCKDB_UI") == "1",
+		DatabaseURL:     os.Getenv("DATABASE_URL"),
+		AgenciesFile:    os.Getenv("AGENCIES_FILE"),
 
 		ParquetBucket: getEnv("PARQUET_BUCKET_NAME", "ecfr-parquet"),
 		ParquetPrefix: getEnv("PARQUET_PREFIX", "parquet"),
 		RawXMLBucket:  getEnv("RAW_XML_BUCKET_NAME", "ecfr-raw-xml"),
 		RawXMLPrefix:  getEnv("RAW_XML_PREFIX", "raw"),
+
+		StorageBackend: getEnv("STORAGE_BACKEND", defaultBackend),
+
+		S3Endpoint:        getEnv("S3_ENDPOINT", "s3.amazonaws.com"),
+		S3Region:          os.Getenv("S3_REGION"),
+		S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3UseSSL:          getEnv("S3_USE_SSL", "true") != "false",
 	}
 }
@@ -0,0 +1,45 @@
+package usecase
+
+import "github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+
+// Agencies serves single agencies with everything known about them.
+type Agencies struct {
+	store agencyDetailStore
+}
+
+type agencyDetailStore interface {
+	AgencyStore
+	LSAStore
+	SummaryStore
+}
+
+func NewAgencies(store agencyDetailStore) *Agencies {
+	return &Agencies{store: store}
+}
+
+// GetAgency returns an agency's detail with its latest LSA record, content checksum and latest
+// summary. The checksum is computed when the ETL has not stored one. An unknown agency is
+// domain.ErrNotFound and an unknown opts.Aggregation domain.ErrInvalidData.
+func (u *Agencies) GetAgency(agencyID string, opts domain.AgencyMetricsOptions) (*domain.AgencyDetail, error) {
+	detail, err := u.store.GetAgencyDetail(agencyID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if detail.LSA, err = u.store.GetAgencyLSA(agencyID); err != nil {
+		return nil, err
+	}
+	if detail.ContentChecksum == "" {
+		if detail.ContentChecksum, err = u.store.GetAgencyChecksum(agencyID); err != nil {
+			return nil, err
+		}
+	}
+	summary, err := u.store.GetSummaryByKey("agency", agencyID)
+	if err != nil {
+		return nil, err
+	}
+	if summary != nil {
+		detail.Summary = summary.Text
+		detail.SummaryCreatedAt = &summary.CreatedAt
+	}
+	return detail, nil
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+type fakeAgencyDetailStore struct {
+	agencyDetailStore
+	stored   string // Checksum the ETL stored
+	computed int    // Checksums computed on demand
+}
+
+func (f *fakeAgencyDetailStore) GetAgencyDetail(agencyID string, opts domain.AgencyMetricsOptions) (*domain.AgencyDetail, error) {
+	return &domain.AgencyDetail{AgencyMetric: domain.AgencyMetric{ID: agencyID, ContentChecksum: f.stored}}, nil
+}
+
+func (f *fakeAgencyDetailStore) GetAgencyLSA(agencyID string) (*domain.AgencyLSA, error) {
+	return &domain.AgencyLSA{AgencyID: "environmental-protection-agency", TotalDocuments: 5}, nil
+}
+
+func (f *fakeAgencyDetailStore) GetAgencyChecksum(agencyID string) (string, error) {
+	f.computed++
+	return "computed", nil
+}
+
+func (f *fakeAgencyDetailStore) GetSummaryByKey(kind, key string) (*domain.Summary, error) {
+	if kind == "agency" && key == "epa" {
+		return &domain.Summary{Text: "Environmental rules."}, nil
+	}
+	return nil, nil
+}
+
+func TestGetAgency(t *testing.T) {
+	store := &fakeAgencyDetailStore{stored: "stored"}
+	u := NewAgencies(store)
+
+	d, err := u.GetAgency("epa", domain.AgencyMetricsOptions{})
+	if err != nil {
+		t.Fatalf("GetAgency failed: %v", err)
+	}
+	if d.ContentChecksum != "stored" || store.computed != 0 || d.LSA == nil || d.LSA.TotalDocuments != 5 || d.Summary != "Environmental rules." {
+		t.Errorf("GetAgency(epa) = %+v", d)
+	}
+
+	store.stored = ""
+	d, err = u.GetAgency("doi", domain.AgencyMetricsOptions{})
+	if err != nil || d.ContentChecksum != "computed" || store.computed != 1 || d.Summary != "" || d.SummaryCreatedAt != nil {
+		t.Errorf("GetAgency(doi) = %+v, %v; want a computed checksum and no summary", d, err)
+	}
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/lsa"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+	"go.uber.org/zap"
+)
+
+// MatchCFRPart marks an attribution made because the document cites the section's title and part
+const MatchCFRPart = "cfr_part"
+
+// Attribution links changed sections to the Federal Register final rules that likely caused them.
+type Attribution struct {
+	logger    *zap.Logger
+	collector *lsa.Collector
+	store     DiffStore
+}
+
+func NewAttribution(logger *zap.Logger, collector *lsa.Collector, store DiffStore) *Attribution {
+	return &Attribution{logger: logger, collector: collector, store: store}
+}
+
+// AttributeDiffs fetches the final rules published between each diff's previous snapshot and
+// snapshotID, links them to the changed sections they cite, and stores the links.
+// Diffs without a previous snapshot are not attributed.
+func (u *Attribution) AttributeDiffs(ctx context.Context, snapshotID string, diffs []domain.Diff) ([]domain.DiffAttribution, error) {
+	end, err := domain.ParseSnapshotID(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	byPrev := make(map[string][]domain.Diff)
+	for _, d := range diffs {
+		if !d.Changed || d.PrevSnapshotID == "" {
+			continue
+		}
+		byPrev[d.PrevSnapshotID] = append(byPrev[d.PrevSnapshotID], d)
+	}
+
+	var all []domain.DiffAttribution
+	for prev, group := range byPrev {
+		start, err := domain.ParseSnapshotID(prev)
+		if err != nil {
+			return nil, err
+		}
+		docs, err := u.collector.FetchFinalRules(ctx, start, end)
+		if err != nil {
+			return nil, err
+		}
+		u.logger.Info("Fetched final rules for attribution",
+			zap.String("from", prev), zap.String("to", snapshotID), zap.Int("documents", len(docs)))
+		all = append(all, linkDiffs(snapshotID, group, docs)...)
+	}
+
+	if len(all) == 0 {
+		return nil, nil
+	}
+	if err := u.store.InsertDiffAttributions(all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// linkDiffs attaches to every changed section the documents citing its title and part,
+// newest publication first.
+func linkDiffs(snapshotID string, diffs []domain.Diff, docs []domain.FRDocument) []domain.DiffAttribution {
+	byPart := make(map[string][]domain.FRDocument)
+	for _, doc := range docs {
+		seen := make(map[string]bool)
+		for _, ref := range doc.CFRReferences {
+			key := partKey(ref.Title, ref.Part)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			byPart[key] = append(byPart[key], doc)
+		}
+	}
+	for _, docs := range byPart {
+		sort.SliceStable(docs, func(i, j int) bool {
+			return docs[i].PublicationDate.After(docs[j].PublicationDate)
+		})
+	}
+
+	var out []domain.DiffAttribution
+	for _, d := range diffs {
+		if !d.Changed || d.Part == "" {
+			continue
+		}
+		for _, doc := range byPart[partKey(d.Title, d.Part)] {
+			out = append(out, domain.DiffAttribution{
+				SnapshotID:      snapshotID,
+				Title:           d.Title,
+				SectionID:       d.SectionID,
+				DocumentNumber:  doc.DocumentNumber,
+				DocumentTitle:   doc.Title,
+				PublicationDate: doc.PublicationDate,
+				HTMLURL:         doc.HTMLURL,
+				MatchKind:       MatchCFRPart,
+			})
+		}
+	}
+	return out
+}
+
+func partKey(title, part string) string {
+	return strings.TrimSpace(title) + "/" + strings.ToUpper(strings.TrimSpace(part))
+}
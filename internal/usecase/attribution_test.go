@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+func TestLinkDiffs(t *testing.T) {
+	older := domain.FRDocument{
+		DocumentNumber:  "2025-00001",
+		PublicationDate: time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC),
+		CFRReferences:   []domain.CFRReference{{Title: "40", Part: "60"}, {Title: "40", Part: "60"}},
+	}
+	newer := domain.FRDocument{
+		DocumentNumber:  "2025-00002",
+		PublicationDate: time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC),
+		CFRReferences:   []domain.CFRReference{{Title: "40", Part: "60"}, {Title: "7", Part: "60"}},
+	}
+	diffs := []domain.Diff{
+		{SectionID: "§ 60.1", Title: "40", Part: "60", Status: domain.DiffModified, Changed: true},
+		{SectionID: "§ 60.2", Title: "40", Part: "60", Status: domain.DiffUnchanged},
+		{SectionID: "§ 61.1", Title: "40", Part: "61", Status: domain.DiffRemoved, Changed: true},
+		{SectionID: "§ 60.9", Title: "7", Part: "60", Status: domain.DiffRemoved, Changed: true},
+	}
+
+	got := linkDiffs("2025-02-01", diffs, []domain.FRDocument{older, newer})
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 attributions, got %d: %+v", len(got), got)
+	}
+	if got[0].SectionID != "§ 60.1" || got[0].DocumentNumber != "2025-00002" {
+		t.Errorf("expected newest document first for § 60.1, got %+v", got[0])
+	}
+	if got[1].SectionID != "§ 60.1" || got[1].DocumentNumber != "2025-00001" {
+		t.Errorf("expected duplicate references to attach once, got %+v", got[1])
+	}
+	if got[2].SectionID != "§ 60.9" || got[2].Title != "7" {
+		t.Errorf("expected title 7 part 60 match, got %+v", got[2])
+	}
+	for _, a := range got {
+		if a.SnapshotID != "2025-02-01" || a.MatchKind != MatchCFRPart {
+			t.Errorf("unexpected attribution metadata: %+v", a)
+		}
+	}
+}
@@ -13,20 +13,23 @@ import (
 
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/govinfo"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/parquet"
-	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 	"go.uber.org/zap"
 )
 
+// ScoringVersion identifies the text normalization and RSCS formula used by IngestTitle.
+// Bump it whenever either changes so snapshots scored differently can be told apart.
+const ScoringVersion = "rscs-v1"
+
 type Ingest struct {
 	logger      *zap.Logger
 	govinfo     *govinfo.Client
 	parquetRepo *parquet.Repo
-	sqliteRepo  *sqlite.Repo
+	store       SectionStore
 }
 
-func NewIngest(logger *zap.Logger, govinfo *govinfo.Client, parquet *parquet.Repo, sqlite *sqlite.Repo) *Ingest {
-	return &Ingest{logger: logger, govinfo: govinfo, parquetRepo: parquet, sqliteRepo: sqlite}
+func NewIngest(logger *zap.Logger, govinfo *govinfo.Client, parquet *parquet.Repo, store SectionStore) *Ingest {
+	return &Ingest{logger: logger, govinfo: govinfo, parquetRepo: parquet, store: store}
 }
 
 func (u *Ingest) FetchChangedTitles(ctx context.Context) ([]domain.Title, error) {
@@ -42,7 +45,13 @@ func (u *Ingest) FetchChangedTitles(ctx context.Context) ([]domain.Title, error)
 	return titles, nil
 }
 
-func (u *Ingest) IngestTitle(ctx context.Context, title domain.Title) ([]domain.Section, error) {
+// IngestTitle downloads, parses and scores a title, stamping every section with snapshotID.
+func (u *Ingest) IngestTitle(ctx context.Context, snapshotID string, title domain.Title) ([]domain.Section, error) {
+	snapshotDay, err := domain.SnapshotDay(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
 	u.logger.Info("Starting ingestion for title", zap.String("title", title.Title))
 	start := time.Now()
 
@@ -87,9 +96,6 @@ func (u *Ingest) IngestTitle(ctx context.Context, title domain.Title) ([]domain.
 	sem := make(chan struct{}, numWorkers)
 	var wg sync.WaitGroup
 
-	// Snapshot date is constant for the batch
-	snapshotDate := time.Now().Format("2006-01-02")
-
 	for i, raw := range rawSections {
 		wg.Add(1)
 		sem <- struct{}{} // Acquire token
@@ -118,18 +124,23 @@ func (u *Ingest) IngestTitle(ctx context.Context, title domain.Title) ([]domain.
 				Title:          title.Title,
 				Part:           raw.Part,
 				Section:        raw.Section,
+				Heading:        raw.Heading,
 				AgencyID:       raw.AgencyID,
+				Subtitle:       raw.Subtitle,
+				Subchapter:     raw.Subchapter,
 				Path:           raw.Path,
 				Text:           raw.Text,
 				RevDate:        raw.RevDate,
 				ChecksumSHA256: hex.EncodeToString(checksum[:]),
+				TextSHA256:     domain.TextHash(raw.Text),
 				WordCount:      wordCount,
 				DefCount:       defCount,
 				XrefCount:      xrefCount,
 				ModalCount:     modalCount,
 				RSCSRaw:        rscsRaw,
 				RSCSPer1K:      rscsPer1K,
-				SnapshotDate:   snapshotDate,
+				SnapshotDate:   snapshotDay,
+				SnapshotID:     snapshotID,
 			}
 		}(i, raw)
 	}
@@ -144,6 +155,15 @@ func (u *Ingest) IngestTitle(ctx context.Context, title domain.Title) ([]domain.
 	return sections, nil
 }
 
+// SourceChecksum returns the SHA-256 of the stored XML a title was parsed from
+func (u *Ingest) SourceChecksum(ctx context.Context, title domain.Title) (string, error) {
+	titleNum, err := strconv.Atoi(title.Title)
+	if err != nil {
+		return "", err
+	}
+	return u.govinfo.ChecksumTitleXML(ctx, titleNum)
+}
+
 func normalizeText(text string) string {
 	text = strings.ToLower(text)
 	text = regexp.MustCompile(`\p{P}`).ReplaceAllString(text, " ")
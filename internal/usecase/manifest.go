@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// ManifestBuilder accumulates the outcome of an ETL run into a snapshot manifest.
+// It is safe for concurrent use by the per-title workers.
+type ManifestBuilder struct {
+	mu       sync.Mutex
+	manifest domain.SnapshotManifest
+	titles   map[string]*domain.ManifestTitle
+}
+
+func NewManifestBuilder(snapshotID string, startedAt time.Time) *ManifestBuilder {
+	return &ManifestBuilder{
+		manifest: domain.SnapshotManifest{
+			SnapshotID:     snapshotID,
+			Status:         domain.SnapshotIncomplete,
+			ScoringVersion: ScoringVersion,
+			StartedAt:      startedAt,
+		},
+		titles: make(map[string]*domain.ManifestTitle),
+	}
+}
+
+func (b *ManifestBuilder) title(title string) *domain.ManifestTitle {
+	t, ok := b.titles[title]
+	if !ok {
+		t = &domain.ManifestTitle{Title: title}
+		b.titles[title] = t
+	}
+	return t
+}
+
+// TitleSucceeded records the rows and source XML hash of a successfully ingested title
+func (b *ManifestBuilder) TitleSucceeded(title string, rows int, sourceSHA256 string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.title(title)
+	t.Status = domain.TitleSucceeded
+	t.Rows = rows
+	t.SourceXMLSHA256 = sourceSHA256
+}
+
+// TitleFailed records a title that could not be ingested; status is failed or skipped
+func (b *ManifestBuilder) TitleFailed(title, status string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.title(title)
+	t.Status = status
+	if err != nil {
+		t.Error = err.Error()
+	}
+}
+
+// AddTitleFile attaches a written file to a title
+func (b *ManifestBuilder) AddTitleFile(title string, f domain.ManifestFile) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.title(title)
+	t.Files = append(t.Files, f)
+}
+
+// AddFile attaches a snapshot-wide file
+func (b *ManifestBuilder) AddFile(f domain.ManifestFile) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.manifest.Files = append(b.manifest.Files, f)
+}
+
+// FailedTitles returns the titles recorded as failed, in numeric order. Skipped titles, which
+// GovInfo does not publish, are not failures.
+func (b *ManifestBuilder) FailedTitles() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var failed []string
+	for _, t := range b.titles {
+		if t.Status == domain.TitleFailed {
+			failed = append(failed, t.Title)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return titleLess(failed[i], failed[j]) })
+	return failed
+}
+
+// Status is the status the run's final manifest is written with: complete, or incomplete when
+// any title failed so that readers and later runs' diffs never see a partial snapshot.
+func (b *ManifestBuilder) Status() string {
+	if len(b.FailedTitles()) > 0 {
+		return domain.SnapshotIncomplete
+	}
+	return domain.SnapshotComplete
+}
+
+// Build returns the manifest with the given status. Titles are ordered numerically.
+func (b *ManifestBuilder) Build(status string, completedAt time.Time) domain.SnapshotManifest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m := b.manifest
+	m.Status = status
+	m.Titles = make([]domain.ManifestTitle, 0, len(b.titles))
+	for _, t := range b.titles {
+		m.Titles = append(m.Titles, *t)
+	}
+	sort.Slice(m.Titles, func(i, j int) bool { return titleLess(m.Titles[i].Title, m.Titles[j].Title) })
+	m.Files = append([]domain.ManifestFile(nil), b.manifest.Files...)
+	if status == domain.SnapshotComplete {
+		m.CompletedAt = completedAt
+		m.DurationMS = completedAt.Sub(m.StartedAt).Milliseconds()
+	}
+	return m
+}
+
+// titleLess orders title numbers numerically, falling back to string order for anything else
+func titleLess(a, b string) bool {
+	x, errX := strconv.Atoi(a)
+	y, errY := strconv.Atoi(b)
+	if errX != nil || errY != nil {
+		return a < b
+	}
+	return x < y
+}
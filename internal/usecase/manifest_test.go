@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+func TestManifestStatus(t *testing.T) {
+	started := time.Date(2025, 11, 19, 6, 0, 0, 0, time.UTC)
+	b := NewManifestBuilder(domain.NewSnapshotID(started), started)
+	b.TitleSucceeded("40", 10, "abc")
+	b.TitleFailed("35", domain.TitleSkipped, domain.ErrNotFound)
+	if got := b.Status(); got != domain.SnapshotComplete {
+		t.Fatalf("Status with a skipped title = %q, want complete", got)
+	}
+
+	b.TitleFailed("12", domain.TitleFailed, errors.New("boom"))
+	b.TitleFailed("7", domain.TitleFailed, errors.New("boom"))
+	if got := b.Status(); got != domain.SnapshotIncomplete {
+		t.Fatalf("Status with failed titles = %q, want incomplete", got)
+	}
+	if got := b.FailedTitles(); !reflect.DeepEqual(got, []string{"7", "12"}) {
+		t.Errorf("FailedTitles = %v, want [7 12]", got)
+	}
+
+	m := b.Build(b.Status(), started.Add(time.Hour))
+	if m.Status != domain.SnapshotIncomplete || !m.CompletedAt.IsZero() {
+		t.Errorf("manifest status = %q, completed_at = %v; want incomplete and unset", m.Status, m.CompletedAt)
+	}
+	var titles []string
+	for _, title := range m.Titles {
+		titles = append(titles, title.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"7", "12", "35", "40"}) {
+		t.Errorf("manifest titles = %v, want numeric order", titles)
+	}
+}
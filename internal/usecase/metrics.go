@@ -1,25 +1,145 @@
 package usecase
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/duck"
-	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
+// Time series metrics served by GetAgencyTimeSeries
+const (
+	MetricWords           = "words"
+	MetricRSCS            = "rscs"
+	MetricAvgRSCS         = "avg_rscs"
+	MetricWeightedAvgRSCS = "weighted_avg_rscs"
+	MetricMedianRSCS      = "median_rscs"
+	MetricP90RSCS         = "p90_rscs"
+	MetricTotalRSCSPer1K  = "total_rscs_per_1k"
+	MetricRestrictions    = "restrictions"
+	MetricSections        = "sections"
+)
+
 type Metrics struct {
-	duck   *duck.Helper
-	sqlite *sqlite.Repo
+	duck  *duck.Helper
+	store AgencyStore
+}
+
+func NewMetrics(duck *duck.Helper, store AgencyStore) *Metrics {
+	return &Metrics{duck: duck, store: store}
+}
+
+// GetAgencyTotals lists every agency's totals, optionally for one title. With opts.Rollup,
+// parents' totals include all of their descendants. An unknown opts.Aggregation is
+// domain.ErrInvalidData.
+func (u *Metrics) GetAgencyTotals(titleFilter *string, opts domain.AgencyMetricsOptions) ([]domain.AgencyMetric, error) {
+	return u.store.GetAgencyTotals(titleFilter, opts)
 }
 
-func NewMetrics(duck *duck.Helper, sqlite *sqlite.Repo) *Metrics {
-	return &Metrics{duck: duck, sqlite: sqlite}
+// GetAgencyTree returns an agency with all of its descendants nested under it, each with its
+// agency-wide totals. With opts.Rollup, each node's totals include its own descendants.
+func (u *Metrics) GetAgencyTree(agencyID string, opts domain.AgencyMetricsOptions) (*domain.AgencyNode, error) {
+	agencies, err := u.store.GetAgencySubtree(agencyID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return buildAgencyTree(agencies)
 }
 
-func (u *Metrics) GetAgencyTotals(titleFilter *string) ([]domain.AgencyMetric, error) {
-	return u.sqlite.GetAgencyTotals(titleFilter)
+// buildAgencyTree nests agencies, listed parents before children with the root first, under
+// their parents. Sibling order is kept.
+func buildAgencyTree(agencies []domain.AgencyMetric) (*domain.AgencyNode, error) {
+	if len(agencies) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	nodes := make(map[string]*domain.AgencyNode, len(agencies))
+	root := &domain.AgencyNode{AgencyMetric: agencies[0], Children: []*domain.AgencyNode{}}
+	nodes[root.ID] = root
+	for _, a := range agencies[1:] {
+		if a.ParentID == nil || nodes[*a.ParentID] == nil {
+			return nil, fmt.Errorf("agency %q listed before its parent", a.ID)
+		}
+		node := &domain.AgencyNode{AgencyMetric: a, Children: []*domain.AgencyNode{}}
+		parent := nodes[*a.ParentID]
+		parent.Children = append(parent.Children, node)
+		nodes[a.ID] = node
+	}
+	return root, nil
+}
+
+// GetAgencyChanges lists the registry changes recorded for an agency, oldest first.
+func (u *Metrics) GetAgencyChanges(agencyID string) ([]domain.AgencyChange, error) {
+	changes, err := u.store.GetAgencyChanges(agencyID)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []domain.AgencyChange{}
+	}
+	return changes, nil
 }
 
 // GetAgencyChecksum returns the SHA256 hash of all section content for an agency
 func (u *Metrics) GetAgencyChecksum(agencyID string) (string, error) {
-	return u.sqlite.GetAgencyChecksum(agencyID)
+	return u.store.GetAgencyChecksum(agencyID)
+}
+
+// GetAgencyTimeSeries returns one metric for an agency across the snapshots in [from, to].
+// from and to are optional YYYY-MM-DD dates; metric defaults to words.
+func (u *Metrics) GetAgencyTimeSeries(agencyID, from, to, metric string) ([]domain.TimeSeriesPoint, error) {
+	if metric == "" {
+		metric = MetricWords
+	}
+	// Checked before reading, so an agency without history still rejects an unknown metric
+	if _, err := metricValue(domain.AgencyMetricSnapshot{}, metric); err != nil {
+		return nil, err
+	}
+	for _, d := range []string{from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return nil, fmt.Errorf("%w: date %q must be YYYY-MM-DD", domain.ErrInvalidData, d)
+		}
+	}
+
+	history, err := u.store.GetAgencyMetricsHistory(agencyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]domain.TimeSeriesPoint, 0, len(history))
+	for _, h := range history {
+		v, err := metricValue(h, metric)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, domain.TimeSeriesPoint{SnapshotID: h.SnapshotID, Value: v})
+	}
+	return points, nil
+}
+
+func metricValue(m domain.AgencyMetricSnapshot, metric string) (float64, error) {
+	switch metric {
+	case MetricWords:
+		return float64(m.TotalWords), nil
+	case MetricRSCS:
+		return float64(m.TotalRSCS), nil
+	case MetricAvgRSCS:
+		return m.AvgRSCS, nil
+	case MetricWeightedAvgRSCS:
+		return m.WeightedAvgRSCS, nil
+	case MetricMedianRSCS:
+		return m.MedianRSCS, nil
+	case MetricP90RSCS:
+		return m.P90RSCS, nil
+	case MetricTotalRSCSPer1K:
+		return m.TotalRSCSPer1K, nil
+	case MetricRestrictions:
+		return float64(m.Restrictions), nil
+	case MetricSections:
+		return float64(m.SectionCount), nil
+	}
+	return 0, fmt.Errorf("%w: unknown metric %q", domain.ErrInvalidData, metric)
 }
@@ -1,7 +1,10 @@
 package usecase
 
 import (
+	"errors"
 	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
 func TestCountDefs(t *testing.T) {
@@ -74,3 +77,56 @@ func TestCountModals(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildAgencyTree(t *testing.T) {
+	usda, fs := "usda", "fs"
+	tree, err := buildAgencyTree([]domain.AgencyMetric{
+		{ID: "usda", TotalWords: 145},
+		{ID: "fs", ParentID: &usda, TotalWords: 105},
+		{ID: "aphis", ParentID: &usda, TotalWords: 40},
+		{ID: "ranger", ParentID: &fs, TotalWords: 105},
+	})
+	if err != nil {
+		t.Fatalf("buildAgencyTree failed: %v", err)
+	}
+	if tree.ID != "usda" || len(tree.Children) != 2 || tree.Children[0].ID != "fs" || tree.Children[1].ID != "aphis" {
+		t.Fatalf("unexpected tree: %+v", tree)
+	}
+	if len(tree.Children[0].Children) != 1 || tree.Children[0].Children[0].ID != "ranger" {
+		t.Errorf("fs children = %+v", tree.Children[0].Children)
+	}
+	if tree.Children[1].Children == nil {
+		t.Error("leaf children should be an empty list, not nil")
+	}
+
+	// A child listed before its parent is a store bug, not a missing agency
+	if _, err := buildAgencyTree([]domain.AgencyMetric{{ID: "usda"}, {ID: "ranger", ParentID: &fs}}); err == nil || errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("orphan error = %v", err)
+	}
+}
+
+type fakeHistoryStore struct {
+	AgencyStore
+	history []domain.AgencyMetricSnapshot
+}
+
+func (f *fakeHistoryStore) GetAgencyMetricsHistory(agencyID, from, to string) ([]domain.AgencyMetricSnapshot, error) {
+	return f.history, nil
+}
+
+func TestGetAgencyTimeSeriesValidation(t *testing.T) {
+	u := NewMetrics(nil, &fakeHistoryStore{})
+
+	for _, tt := range []struct{ from, to, metric string }{
+		{"", "", "bogus"},
+		{"2025-13-01", "", MetricWords},
+		{"", "yesterday", MetricWords},
+	} {
+		if _, err := u.GetAgencyTimeSeries("epa", tt.from, tt.to, tt.metric); !errors.Is(err, domain.ErrInvalidData) {
+			t.Errorf("GetAgencyTimeSeries(%q, %q, %q) error = %v, want ErrInvalidData", tt.from, tt.to, tt.metric, err)
+		}
+	}
+	if points, err := u.GetAgencyTimeSeries("epa", "", "", ""); err != nil || len(points) != 0 {
+		t.Errorf("GetAgencyTimeSeries with no history = %v, %v", points, err)
+	}
+}
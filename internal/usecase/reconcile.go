@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/lsa"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+	"go.uber.org/zap"
+)
+
+// AgencyReconciliation works out which eCFR agency each Federal Register agency is, so LSA
+// counts, which the Federal Register keys by its own slugs, reach the agencies table.
+type AgencyReconciliation struct {
+	logger    *zap.Logger
+	collector *lsa.Collector
+	store     FRAgencyMapStore
+}
+
+func NewAgencyReconciliation(logger *zap.Logger, collector *lsa.Collector, store FRAgencyMapStore) *AgencyReconciliation {
+	return &AgencyReconciliation{logger: logger, collector: collector, store: store}
+}
+
+// Reconcile fetches the Federal Register agencies, matches them to the stored eCFR agencies and
+// replaces the stored mapping. Unmatched agencies on either side are logged. An override naming
+// an unknown eCFR agency is domain.ErrInvalidData and leaves the mapping as it was.
+func (u *AgencyReconciliation) Reconcile(ctx context.Context, overrides []domain.FRAgencyOverride) ([]domain.FRAgencyMatch, error) {
+	frAgencies, err := u.collector.FetchFederalRegisterAgencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	agencies, err := u.store.GetAgencies()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := matchFRAgencies(frAgencies, agencies, overrides)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.store.ReplaceFRAgencyMap(matches); err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[string]int)
+	var unmatched []string
+	mapped := make(map[string]bool)
+	for _, m := range matches {
+		kinds[m.MatchKind]++
+		if m.MatchKind == domain.FRMatchUnmatched {
+			unmatched = append(unmatched, m.FRSlug)
+		}
+		mapped[m.AgencyID] = true
+	}
+	var unmapped []string
+	for _, a := range agencies {
+		if !mapped[a.ID] {
+			unmapped = append(unmapped, a.ID)
+		}
+	}
+	u.logger.Info("Reconciled Federal Register agencies",
+		zap.Int("override", kinds[domain.FRMatchOverride]),
+		zap.Int("slug", kinds[domain.FRMatchSlug]),
+		zap.Int("name", kinds[domain.FRMatchName]),
+		zap.Int("parent", kinds[domain.FRMatchParent]))
+	if len(unmatched) > 0 {
+		u.logger.Warn("Federal Register agencies without an eCFR agency", zap.Strings("fr_slugs", unmatched))
+	}
+	if len(unmapped) > 0 {
+		u.logger.Info("eCFR agencies without a Federal Register agency", zap.Strings("agency_ids", unmapped))
+	}
+	return matches, nil
+}
+
+// matchFRAgencies matches every Federal Register agency to at most one eCFR agency, trying in
+// turn the overrides, the slug, and the normalized name. A name held by several eCFR agencies
+// (e.g. "Office of the Secretary") matches the one whose parent is the match of the Federal
+// Register agency's parent. Matches are sorted by Federal Register slug.
+func matchFRAgencies(frAgencies []lsa.AgencyInfo, agencies []domain.Agency, overrides []domain.FRAgencyOverride) ([]domain.FRAgencyMatch, error) {
+	known := make(map[string]domain.Agency, len(agencies))
+	byName := make(map[string][]string)
+	for _, a := range agencies {
+		known[a.ID] = a
+		seen := make(map[string]bool)
+		for _, key := range agencyNameKeys(a) {
+			if !seen[key] {
+				seen[key] = true
+				byName[key] = append(byName[key], a.ID)
+			}
+		}
+	}
+	pinned := make(map[string]string, len(overrides))
+	for _, o := range overrides {
+		if _, ok := known[o.AgencyID]; o.AgencyID != "" && !ok {
+			return nil, fmt.Errorf("%w: override for %q names unknown agency %q", domain.ErrInvalidData, o.FRSlug, o.AgencyID)
+		}
+		pinned[o.FRSlug] = o.AgencyID
+	}
+
+	slugByID := make(map[int]string, len(frAgencies))
+	for _, fr := range frAgencies {
+		slugByID[fr.ID] = fr.Slug
+	}
+
+	matches := make(map[string]*domain.FRAgencyMatch, len(frAgencies))
+	ambiguous := make(map[string][]string)
+	for _, fr := range frAgencies {
+		m := &domain.FRAgencyMatch{FRSlug: fr.Slug, FRName: fr.Name, MatchKind: domain.FRMatchUnmatched}
+		matches[fr.Slug] = m
+		if id, ok := pinned[fr.Slug]; ok {
+			m.AgencyID, m.MatchKind = id, domain.FRMatchOverride
+			continue
+		}
+		if _, ok := known[fr.Slug]; ok {
+			m.AgencyID, m.MatchKind = fr.Slug, domain.FRMatchSlug
+			continue
+		}
+		switch candidates := byName[normalizeAgencyName(fr.Name)]; len(candidates) {
+		case 0:
+		case 1:
+			m.AgencyID, m.MatchKind = candidates[0], domain.FRMatchName
+		default:
+			ambiguous[fr.Slug] = candidates
+		}
+	}
+
+	// Parents may themselves be told apart by their parents, so repeat until nothing changes
+	for progress := true; progress; {
+		progress = false
+		for _, fr := range frAgencies {
+			candidates := ambiguous[fr.Slug]
+			if candidates == nil || fr.ParentID == nil {
+				continue
+			}
+			parent := matches[slugByID[*fr.ParentID]]
+			if parent == nil || parent.AgencyID == "" {
+				continue
+			}
+			var found []string
+			for _, id := range candidates {
+				if p := known[id].ParentID; p != nil && *p == parent.AgencyID {
+					found = append(found, id)
+				}
+			}
+			if len(found) == 1 {
+				m := matches[fr.Slug]
+				m.AgencyID, m.MatchKind = found[0], domain.FRMatchParent
+				delete(ambiguous, fr.Slug)
+				progress = true
+			}
+		}
+	}
+
+	result := make([]domain.FRAgencyMatch, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].FRSlug < result[j].FRSlug })
+	return result, nil
+}
+
+// agencyNameKeys lists the normalized names an eCFR agency can be found under: its name, its
+// name without the ", Department of ..." suffix naming its parent, and its sortable name, both
+// as is and turned back around ("Agriculture, Department of" as "Department of Agriculture").
+func agencyNameKeys(a domain.Agency) []string {
+	names := []string{a.Name, a.SortableName}
+	if head, _, ok := strings.Cut(a.Name, ", "); ok {
+		names = append(names, head)
+	}
+	if head, tail, ok := strings.Cut(a.SortableName, ", "); ok {
+		names = append(names, tail+" "+head)
+	}
+	var keys []string
+	for _, name := range names {
+		if key := normalizeAgencyName(name); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// normalizeAgencyName reduces an agency name to lower-case words without punctuation or "the",
+// with "&" spelled out and "Department of X" written the Federal Register way, "X Department".
+func normalizeAgencyName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("&", " and ", ".", "", "'", "", "’", "").Replace(name)
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	kept := words[:0]
+	for _, w := range words {
+		if w != "the" {
+			kept = append(kept, w)
+		}
+	}
+	if len(kept) > 2 && kept[0] == "department" && kept[1] == "of" {
+		kept = append(kept[2:], "department")
+	}
+	return strings.Join(kept, " ")
+}
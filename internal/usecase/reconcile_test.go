@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/lsa"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+func TestNormalizeAgencyName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Environmental Protection Agency", "environmental protection agency"},
+		{"Department of Health and Human Services", "health and human services department"},
+		{"Health & Human Services Department", "health and human services department"},
+		{"Office of the U.S. Trade Representative", "office of us trade representative"},
+		{"Department of", "department of"},
+	}
+	for _, tt := range tests {
+		if got := normalizeAgencyName(tt.name); got != tt.want {
+			t.Errorf("normalizeAgencyName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFRAgencies(t *testing.T) {
+	usda, doi := "agriculture-department", "interior-department"
+	agencies := []domain.Agency{
+		{ID: "agriculture-department", Name: "Department of Agriculture", SortableName: "Agriculture, Department of"},
+		{ID: "interior-department", Name: "Department of the Interior", SortableName: "Interior, Department of the"},
+		{ID: "agricultural-marketing-service", Name: "Agricultural Marketing Service, Department of Agriculture", SortableName: "Agricultural Marketing Service", ParentID: &usda},
+		{ID: "usda-secretary", Name: "Office of the Secretary of Agriculture", SortableName: "Office of the Secretary", ParentID: &usda},
+		{ID: "doi-secretary", Name: "Office of the Secretary of the Interior", SortableName: "Office of the Secretary", ParentID: &doi},
+		{ID: "justice-department", Name: "Department of Justice", SortableName: "Justice, Department of"},
+	}
+	parent := func(id int) *int { return &id }
+	fr := []lsa.AgencyInfo{
+		{ID: 1, Slug: "agriculture-department", Name: "Agriculture Department"},
+		{ID: 2, Slug: "interior-dept", Name: "Interior Department"},
+		{ID: 3, Slug: "ams", Name: "Agricultural Marketing Service", ParentID: parent(1)},
+		{ID: 4, Slug: "secretary-office-interior", Name: "Office of the Secretary", ParentID: parent(2)},
+		{ID: 5, Slug: "secretary-office-unknown", Name: "Office of the Secretary"},
+		{ID: 6, Slug: "antitrust-division", Name: "Antitrust Division"},
+		{ID: 7, Slug: "census-bureau", Name: "Census Bureau"},
+	}
+	overrides := []domain.FRAgencyOverride{{FRSlug: "antitrust-division", AgencyID: "justice-department"}}
+
+	got, err := matchFRAgencies(fr, agencies, overrides)
+	if err != nil {
+		t.Fatalf("matchFRAgencies failed: %v", err)
+	}
+	want := []domain.FRAgencyMatch{
+		{FRSlug: "agriculture-department", FRName: "Agriculture Department", AgencyID: "agriculture-department", MatchKind: domain.FRMatchSlug},
+		{FRSlug: "ams", FRName: "Agricultural Marketing Service", AgencyID: "agricultural-marketing-service", MatchKind: domain.FRMatchName},
+		{FRSlug: "antitrust-division", FRName: "Antitrust Division", AgencyID: "justice-department", MatchKind: domain.FRMatchOverride},
+		{FRSlug: "census-bureau", FRName: "Census Bureau", MatchKind: domain.FRMatchUnmatched},
+		{FRSlug: "interior-dept", FRName: "Interior Department", AgencyID: "interior-department", MatchKind: domain.FRMatchName},
+		{FRSlug: "secretary-office-interior", FRName: "Office of the Secretary", AgencyID: "doi-secretary", MatchKind: domain.FRMatchParent},
+		{FRSlug: "secretary-office-unknown", FRName: "Office of the Secretary", MatchKind: domain.FRMatchUnmatched},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("matchFRAgencies =\n%+v\nwant\n%+v", got, want)
+	}
+
+	bad := []domain.FRAgencyOverride{{FRSlug: "antitrust-division", AgencyID: "no-such-agency"}}
+	if _, err := matchFRAgencies(fr, agencies, bad); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("expected ErrInvalidData for an unknown agency, got %v", err)
+	}
+}
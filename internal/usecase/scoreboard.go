@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Scoreboard answers "who actually removed regulatory text" from the snapshot diffs.
+type Scoreboard struct {
+	store DiffStore
+}
+
+func NewScoreboard(store DiffStore) *Scoreboard {
+	return &Scoreboard{store: store}
+}
+
+// Compute returns the ranked scoreboard for diffs recorded in snapshots between from and to
+// (inclusive YYYY-MM-DD dates, either may be empty).
+func (u *Scoreboard) Compute(from, to string) ([]domain.ScoreboardEntry, error) {
+	for _, d := range []string{from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return nil, fmt.Errorf("%w: date %q must be YYYY-MM-DD", domain.ErrInvalidData, d)
+		}
+	}
+	if from != "" && to != "" && from > to {
+		return nil, fmt.Errorf("%w: from %s is after to %s", domain.ErrInvalidData, from, to)
+	}
+
+	entries, err := u.store.GetScoreboard(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return rankScoreboard(entries), nil
+}
+
+// rankScoreboard fills in the net changes and orders agencies by the largest net
+// reduction in words, breaking ties by words removed and then agency ID.
+// Agencies with no activity in the window are dropped.
+func rankScoreboard(entries []domain.ScoreboardEntry) []domain.ScoreboardEntry {
+	ranked := make([]domain.ScoreboardEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.WordsRemoved == 0 && e.WordsAdded == 0 && e.RestrictionsRemoved == 0 &&
+			e.RestrictionsAdded == 0 && e.SectionsRemoved == 0 {
+			continue
+		}
+		e.NetWords = e.WordsAdded - e.WordsRemoved
+		e.NetRestrictions = e.RestrictionsAdded - e.RestrictionsRemoved
+		ranked = append(ranked, e)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].NetWords != ranked[j].NetWords {
+			return ranked[i].NetWords < ranked[j].NetWords
+		}
+		if ranked[i].WordsRemoved != ranked[j].WordsRemoved {
+			return ranked[i].WordsRemoved > ranked[j].WordsRemoved
+		}
+		return ranked[i].AgencyID < ranked[j].AgencyID
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+	return ranked
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+func TestRankScoreboard(t *testing.T) {
+	entries := []domain.ScoreboardEntry{
+		{AgencyID: "grower", WordsAdded: 500, WordsRemoved: 100},
+		{AgencyID: "idle"},
+		{AgencyID: "cutter", WordsRemoved: 900, WordsAdded: 100, SectionsRemoved: 3},
+		{AgencyID: "trimmer-b", WordsRemoved: 300, RestrictionsRemoved: 2},
+		{AgencyID: "trimmer-a", WordsRemoved: 300},
+	}
+
+	got := rankScoreboard(entries)
+
+	want := []string{"cutter", "trimmer-a", "trimmer-b", "grower"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(got))
+	}
+	for i, id := range want {
+		if got[i].AgencyID != id {
+			t.Errorf("rank %d: expected %s, got %s", i+1, id, got[i].AgencyID)
+		}
+		if got[i].Rank != i+1 {
+			t.Errorf("%s: expected rank %d, got %d", id, i+1, got[i].Rank)
+		}
+	}
+	if got[0].NetWords != -800 {
+		t.Errorf("cutter: expected net -800, got %d", got[0].NetWords)
+	}
+	if got[2].NetRestrictions != -2 {
+		t.Errorf("trimmer-b: expected net restrictions -2, got %d", got[2].NetRestrictions)
+	}
+}
+
+func TestDiffSections(t *testing.T) {
+	prev := []domain.Section{
+		{ID: "1.1", WordCount: 100, ModalCount: 2, ChecksumSHA256: "a"},
+		{ID: "1.2", WordCount: 40, ModalCount: 1, ChecksumSHA256: "b"},
+		{ID: "1.3", WordCount: 10, ChecksumSHA256: "c"},
+	}
+	curr := []domain.Section{
+		{ID: "1.1", WordCount: 80, ModalCount: 1, ChecksumSHA256: "a2"},
+		{ID: "1.3", WordCount: 10, ChecksumSHA256: "c"},
+		{ID: "1.4", WordCount: 25, ChecksumSHA256: "d"},
+	}
+
+	diffs := diffSections("2025-01-01", "1", prev, curr)
+
+	want := map[string]string{
+		"1.1": domain.DiffModified,
+		"1.2": domain.DiffRemoved,
+		"1.3": domain.DiffUnchanged,
+		"1.4": domain.DiffAdded,
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("expected %d diffs, got %d", len(want), len(diffs))
+	}
+	for _, d := range diffs {
+		if d.Status != want[d.SectionID] {
+			t.Errorf("%s: expected %s, got %s", d.SectionID, want[d.SectionID], d.Status)
+		}
+		if d.PrevSnapshotID != "2025-01-01" {
+			t.Errorf("%s: expected prev snapshot to be recorded", d.SectionID)
+		}
+	}
+	removed := diffs[len(diffs)-1]
+	if removed.SectionID != "1.2" || removed.WordsBefore != 40 || removed.DeltaWordCount != -40 || removed.RestrictionsBefore != 1 {
+		t.Errorf("unexpected removed diff: %+v", removed)
+	}
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Page sizes for search results
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// Search answers full-text queries over the current regulation text.
+type Search struct {
+	store SearchStore
+}
+
+func NewSearch(store SearchStore) *Search {
+	return &Search{store: store}
+}
+
+// Search validates q, fills in the default page size and runs it. A store without a text index
+// returns domain.ErrUnsupported.
+func (u *Search) Search(q domain.SearchQuery) (*domain.SearchResults, error) {
+	q.Query = strings.TrimSpace(q.Query)
+	if q.Query == "" {
+		return nil, fmt.Errorf("%w: q is required", domain.ErrInvalidData)
+	}
+	if q.Title != "" {
+		if n, err := strconv.Atoi(q.Title); err != nil || n < 1 || n > 50 {
+			return nil, fmt.Errorf("%w: title %q must be a CFR title number", domain.ErrInvalidData, q.Title)
+		}
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxSearchLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", domain.ErrInvalidData, MaxSearchLimit)
+	}
+	if q.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", domain.ErrInvalidData)
+	}
+	return u.store.Search(q)
+}
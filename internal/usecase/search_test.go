@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+type fakeSearchStore struct {
+	got domain.SearchQuery
+}
+
+func (f *fakeSearchStore) Search(q domain.SearchQuery) (*domain.SearchResults, error) {
+	f.got = q
+	return &domain.SearchResults{Query: q.Query, Limit: q.Limit, Offset: q.Offset}, nil
+}
+
+func TestSearchValidation(t *testing.T) {
+	store := &fakeSearchStore{}
+	u := NewSearch(store)
+
+	if _, err := u.Search(domain.SearchQuery{Query: "  emissions "}); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if store.got.Query != "emissions" || store.got.Limit != DefaultSearchLimit {
+		t.Errorf("store got %+v, want trimmed query and default limit", store.got)
+	}
+
+	for _, q := range []domain.SearchQuery{
+		{Query: "   "},
+		{Query: "x", Title: "abc"},
+		{Query: "x", Title: "51"},
+		{Query: "x", Limit: MaxSearchLimit + 1},
+		{Query: "x", Limit: -1},
+		{Query: "x", Offset: -1},
+	} {
+		if _, err := u.Search(q); !errors.Is(err, domain.ErrInvalidData) {
+			t.Errorf("Search(%+v) error = %v, want ErrInvalidData", q, err)
+		}
+	}
+}
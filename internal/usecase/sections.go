@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Sections serves current sections by citation or ID.
+type Sections struct {
+	store sectionSummaryStore
+}
+
+type sectionSummaryStore interface {
+	SectionStore
+	SummaryStore
+}
+
+func NewSections(store sectionSummaryStore) *Sections {
+	return &Sections{store: store}
+}
+
+// GetSection returns the current section ref names, as read by domain.ParseSectionRef, with its
+// breadcrumb and latest summary. A bare section number found in several titles is
+// domain.ErrInvalidData naming their citations; one found in none is domain.ErrNotFound.
+func (u *Sections) GetSection(ref string) (*domain.SectionDetail, error) {
+	title, section, err := domain.ParseSectionRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	key := domain.SectionKey(title, section)
+	if title == "" {
+		keys, err := u.store.FindSectionKeys(section)
+		if err != nil {
+			return nil, err
+		}
+		switch len(keys) {
+		case 0:
+			return nil, fmt.Errorf("%w: section %q", domain.ErrNotFound, ref)
+		case 1:
+			key = keys[0]
+		default:
+			citations := make([]string, len(keys))
+			for i, k := range keys {
+				citations[i] = keyCitation(k)
+			}
+			return nil, fmt.Errorf("%w: section %q is in several titles; cite one of %s", domain.ErrInvalidData, ref, strings.Join(citations, ", "))
+		}
+	}
+
+	detail, err := u.store.GetSectionDetail(key)
+	if err != nil {
+		return nil, err
+	}
+	detail.Citation = keyCitation(detail.Key)
+	detail.Breadcrumb = sectionBreadcrumb(detail)
+	summary, err := u.store.GetSummaryByKey("section", detail.Key)
+	if err != nil {
+		return nil, err
+	}
+	if summary != nil {
+		detail.Summary = summary.Text
+		detail.SummaryCreatedAt = &summary.CreatedAt
+	}
+	return detail, nil
+}
+
+// keyCitation writes a section key ("40:60.5") as a citation ("40 CFR 60.5")
+func keyCitation(key string) string {
+	title, section, _ := strings.Cut(key, ":")
+	return title + " CFR " + section
+}
+
+// sectionBreadcrumb lists the levels of the CFR a section sits under, from its title down to
+// the section itself
+func sectionBreadcrumb(d *domain.SectionDetail) []domain.HierarchyLevel {
+	levels := []domain.HierarchyLevel{{Level: "title", ID: d.Title, Name: d.TitleName}}
+	for _, l := range []struct{ level, id string }{
+		{"subtitle", d.Subtitle},
+		{"chapter", d.Chapter},
+		{"subchapter", d.Subchapter},
+		{"part", d.Part},
+	} {
+		if l.id != "" {
+			levels = append(levels, domain.HierarchyLevel{Level: l.level, ID: l.id})
+		}
+	}
+	_, number, _ := strings.Cut(d.Key, ":")
+	return append(levels, domain.HierarchyLevel{Level: "section", ID: number, Name: d.Heading})
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+type fakeSectionStore struct {
+	SectionStore
+	SummaryStore
+	keys map[string][]string // Section number to the keys holding it
+}
+
+func (f *fakeSectionStore) FindSectionKeys(section string) ([]string, error) {
+	return f.keys[section], nil
+}
+
+func (f *fakeSectionStore) GetSectionDetail(key string) (*domain.SectionDetail, error) {
+	if key != "40:60.5" && key != "7:1.1" {
+		return nil, domain.ErrNotFound
+	}
+	return &domain.SectionDetail{Key: key, Title: "40", TitleName: "Protection of Environment", Chapter: "I", Part: "60", Heading: "§ 60.5 Determination."}, nil
+}
+
+func (f *fakeSectionStore) GetSummaryByKey(kind, key string) (*domain.Summary, error) {
+	if kind == "section" && key == "40:60.5" {
+		return &domain.Summary{Text: "Construction."}, nil
+	}
+	return nil, nil
+}
+
+func TestParseSectionRef(t *testing.T) {
+	tests := []struct {
+		ref, title, section string
+	}{
+		{"40 CFR 60.5", "40", "60.5"},
+		{"40 C.F.R. § 60.5", "40", "60.5"},
+		{"40 cfr §60.5", "40", "60.5"},
+		{"040:60.5", "40", "60.5"},
+		{"§ 60.5", "", "60.5"},
+		{"60.5", "", "60.5"},
+	}
+	for _, tt := range tests {
+		title, section, err := domain.ParseSectionRef(tt.ref)
+		if err != nil || title != tt.title || section != tt.section {
+			t.Errorf("ParseSectionRef(%q) = %q, %q, %v; want %q, %q", tt.ref, title, section, err, tt.title, tt.section)
+		}
+	}
+	for _, ref := range []string{"", "§", "51 CFR 1.1", "x:1.1", "40:"} {
+		if _, _, err := domain.ParseSectionRef(ref); !errors.Is(err, domain.ErrInvalidData) {
+			t.Errorf("ParseSectionRef(%q) error = %v, want ErrInvalidData", ref, err)
+		}
+	}
+}
+
+func TestGetSection(t *testing.T) {
+	u := NewSections(&fakeSectionStore{keys: map[string][]string{
+		"60.5": {"40:60.5"},
+		"1.1":  {"7:1.1", "40:1.1"},
+	}})
+
+	for _, ref := range []string{"40 CFR 60.5", "40:60.5", "§ 60.5"} {
+		d, err := u.GetSection(ref)
+		if err != nil {
+			t.Fatalf("GetSection(%q) failed: %v", ref, err)
+		}
+		want := []domain.HierarchyLevel{
+			{Level: "title", ID: "40", Name: "Protection of Environment"},
+			{Level: "chapter", ID: "I"},
+			{Level: "part", ID: "60"},
+			{Level: "section", ID: "60.5", Name: "§ 60.5 Determination."},
+		}
+		if d.Citation != "40 CFR 60.5" || d.Summary != "Construction." || !reflect.DeepEqual(d.Breadcrumb, want) {
+			t.Errorf("GetSection(%q) = %+v", ref, d)
+		}
+	}
+
+	if _, err := u.GetSection("1.1"); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("ambiguous section error = %v, want ErrInvalidData", err)
+	}
+	if _, err := u.GetSection("99.9"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown section error = %v, want ErrNotFound", err)
+	}
+	if _, err := u.GetSection("40 CFR 99.9"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("unknown cited section error = %v, want ErrNotFound", err)
+	}
+}
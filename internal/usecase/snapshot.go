@@ -2,53 +2,202 @@ package usecase
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/parquet"
-	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 )
 
 type Snapshot struct {
 	parquetRepo *parquet.Repo
-	sqliteRepo  *sqlite.Repo
+	store       snapshotDiffStore
 }
 
-func NewSnapshot(parquet *parquet.Repo, sqlite *sqlite.Repo) *Snapshot {
-	return &Snapshot{parquetRepo: parquet, sqliteRepo: sqlite}
+type snapshotDiffStore interface {
+	SnapshotStore
+	SectionStore
+	DiffStore
 }
 
-func (u *Snapshot) ComputeDiffs(ctx context.Context, snapshotDate, title string) ([]domain.Diff, error) {
-	prevDate, err := u.parquetRepo.GetPrevSnapshot(ctx, snapshotDate)
-	if err != nil {
-		return nil, err
-	}
+func NewSnapshot(parquet *parquet.Repo, store snapshotDiffStore) *Snapshot {
+	return &Snapshot{parquetRepo: parquet, store: store}
+}
+
+// PrevSnapshot returns the complete snapshot a run writing snapshotID diffs against, or "" for
+// the first run. Resolve it once per run and pass it to ComputeDiffs for every title.
+func (u *Snapshot) PrevSnapshot(ctx context.Context, snapshotID string) (string, error) {
+	return u.parquetRepo.GetPrevSnapshot(ctx, snapshotID)
+}
 
+// ComputeDiffs compares the sections just ingested for a title with the same title in prevID,
+// the previous complete snapshot ("" when there is none). currSections are passed in because
+// the snapshot being written is not readable until its manifest is complete.
+func (u *Snapshot) ComputeDiffs(ctx context.Context, prevID, title string, currSections []domain.Section) ([]domain.Diff, error) {
 	var prevSections []domain.Section
-	if prevDate != "" {
-		prevSections, err = u.parquetRepo.ReadSections(ctx, prevDate, title)
+	if prevID != "" {
+		// Only the columns diffSections compares are decoded
+		q := parquet.SectionQuery{Columns: []string{"id", "part", "agency_id", "checksum_sha256", "word_count", "modal_count"}}
+		err := u.parquetRepo.ScanSections(ctx, prevID, title, q, func(s domain.Section) error {
+			prevSections = append(prevSections, s)
+			return nil
+		})
 		if err != nil {
 			return nil, err
 		}
 	}
-	currSections, err := u.parquetRepo.ReadSections(ctx, snapshotDate, title)
+
+	return diffSections(prevID, title, prevSections, currSections), nil
+}
+
+// Publish points the snapshot's day at it in both the Parquet store and the relational store. The snapshot
+// must already have a complete manifest.
+func (u *Snapshot) Publish(ctx context.Context, snapshotID string) (*domain.PublishedSnapshot, error) {
+	p, err := u.parquetRepo.PublishSnapshot(ctx, snapshotID, time.Now())
 	if err != nil {
 		return nil, err
 	}
+	if err := u.store.PublishSnapshot(*p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
 
+// LoadSections copies a title's sections, text included, from a published snapshot into the
+// relational store and returns how many it loaded. The ETL loads each title only after
+// publishing, so the API never serves a run that failed partway.
+func (u *Snapshot) LoadSections(ctx context.Context, snapshotID, title string) (int, error) {
+	q := parquet.SectionQuery{Columns: append(slices.Clone(parquet.SectionMetricColumns), "text")}
+	var sections []domain.Section
+	err := u.parquetRepo.ScanSections(ctx, snapshotID, title, q, func(s domain.Section) error {
+		sections = append(sections, s)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := u.store.InsertSections(sections); err != nil {
+		return 0, err
+	}
+	return len(sections), nil
+}
+
+// GetPublished returns the snapshot published for a YYYY-MM-DD day.
+func (u *Snapshot) GetPublished(ctx context.Context, day string) (*domain.PublishedSnapshot, error) {
+	return u.parquetRepo.GetPublishedSnapshot(ctx, day)
+}
+
+// ListSnapshots returns the manifest of every snapshot, oldest first.
+func (u *Snapshot) ListSnapshots(ctx context.Context) ([]domain.SnapshotManifest, error) {
+	return u.parquetRepo.ListManifests(ctx)
+}
+
+// diffSections compares two versions of a title. Sections only present in prev are
+// reported as removed, after all current sections in their original order.
+func diffSections(prevID, title string, prevSections, currSections []domain.Section) []domain.Diff {
 	diffs := []domain.Diff{}
 	prevMap := make(map[string]domain.Section)
 	for _, p := range prevSections {
 		prevMap[p.ID] = p
 	}
+	seen := make(map[string]bool, len(currSections))
 	for _, c := range currSections {
+		seen[c.ID] = true
+		d := domain.Diff{
+			SectionID:         c.ID,
+			Title:             title,
+			Part:              c.Part,
+			AgencyID:          c.AgencyID,
+			PrevSnapshotID:    prevID,
+			WordsAfter:        c.WordCount,
+			RestrictionsAfter: c.ModalCount,
+		}
 		p, ok := prevMap[c.ID]
 		if !ok {
-			diffs = append(diffs, domain.Diff{SectionID: c.ID, DeltaWordCount: c.WordCount, Changed: true})
+			d.Status = domain.DiffAdded
+			d.DeltaWordCount = c.WordCount
+			d.Changed = true
+			diffs = append(diffs, d)
+			continue
+		}
+		d.WordsBefore = p.WordCount
+		d.RestrictionsBefore = p.ModalCount
+		d.DeltaWordCount = c.WordCount - p.WordCount
+		d.Changed = c.ChecksumSHA256 != p.ChecksumSHA256
+		d.Status = domain.DiffUnchanged
+		if d.Changed {
+			d.Status = domain.DiffModified
+		}
+		diffs = append(diffs, d)
+	}
+	for _, p := range prevSections {
+		if seen[p.ID] {
 			continue
 		}
-		delta := c.WordCount - p.WordCount
-		changed := c.ChecksumSHA256 != p.ChecksumSHA256
-		diffs = append(diffs, domain.Diff{SectionID: c.ID, DeltaWordCount: delta, Changed: changed})
+		seen[p.ID] = true
+		diffs = append(diffs, domain.Diff{
+			SectionID:          p.ID,
+			Title:              title,
+			Part:               p.Part,
+			AgencyID:           p.AgencyID,
+			PrevSnapshotID:     prevID,
+			Status:             domain.DiffRemoved,
+			WordsBefore:        p.WordCount,
+			RestrictionsBefore: p.ModalCount,
+			DeltaWordCount:     -p.WordCount,
+			Changed:            true,
+		})
+	}
+	return diffs
+}
+
+// GetDiffs returns the changed sections stored for a snapshot (the latest one if snapshotID is
+// empty), each with the Federal Register documents it was attributed to. A malformed snapshot
+// ID or a title outside 1-50 is domain.ErrInvalidData.
+func (u *Snapshot) GetDiffs(ctx context.Context, snapshotID, title string) (string, []domain.AttributedDiff, error) {
+	if title != "" {
+		n, err := strconv.Atoi(title)
+		if err != nil || n < 1 || n > 50 {
+			return "", nil, fmt.Errorf("%w: title %q must be a CFR title number", domain.ErrInvalidData, title)
+		}
+		title = strconv.Itoa(n)
+	}
+	if snapshotID != "" {
+		if _, err := domain.ParseSnapshotID(snapshotID); err != nil {
+			return "", nil, err
+		}
+	}
+	if snapshotID == "" {
+		latest, err := u.store.GetLatestDiffSnapshot()
+		if err != nil {
+			return "", nil, err
+		}
+		if latest == "" {
+			return "", nil, nil
+		}
+		snapshotID = latest
+	}
+
+	diffs, err := u.store.GetSectionDiffs(snapshotID, title)
+	if err != nil {
+		return "", nil, err
+	}
+	attributions, err := u.store.GetDiffAttributions(snapshotID, title)
+	if err != nil {
+		return "", nil, err
+	}
+
+	bySection := make(map[string][]domain.DiffAttribution)
+	for _, a := range attributions {
+		key := a.Title + "/" + a.SectionID
+		bySection[key] = append(bySection[key], a)
+	}
+
+	out := make([]domain.AttributedDiff, len(diffs))
+	for i, d := range diffs {
+		out[i] = domain.AttributedDiff{Diff: d, Attributions: bySection[d.Title+"/"+d.SectionID]}
 	}
-	return diffs, nil
+	return snapshotID, out, nil
 }
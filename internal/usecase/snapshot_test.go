@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+type fakeDiffStore struct {
+	snapshotDiffStore
+	title string
+}
+
+func (f *fakeDiffStore) GetSectionDiffs(snapshotID, title string) ([]domain.Diff, error) {
+	f.title = title
+	return nil, nil
+}
+
+func (f *fakeDiffStore) GetDiffAttributions(snapshotID, title string) ([]domain.DiffAttribution, error) {
+	return nil, nil
+}
+
+func TestGetDiffsValidation(t *testing.T) {
+	store := &fakeDiffStore{}
+	u := NewSnapshot(nil, store)
+	ctx := context.Background()
+
+	for _, tt := range []struct{ snapshot, title string }{
+		{"2025-13-01", ""},
+		{"latest", ""},
+		{"2025-11-19", "0"},
+		{"2025-11-19", "x"},
+	} {
+		if _, _, err := u.GetDiffs(ctx, tt.snapshot, tt.title); !errors.Is(err, domain.ErrInvalidData) {
+			t.Errorf("GetDiffs(%q, %q) = %v, want ErrInvalidData", tt.snapshot, tt.title, err)
+		}
+	}
+
+	id, diffs, err := u.GetDiffs(ctx, "2025-11-19", "040")
+	if err != nil || id != "2025-11-19" || len(diffs) != 0 {
+		t.Fatalf("GetDiffs = %q, %+v, %v", id, diffs, err)
+	}
+	if store.title != "40" {
+		t.Errorf("title passed to store = %q, want 40", store.title)
+	}
+}
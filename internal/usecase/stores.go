@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/ecfr"
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// The relational store behind the use cases. sqlite.Repo backs a single instance; postgres.Repo
+// lets several API replicas share one database. Use cases take the narrowest interface they need.
+
+// SectionStore keeps the sections each snapshot loaded and their text.
+type SectionStore interface {
+	InsertSections(sections []domain.Section) error
+	GetSectionText(hash string) (string, error)
+	FindSectionKeys(section string) ([]string, error)
+	GetSectionDetail(key string) (*domain.SectionDetail, error)
+}
+
+// AgencyStore keeps agencies, their CFR references, the parts they own and the rollups computed
+// from their sections.
+type AgencyStore interface {
+	SyncAgencies(agencies []ecfr.Agency, syncedAt time.Time) ([]domain.AgencyChange, error)
+	GetAgencyChanges(agencyID string) ([]domain.AgencyChange, error)
+	ResolvePartOwners(overrides []domain.PartOwnerOverride) error
+	GetAllAgencyIDs() ([]string, error)
+	GetAgencyTotals(titleFilter *string, opts domain.AgencyMetricsOptions) ([]domain.AgencyMetric, error)
+	GetAgencySubtree(agencyID string, opts domain.AgencyMetricsOptions) ([]domain.AgencyMetric, error)
+	GetAgencyDetail(agencyID string, opts domain.AgencyMetricsOptions) (*domain.AgencyDetail, error)
+	GetAgencyChecksum(agencyID string) (string, error)
+	UpdateAgencyChecksum(agencyID, checksum string) error
+	SnapshotAgencyMetrics(snapshotID string, computedAt time.Time) ([]domain.AgencyMetricSnapshot, error)
+	GetAgencyMetricsHistory(agencyID, from, to string) ([]domain.AgencyMetricSnapshot, error)
+}
+
+// TitleStore keeps the eCFR title catalog and the totals of each title's current sections.
+type TitleStore interface {
+	UpsertTitles(titles []domain.Title) error
+	GetTitleMetrics() ([]domain.TitleMetric, error)
+	GetTitleDetail(title string) (*domain.TitleDetail, error)
+}
+
+// SummaryStore keeps AI-generated summaries.
+type SummaryStore interface {
+	InsertSummary(summary domain.Summary) error
+	InsertSummaries(summaries []domain.Summary) error
+	GetAllSummaries() ([]domain.Summary, error)
+	GetSummaryByKey(kind, key string) (*domain.Summary, error)
+}
+
+// LSAStore keeps per-agency Federal Register activity.
+type LSAStore interface {
+	InsertAgencyLSA(lsa domain.AgencyLSA) error
+	InsertAgencyLSABatch(lsaRecords []domain.AgencyLSA) error
+	GetAgencyLSA(agencyID string) (*domain.AgencyLSA, error)
+	GetAllAgencyLSA() ([]domain.AgencyLSA, error)
+	HasRecentAgencyLSA(withinDays int) (bool, error)
+}
+
+// FRAgencyMapStore keeps which eCFR agency each Federal Register agency is, which LSA counts
+// are joined to agencies through.
+type FRAgencyMapStore interface {
+	GetAgencies() ([]domain.Agency, error)
+	ReplaceFRAgencyMap(matches []domain.FRAgencyMatch) error
+}
+
+// DiffStore keeps the changed sections of each snapshot and the documents they are attributed to.
+type DiffStore interface {
+	InsertDiffs(snapshotID string, diffs []domain.Diff) error
+	GetScoreboard(from, to string) ([]domain.ScoreboardEntry, error)
+	GetLatestDiffSnapshot() (string, error)
+	GetSectionDiffs(snapshotID, title string) ([]domain.Diff, error)
+	InsertDiffAttributions(attributions []domain.DiffAttribution) error
+	GetDiffAttributions(snapshotID, title string) ([]domain.DiffAttribution, error)
+}
+
+// SnapshotStore keeps which snapshot is published for each day.
+type SnapshotStore interface {
+	PublishSnapshot(p domain.PublishedSnapshot) error
+	GetPublishedSnapshot(day string) (*domain.PublishedSnapshot, error)
+}
+
+// SearchStore runs full-text queries over the current section text. Stores without a text index
+// return domain.ErrUnsupported.
+type SearchStore interface {
+	Search(q domain.SearchQuery) (*domain.SearchResults, error)
+}
+
+// Store is everything the ETL and API keep in the relational store.
+type Store interface {
+	SectionStore
+	AgencyStore
+	TitleStore
+	SummaryStore
+	LSAStore
+	FRAgencyMapStore
+	DiffStore
+	SnapshotStore
+	SearchStore
+	Close() error
+}
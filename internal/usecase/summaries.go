@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/parquet"
-	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/vertexai"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
 	"go.uber.org/zap"
@@ -17,21 +16,21 @@ type Summaries struct {
 	logger      *zap.Logger
 	vertex      *vertexai.Client
 	parquetRepo *parquet.Repo
-	sqliteRepo  *sqlite.Repo
+	store       SummaryStore
 
 	mu        sync.Mutex
 	cache     []domain.Summary
 	cacheTime time.Time
 }
 
-func NewSummaries(logger *zap.Logger, vertex *vertexai.Client, parquet *parquet.Repo, sqliteRepo *sqlite.Repo) *Summaries {
-	return &Summaries{logger: logger, vertex: vertex, parquetRepo: parquet, sqliteRepo: sqliteRepo}
+func NewSummaries(logger *zap.Logger, vertex *vertexai.Client, parquet *parquet.Repo, store SummaryStore) *Summaries {
+	return &Summaries{logger: logger, vertex: vertex, parquetRepo: parquet, store: store}
 }
 
 // NewSummariesReadOnly creates a Summaries usecase for read-only operations (API).
-// This constructor does not require Vertex AI client since GetAllSummaries only reads from SQLite.
-func NewSummariesReadOnly(logger *zap.Logger, sqliteRepo *sqlite.Repo) *Summaries {
-	return &Summaries{logger: logger, sqliteRepo: sqliteRepo}
+// This constructor does not require Vertex AI client since GetAllSummaries only reads from the store.
+func NewSummariesReadOnly(logger *zap.Logger, store SummaryStore) *Summaries {
+	return &Summaries{logger: logger, store: store}
 }
 
 func (u *Summaries) GetAllSummaries(ctx context.Context) ([]domain.Summary, error) {
@@ -43,17 +42,17 @@ func (u *Summaries) GetAllSummaries(ctx context.Context) ([]domain.Summary, erro
 		return u.cache, nil
 	}
 
-	// Fetch summaries from SQLite database
-	summaries, err := u.sqliteRepo.GetAllSummaries()
+	// Fetch summaries from the store
+	summaries, err := u.store.GetAllSummaries()
 	if err != nil {
-		u.logger.Error("Failed to fetch summaries from SQLite", zap.Error(err))
+		u.logger.Error("Failed to fetch summaries", zap.Error(err))
 		return nil, err
 	}
 
 	u.cache = summaries
 	u.cacheTime = time.Now()
 
-	u.logger.Info("Loaded summaries from SQLite", zap.Int("count", len(summaries)))
+	u.logger.Info("Loaded summaries", zap.Int("count", len(summaries)))
 	return summaries, nil
 }
 
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+// Titles serves CFR titles with their metadata, totals and summaries.
+type Titles struct {
+	store titleSummaryStore
+}
+
+type titleSummaryStore interface {
+	TitleStore
+	SummaryStore
+}
+
+func NewTitles(store titleSummaryStore) *Titles {
+	return &Titles{store: store}
+}
+
+// ListTitles lists every known title in numeric order.
+func (u *Titles) ListTitles() ([]domain.TitleMetric, error) {
+	titles, err := u.store.GetTitleMetrics()
+	if titles == nil {
+		titles = []domain.TitleMetric{}
+	}
+	return titles, err
+}
+
+// GetTitle returns a title's detail with its latest summary. A title outside 1-50 is
+// domain.ErrInvalidData; one neither in the catalog nor loaded is domain.ErrNotFound.
+func (u *Titles) GetTitle(title string) (*domain.TitleDetail, error) {
+	n, err := strconv.Atoi(title)
+	if err != nil || n < 1 || n > 50 {
+		return nil, fmt.Errorf("%w: title %q must be a CFR title number", domain.ErrInvalidData, title)
+	}
+	detail, err := u.store.GetTitleDetail(strconv.Itoa(n))
+	if err != nil {
+		return nil, err
+	}
+	summary, err := u.store.GetSummaryByKey("title", detail.ID)
+	if err != nil {
+		return nil, err
+	}
+	if summary != nil {
+		detail.Summary = summary.Text
+		detail.SummaryCreatedAt = &summary.CreatedAt
+	}
+	return detail, nil
+}
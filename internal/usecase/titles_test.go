@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
+)
+
+type fakeTitleStore struct {
+	SummaryStore
+	got     string
+	summary *domain.Summary
+}
+
+func (f *fakeTitleStore) UpsertTitles([]domain.Title) error { return nil }
+
+func (f *fakeTitleStore) GetTitleMetrics() ([]domain.TitleMetric, error) { return nil, nil }
+
+func (f *fakeTitleStore) GetTitleDetail(title string) (*domain.TitleDetail, error) {
+	f.got = title
+	return &domain.TitleDetail{TitleMetric: domain.TitleMetric{ID: title}}, nil
+}
+
+func (f *fakeTitleStore) GetSummaryByKey(kind, key string) (*domain.Summary, error) {
+	if kind != "title" || key != f.got {
+		return nil, nil
+	}
+	return f.summary, nil
+}
+
+func TestGetTitle(t *testing.T) {
+	created := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeTitleStore{summary: &domain.Summary{Kind: "title", Key: "40", Text: "Air and water.", CreatedAt: created}}
+	u := NewTitles(store)
+
+	detail, err := u.GetTitle("040")
+	if err != nil {
+		t.Fatalf("GetTitle failed: %v", err)
+	}
+	if store.got != "40" || detail.Summary != "Air and water." || detail.SummaryCreatedAt == nil || !detail.SummaryCreatedAt.Equal(created) {
+		t.Errorf("GetTitle(040) = %+v for store title %q", detail, store.got)
+	}
+
+	store.summary = nil
+	if detail, err := u.GetTitle("7"); err != nil || detail.Summary != "" || detail.SummaryCreatedAt != nil {
+		t.Errorf("GetTitle(7) = %+v, %v; want no summary", detail, err)
+	}
+
+	for _, title := range []string{"", "abc", "0", "51"} {
+		if _, err := u.GetTitle(title); !errors.Is(err, domain.ErrInvalidData) {
+			t.Errorf("GetTitle(%q) error = %v, want ErrInvalidData", title, err)
+		}
+	}
+
+	if titles, err := u.ListTitles(); err != nil || titles == nil {
+		t.Errorf("ListTitles = %v, %v; want an empty list", titles, err)
+	}
+}
@@ -2,8 +2,17 @@ package integration_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
 	"testing"
+	"time"
 
+	pq "github.com/parquet-go/parquet-go"
+
+	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/blob"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/parquet"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/adapter/sqlite"
 	"github.com/Jibbscript/ecfr-dereg-dashboard/internal/domain"
@@ -26,15 +35,15 @@ func TestSnapshotFlow(t *testing.T) {
 	_ = usecase.NewSnapshot(parquetRepo, sqliteRepo)
 
 	// Seed data
-	sections := []domain.Section{
-		{ID: "1", WordCount: 100, ChecksumSHA256: "a"},
-	}
+	sections := checksummed(
+		domain.Section{ID: "1", WordCount: 100, Text: "a"},
+	)
 	parquetRepo.WriteSections(context.Background(), "2023-01-01", "1", sections)
 
-	sections2 := []domain.Section{
-		{ID: "1", WordCount: 110, ChecksumSHA256: "b"}, // Changed
-		{ID: "2", WordCount: 50, ChecksumSHA256: "c"},  // New
-	}
+	sections2 := checksummed(
+		domain.Section{ID: "1", WordCount: 110, Text: "b"}, // Changed
+		domain.Section{ID: "2", WordCount: 50, Text: "c"},  // New
+	)
 	parquetRepo.WriteSections(context.Background(), "2023-01-02", "1", sections2)
 
 	// Test Diff
@@ -45,6 +54,15 @@ func TestSnapshotFlow(t *testing.T) {
 	// But let's assume we test the ComputeDiffs logic given we pass dates manually if we refactor,
 	// or we just test the logic that relies on ReadSections.
 
+	// Snapshots are only readable once their manifest is complete.
+	if _, err := parquetRepo.ReadSections(context.Background(), "2023-01-01", "1"); !errors.Is(err, domain.ErrIncompleteSnapshot) {
+		t.Fatalf("Expected ErrIncompleteSnapshot before the manifest is written, got %v", err)
+	}
+	manifest := domain.SnapshotManifest{SnapshotID: "2023-01-01", Status: domain.SnapshotComplete}
+	if err := parquetRepo.WriteManifest(context.Background(), manifest); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+
 	// Actually, let's just test ReadSections works.
 	read, err := parquetRepo.ReadSections(context.Background(), "2023-01-01", "1")
 	if err != nil {
@@ -54,3 +72,600 @@ func TestSnapshotFlow(t *testing.T) {
 		t.Errorf("Expected 1 section, got %d", len(read))
 	}
 }
+
+func TestSnapshotManifests(t *testing.T) {
+	ctx := context.Background()
+	parquetRepo, err := parquet.NewLocalRepo(t.TempDir(), "parquet")
+	if err != nil {
+		t.Fatalf("Failed to create parquet repo: %v", err)
+	}
+
+	sections := checksummed(domain.Section{ID: "1", WordCount: 100, Text: "a"})
+	for _, snap := range []string{"2023-01-01", "2023-01-02", "2023-01-03"} {
+		if err := parquetRepo.WriteSections(ctx, snap, "1", sections); err != nil {
+			t.Fatalf("WriteSections failed: %v", err)
+		}
+	}
+	// 2023-01-02 never finished; 2023-01-03 has no manifest at all
+	for snap, status := range map[string]string{"2023-01-01": domain.SnapshotComplete, "2023-01-02": domain.SnapshotIncomplete} {
+		if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: snap, Status: status}); err != nil {
+			t.Fatalf("WriteManifest failed: %v", err)
+		}
+	}
+
+	snapshots, err := parquetRepo.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0] != "2023-01-01" {
+		t.Errorf("Expected only the complete snapshot, got %v", snapshots)
+	}
+
+	prev, err := parquetRepo.GetPrevSnapshot(ctx, "2023-01-03")
+	if err != nil {
+		t.Fatalf("GetPrevSnapshot failed: %v", err)
+	}
+	if prev != "2023-01-01" {
+		t.Errorf("Expected incomplete snapshots to be skipped, got prev %q", prev)
+	}
+
+	manifests, err := parquetRepo.ListManifests(ctx)
+	if err != nil {
+		t.Fatalf("ListManifests failed: %v", err)
+	}
+	if len(manifests) != 3 || manifests[2].Status != domain.SnapshotIncomplete {
+		t.Errorf("Expected all three snapshots listed with the last incomplete, got %+v", manifests)
+	}
+
+	info, err := parquetRepo.FileInfo(ctx, "2023-01-01", "1.parquet")
+	if err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	}
+	if info.Bytes == 0 || len(info.SHA256) != 64 {
+		t.Errorf("Unexpected file info: %+v", info)
+	}
+}
+
+func TestSameDaySnapshots(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	parquetRepo, err := parquet.NewLocalRepo(tempDir, "parquet")
+	if err != nil {
+		t.Fatalf("Failed to create parquet repo: %v", err)
+	}
+	sqliteRepo, err := sqlite.NewRepo(tempDir + "/test.db")
+	if err != nil {
+		t.Fatalf("Failed to create sqlite repo: %v", err)
+	}
+	snapshotUseCase := usecase.NewSnapshot(parquetRepo, sqliteRepo)
+
+	// A legacy date-only snapshot followed by two runs on the same day
+	day := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	first := domain.NewSnapshotID(day.Add(6 * time.Hour))
+	second := domain.NewSnapshotID(day.Add(18 * time.Hour))
+	for i, snap := range []string{"2023-01-02", first, second} {
+		sections := checksummed(domain.Section{ID: "1", WordCount: 100 + i, Text: snap})
+		if err := parquetRepo.WriteSections(ctx, snap, "1", sections); err != nil {
+			t.Fatalf("WriteSections failed: %v", err)
+		}
+		if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: snap, Status: domain.SnapshotComplete}); err != nil {
+			t.Fatalf("WriteManifest failed: %v", err)
+		}
+	}
+
+	// Each run keeps its own output
+	read, err := parquetRepo.ReadSections(ctx, first, "1")
+	if err != nil {
+		t.Fatalf("ReadSections failed: %v", err)
+	}
+	if len(read) != 1 || read[0].WordCount != 101 {
+		t.Errorf("Expected the first run's sections to survive the rerun, got %+v", read)
+	}
+
+	prev, err := parquetRepo.GetPrevSnapshot(ctx, second)
+	if err != nil {
+		t.Fatalf("GetPrevSnapshot failed: %v", err)
+	}
+	if prev != first {
+		t.Errorf("Expected prev %q, got %q", first, prev)
+	}
+	prev, err = parquetRepo.GetPrevSnapshot(ctx, first)
+	if err != nil {
+		t.Fatalf("GetPrevSnapshot failed: %v", err)
+	}
+	if prev != "2023-01-02" {
+		t.Errorf("Expected the legacy snapshot before the day's first run, got %q", prev)
+	}
+
+	// Publishing the rerun moves the day's pointer
+	for _, snap := range []string{first, second} {
+		if _, err := snapshotUseCase.Publish(ctx, snap); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	published, err := snapshotUseCase.GetPublished(ctx, "2023-01-02")
+	if err != nil {
+		t.Fatalf("GetPublished failed: %v", err)
+	}
+	if published.SnapshotID != second {
+		t.Errorf("Expected %q published, got %q", second, published.SnapshotID)
+	}
+	stored, err := sqliteRepo.GetPublishedSnapshot("2023-01-02")
+	if err != nil {
+		t.Fatalf("GetPublishedSnapshot failed: %v", err)
+	}
+	if stored.SnapshotID != second {
+		t.Errorf("Expected SQLite pointer %q, got %q", second, stored.SnapshotID)
+	}
+
+	if _, err := snapshotUseCase.GetPublished(ctx, "2023-01-03"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unpublished day, got %v", err)
+	}
+}
+
+func TestLoadPublishedSections(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	parquetRepo, err := parquet.NewLocalRepo(tempDir, "parquet")
+	if err != nil {
+		t.Fatalf("Failed to create parquet repo: %v", err)
+	}
+	sqliteRepo, err := sqlite.NewRepo(tempDir + "/test.db")
+	if err != nil {
+		t.Fatalf("Failed to create sqlite repo: %v", err)
+	}
+	snapshotUseCase := usecase.NewSnapshot(parquetRepo, sqliteRepo)
+
+	snap := domain.NewSnapshotID(time.Date(2023, 1, 2, 6, 0, 0, 0, time.UTC))
+	sections := checksummed(
+		domain.Section{ID: "§ 1.1", Title: "1", Part: "1", Section: "1.1", WordCount: 100, Text: "first", SnapshotID: snap},
+		domain.Section{ID: "§ 1.2", Title: "1", Part: "1", Section: "1.2", WordCount: 50, Text: "second", SnapshotID: snap},
+	)
+	if err := parquetRepo.WriteSections(ctx, snap, "1", sections); err != nil {
+		t.Fatalf("WriteSections failed: %v", err)
+	}
+
+	// A run still in progress cannot be loaded
+	if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: snap, Status: domain.SnapshotIncomplete}); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	if _, err := snapshotUseCase.LoadSections(ctx, snap, "1"); !errors.Is(err, domain.ErrIncompleteSnapshot) {
+		t.Fatalf("Expected ErrIncompleteSnapshot loading an incomplete snapshot, got %v", err)
+	}
+	if _, err := sqliteRepo.GetSectionDetail("1:1.1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("Expected no sections in the store before publishing, got %v", err)
+	}
+
+	if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: snap, Status: domain.SnapshotComplete}); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	if _, err := snapshotUseCase.Publish(ctx, snap); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	loaded, err := snapshotUseCase.LoadSections(ctx, snap, "1")
+	if err != nil {
+		t.Fatalf("LoadSections failed: %v", err)
+	}
+	if loaded != 2 {
+		t.Errorf("Expected 2 sections loaded, got %d", loaded)
+	}
+	detail, err := sqliteRepo.GetSectionDetail("1:1.2")
+	if err != nil {
+		t.Fatalf("GetSectionDetail failed: %v", err)
+	}
+	if detail.Text != "second" || detail.WordCount != 50 || detail.SnapshotID != snap {
+		t.Errorf("Expected the published section with its text, got %+v", detail)
+	}
+}
+
+func TestSnapshotChecksums(t *testing.T) {
+	ctx := context.Background()
+	store := blob.NewMem()
+	parquetRepo := parquet.NewStoreRepo(store, "parquet")
+
+	sections := checksummed(domain.Section{ID: "1", WordCount: 100, Text: "a"})
+	if err := parquetRepo.WriteSections(ctx, "2023-01-01", "1", sections); err != nil {
+		t.Fatalf("WriteSections failed: %v", err)
+	}
+	if _, err := store.Stat(ctx, "parquet/2023-01-01/1.parquet.sha256"); err != nil {
+		t.Fatalf("Expected a checksum sidecar, got %v", err)
+	}
+	if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: "2023-01-01", Status: domain.SnapshotComplete}); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+
+	// Files written before sidecars existed are read unchecked
+	if err := store.Delete(ctx, "parquet/2023-01-01/1.parquet.sha256"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := parquetRepo.ReadSections(ctx, "2023-01-01", "1"); err != nil {
+		t.Errorf("Expected a file without a sidecar to read, got %v", err)
+	}
+
+	if err := parquetRepo.WriteSections(ctx, "2023-01-01", "1", sections); err != nil {
+		t.Fatalf("WriteSections failed: %v", err)
+	}
+	w, err := store.Create(ctx, "parquet/2023-01-01/1.parquet")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Write([]byte("corrupted"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := parquetRepo.ReadSections(ctx, "2023-01-01", "1"); !errors.Is(err, domain.ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch for a corrupted file, got %v", err)
+	}
+}
+
+func TestSectionSchemaVersions(t *testing.T) {
+	ctx := context.Background()
+	store := blob.NewMem()
+	parquetRepo := parquet.NewStoreRepo(store, "parquet")
+	complete := func(snap string) {
+		t.Helper()
+		if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: snap, Status: domain.SnapshotComplete}); err != nil {
+			t.Fatalf("WriteManifest failed: %v", err)
+		}
+	}
+	putFile := func(key string, write func(w io.Writer) error) {
+		t.Helper()
+		bw, err := store.Create(ctx, key)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := write(bw); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if err := bw.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+
+	// Current layout: sorted by part, text in its own file
+	sections := checksummed(
+		domain.Section{ID: "§ 2.1", Part: "2", WordCount: 20, Text: "two"},
+		domain.Section{ID: "§ 1.2", Part: "1", WordCount: 12, Text: "one-two"},
+		domain.Section{ID: "§ 1.1", Part: "1", WordCount: 11, Text: "one-one"},
+	)
+	if err := parquetRepo.WriteSections(ctx, "2023-01-02", "1", sections); err != nil {
+		t.Fatalf("WriteSections failed: %v", err)
+	}
+	complete("2023-01-02")
+	read, err := parquetRepo.ReadSections(ctx, "2023-01-02", "1")
+	if err != nil {
+		t.Fatalf("ReadSections failed: %v", err)
+	}
+	var ids, texts []string
+	for _, s := range read {
+		ids = append(ids, s.ID)
+		texts = append(texts, s.Text)
+	}
+	if want := []string{"§ 1.2", "§ 1.1", "§ 2.1"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Expected sections sorted by part in document order, got %v", ids)
+	}
+	if want := []string{"one-two", "one-one", "two"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("Expected text joined back onto sections, got %v", texts)
+	}
+
+	// Metric-only reads never touch the text store
+	textKeys, err := store.List(ctx, "parquet/texts/")
+	if err != nil || len(textKeys) != 3 {
+		t.Fatalf("Expected 3 stored texts, got %v, %v", textKeys, err)
+	}
+	for _, key := range textKeys {
+		if err := store.Delete(ctx, key); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	metrics, err := parquetRepo.ReadSectionMetrics(ctx, "2023-01-02", "1")
+	if err != nil {
+		t.Fatalf("ReadSectionMetrics failed: %v", err)
+	}
+	if len(metrics) != 3 || metrics[0].WordCount != 12 || metrics[0].Text != "" {
+		t.Errorf("Unexpected metrics: %+v", metrics)
+	}
+
+	// Files from before schema versioning use domain.Section's field names with text inline
+	putFile("parquet/2023-01-01/1.parquet", func(w io.Writer) error {
+		gw := pq.NewGenericWriter[domain.Section](w)
+		if _, err := gw.Write([]domain.Section{{ID: "§ 1.1", Part: "1", WordCount: 10, Text: "legacy"}}); err != nil {
+			return err
+		}
+		return gw.Close()
+	})
+	complete("2023-01-01")
+	read, err = parquetRepo.ReadSections(ctx, "2023-01-01", "1")
+	if err != nil {
+		t.Fatalf("ReadSections on a version 1 file failed: %v", err)
+	}
+	if len(read) != 1 || read[0].WordCount != 10 || read[0].Text != "legacy" {
+		t.Errorf("Unexpected version 1 sections: %+v", read)
+	}
+	err = parquetRepo.ScanSections(ctx, "2023-01-01", "1", parquet.SectionQuery{Columns: []string{"word_count", "text"}, Parts: []string{"1"}}, func(s domain.Section) error {
+		if s.WordCount != 10 || s.Text != "legacy" {
+			t.Errorf("Unexpected version 1 scan: %+v", s)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanSections on a version 1 file failed: %v", err)
+	}
+
+	// Version 2 files keep text in <title>_text.parquet
+	version2 := pq.KeyValueMetadata("ecfr.schema_version", "2")
+	putFile("parquet/2023-01-04/1.parquet", func(w io.Writer) error {
+		gw := pq.NewGenericWriter[parquet.SectionRecord](w, version2)
+		if _, err := gw.Write([]parquet.SectionRecord{{ID: "§ 1.1", Part: "1", WordCount: 14}}); err != nil {
+			return err
+		}
+		return gw.Close()
+	})
+	putFile("parquet/2023-01-04/1_text.parquet", func(w io.Writer) error {
+		gw := pq.NewGenericWriter[parquet.SectionTextRecord](w, version2)
+		if _, err := gw.Write([]parquet.SectionTextRecord{{ID: "§ 1.1", Part: "1", Text: "split"}}); err != nil {
+			return err
+		}
+		return gw.Close()
+	})
+	complete("2023-01-04")
+	read, err = parquetRepo.ReadSections(ctx, "2023-01-04", "1")
+	if err != nil {
+		t.Fatalf("ReadSections on a version 2 file failed: %v", err)
+	}
+	if len(read) != 1 || read[0].WordCount != 14 || read[0].Text != "split" {
+		t.Errorf("Unexpected version 2 sections: %+v", read)
+	}
+	err = parquetRepo.ScanSections(ctx, "2023-01-04", "1", parquet.SectionQuery{Columns: []string{"id", "text"}}, func(s domain.Section) error {
+		if s.Text != "split" {
+			t.Errorf("Unexpected version 2 scan: %+v", s)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanSections on a version 2 file failed: %v", err)
+	}
+
+	// Files from a newer writer are refused rather than misread
+	putFile("parquet/2023-01-03/1.parquet", func(w io.Writer) error {
+		gw := pq.NewGenericWriter[parquet.SectionRecord](w, pq.KeyValueMetadata("ecfr.schema_version", "99"))
+		if _, err := gw.Write([]parquet.SectionRecord{{ID: "§ 1.1"}}); err != nil {
+			return err
+		}
+		return gw.Close()
+	})
+	complete("2023-01-03")
+	if _, err := parquetRepo.ReadSections(ctx, "2023-01-03", "1"); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("Expected ErrInvalidData for an unknown schema version, got %v", err)
+	}
+}
+
+func TestScanSections(t *testing.T) {
+	ctx := context.Background()
+	parquetRepo := parquet.NewStoreRepo(blob.NewMem(), "parquet")
+	sections := checksummed(
+		domain.Section{ID: "§ 1.1", Part: "1", AgencyID: "a", WordCount: 11, Text: "one-one", RevDate: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), RSCSPer1K: 1.5},
+		domain.Section{ID: "§ 1.2", Part: "1", AgencyID: "b", WordCount: 12, Text: "one-two"},
+		domain.Section{ID: "§ 2.1", Part: "2", AgencyID: "a", WordCount: 21, Text: "two-one"},
+	)
+	if err := parquetRepo.WriteSections(ctx, "2023-01-01", "1", sections); err != nil {
+		t.Fatalf("WriteSections failed: %v", err)
+	}
+	if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: "2023-01-01", Status: domain.SnapshotComplete}); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	scan := func(q parquet.SectionQuery) []domain.Section {
+		t.Helper()
+		var out []domain.Section
+		err := parquetRepo.ScanSections(ctx, "2023-01-01", "1", q, func(s domain.Section) error {
+			out = append(out, s)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ScanSections(%+v) failed: %v", q, err)
+		}
+		return out
+	}
+
+	// The column decoder agrees with the row reader
+	full, err := parquetRepo.ReadSections(ctx, "2023-01-01", "1")
+	if err != nil {
+		t.Fatalf("ReadSections failed: %v", err)
+	}
+	metrics, err := parquetRepo.ReadSectionMetrics(ctx, "2023-01-01", "1")
+	if err != nil {
+		t.Fatalf("ReadSectionMetrics failed: %v", err)
+	}
+	for i := range full {
+		full[i].Text = ""
+	}
+	if !reflect.DeepEqual(full, metrics) {
+		t.Errorf("ReadSectionMetrics = %+v, want %+v", metrics, full)
+	}
+
+	// Only requested columns are filled in
+	got := scan(parquet.SectionQuery{Columns: []string{"id", "word_count"}})
+	if len(got) != 3 || got[2].WordCount != 21 || got[2].Part != "" || got[2].Text != "" {
+		t.Errorf("Unexpected projection: %+v", got)
+	}
+	got = scan(parquet.SectionQuery{Columns: []string{"id", "text"}})
+	if len(got) != 3 || got[1].Text != "one-two" {
+		t.Errorf("Expected text to be joined, got %+v", got)
+	}
+
+	got = scan(parquet.SectionQuery{Columns: []string{"id"}, AgencyID: "a", Parts: []string{"2"}})
+	if len(got) != 1 || got[0].ID != "§ 2.1" {
+		t.Errorf("Expected agency and part filters to keep § 2.1, got %+v", got)
+	}
+	got = scan(parquet.SectionQuery{Columns: []string{"id"}, ChangedFrom: map[string]string{"§ 1.1": sections[0].ChecksumSHA256, "§ 1.2": "old"}})
+	if len(got) != 2 || got[0].ID != "§ 1.2" || got[1].ID != "§ 2.1" {
+		t.Errorf("Expected changed and new sections only, got %+v", got)
+	}
+
+	err = parquetRepo.ScanSections(ctx, "2023-01-01", "1", parquet.SectionQuery{Columns: []string{"bogus"}}, func(domain.Section) error { return nil })
+	if !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("Expected ErrInvalidData for an unknown column, got %v", err)
+	}
+	stop := errors.New("stop")
+	calls := 0
+	err = parquetRepo.ScanSections(ctx, "2023-01-01", "1", parquet.SectionQuery{}, func(domain.Section) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected the callback's error to stop the scan, got %v after %d calls", err, calls)
+	}
+}
+
+// checksummed sets each section's checksum from its text, as ingest does.
+func checksummed(sections ...domain.Section) []domain.Section {
+	for i := range sections {
+		sum := sha256.Sum256([]byte(sections[i].Text))
+		sections[i].ChecksumSHA256 = hex.EncodeToString(sum[:])
+	}
+	return sections
+}
+
+func TestSectionTextDedupe(t *testing.T) {
+	ctx := context.Background()
+	store := blob.NewMem()
+	parquetRepo := parquet.NewStoreRepo(store, "parquet")
+
+	// Only § 1.2 changes between the snapshots. Checksums cover normalized text, so they are
+	// not what text is stored under.
+	first := []domain.Section{
+		{ID: "§ 1.1", Part: "1", Text: "Unchanged.", ChecksumSHA256: "unchanged"},
+		{ID: "§ 1.2", Part: "1", Text: "Before.", ChecksumSHA256: "before"},
+	}
+	second := []domain.Section{
+		{ID: "§ 1.1", Part: "1", Text: "Unchanged.", ChecksumSHA256: "unchanged"},
+		{ID: "§ 1.2", Part: "1", Text: "After.", ChecksumSHA256: "after"},
+	}
+	for snap, sections := range map[string][]domain.Section{"2023-01-01": first, "2023-01-02": second} {
+		if err := parquetRepo.WriteSections(ctx, snap, "1", sections); err != nil {
+			t.Fatalf("WriteSections failed: %v", err)
+		}
+		if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: snap, Status: domain.SnapshotComplete}); err != nil {
+			t.Fatalf("WriteManifest failed: %v", err)
+		}
+	}
+
+	keys, err := store.List(ctx, "parquet/texts/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 distinct texts stored once each, got %v", keys)
+	}
+
+	// Either snapshot can be reconstructed
+	for snap, want := range map[string]string{"2023-01-01": "Before.", "2023-01-02": "After."} {
+		read, err := parquetRepo.ReadSections(ctx, snap, "1")
+		if err != nil {
+			t.Fatalf("ReadSections failed: %v", err)
+		}
+		if len(read) != 2 || read[0].Text != "Unchanged." || read[1].Text != want || read[1].TextSHA256 != domain.TextHash(want) {
+			t.Errorf("Unexpected %s sections: %+v", snap, read)
+		}
+	}
+	text, err := parquetRepo.ReadSectionText(ctx, domain.TextHash("Before."))
+	if err != nil || text != "Before." {
+		t.Errorf("ReadSectionText = %q, %v", text, err)
+	}
+
+	if err := parquetRepo.WriteSections(ctx, "2023-01-03", "1", []domain.Section{{ID: "§ 1.1", Text: "x", TextSHA256: "bogus"}}); !errors.Is(err, domain.ErrInvalidData) {
+		t.Errorf("Expected ErrInvalidData for a text hash that does not match its text, got %v", err)
+	}
+
+	// Stored text that no longer matches its hash is refused
+	hash := domain.TextHash("Unchanged.")
+	w, err := store.Create(ctx, "parquet/texts/"+hash[:2]+"/"+hash+".zst")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Write([]byte("garbage"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := parquetRepo.ReadSections(ctx, "2023-01-01", "1"); !errors.Is(err, domain.ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch for corrupted text, got %v", err)
+	}
+}
+
+func TestBackfillLegacyManifests(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	parquetRepo, err := parquet.NewLocalRepo(dir, "parquet")
+	if err != nil {
+		t.Fatalf("Failed to create parquet repo: %v", err)
+	}
+
+	// A date directory an old summary job left with no sections, and a summary batch now
+	store, err := blob.NewLocal(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	w, err := store.Create(ctx, "parquet/2023-01-04/summaries.parquet")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Write([]byte("not sections"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := parquetRepo.WriteSummaries(ctx, "2023-01-05", []domain.Summary{{Kind: "title", Key: "2", Text: "s"}}); err != nil {
+		t.Fatalf("WriteSummaries failed: %v", err)
+	}
+
+	// A pre-manifest snapshot, an unfinished run of the manifest era and a run with no manifest
+	sections := checksummed(domain.Section{ID: "1", WordCount: 100, Text: "a"}, domain.Section{ID: "2", WordCount: 50, Text: "b"})
+	run := domain.NewSnapshotID(time.Date(2023, 1, 3, 6, 0, 0, 0, time.UTC))
+	for _, snap := range []string{"2023-01-01", "2023-01-02", run} {
+		for _, title := range []string{"10", "2"} {
+			if err := parquetRepo.WriteSections(ctx, snap, title, sections); err != nil {
+				t.Fatalf("WriteSections failed: %v", err)
+			}
+		}
+	}
+	if err := parquetRepo.WriteDiffs(ctx, "2023-01-01", "2", []domain.Diff{{SectionID: "1", Changed: true}}); err != nil {
+		t.Fatalf("WriteDiffs failed: %v", err)
+	}
+	if err := parquetRepo.WriteManifest(ctx, domain.SnapshotManifest{SnapshotID: "2023-01-02", Status: domain.SnapshotIncomplete}); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+
+	if _, err := parquetRepo.ReadSections(ctx, "2023-01-01", "2"); !errors.Is(err, domain.ErrIncompleteSnapshot) {
+		t.Fatalf("Expected ErrIncompleteSnapshot before the backfill, got %v", err)
+	}
+	backfilled, err := parquetRepo.BackfillLegacyManifests(ctx)
+	if err != nil {
+		t.Fatalf("BackfillLegacyManifests failed: %v", err)
+	}
+	if !reflect.DeepEqual(backfilled, []string{"2023-01-01"}) {
+		t.Errorf("Expected only the legacy snapshot backfilled, got %v", backfilled)
+	}
+
+	m, err := parquetRepo.ReadManifest(ctx, "2023-01-01")
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if m.Status != domain.SnapshotComplete || len(m.Titles) != 2 || m.Titles[0].Title != "2" || m.Titles[1].Title != "10" {
+		t.Fatalf("Unexpected backfilled manifest: %+v", m)
+	}
+	if t2 := m.Titles[0]; t2.Status != domain.TitleSucceeded || t2.Rows != 2 || len(t2.Files) != 2 || t2.Files[0].SHA256 == "" {
+		t.Errorf("Unexpected backfilled title: %+v", t2)
+	}
+	if read, err := parquetRepo.ReadSections(ctx, "2023-01-01", "2"); err != nil || len(read) != 2 {
+		t.Errorf("ReadSections after backfill = %d sections, %v", len(read), err)
+	}
+
+	// The unfinished run, the run without a manifest and the summaries stay unreadable, and a
+	// rerun is a no-op
+	prev, err := parquetRepo.GetPrevSnapshot(ctx, domain.NewSnapshotID(time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC)))
+	if err != nil || prev != "2023-01-01" {
+		t.Errorf("GetPrevSnapshot = %q, %v; want the backfilled snapshot", prev, err)
+	}
+	if backfilled, err := parquetRepo.BackfillLegacyManifests(ctx); err != nil || len(backfilled) != 0 {
+		t.Errorf("second BackfillLegacyManifests = %v, %v; want nothing to do", backfilled, err)
+	}
+}